@@ -1,40 +1,134 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/online-compiler/backend/configs"
-	"github.com/online-compiler/backend/internal/api"
-	"github.com/online-compiler/backend/internal/database"
-	"github.com/online-compiler/backend/internal/services"
+	"github.com/online-compiler/backend/internal/app"
+	"github.com/online-compiler/backend/internal/logging"
+	"github.com/online-compiler/backend/internal/tracing"
 )
 
 func main() {
 	// Load configuration
-	configs.LoadConfig()
-	log.Printf("Judge0 URL: %s", configs.AppConfig.Judge0URL)
-	log.Println("Configuration loaded")
+	cfg, err := configs.LoadConfig()
+	if err != nil {
+		slog.Error("Failed to load configuration", "error", err)
+		os.Exit(1)
+	}
+
+	// Initialize logging
+	if err := logging.InitLogger(os.Stdout, cfg.LogFormat, cfg.LogLevel); err != nil {
+		slog.Error("Failed to initialize logging", "error", err)
+		os.Exit(1)
+	}
+	slog.Info("Configuration loaded", "file", cfg.ConfigFile, "judge0_url", cfg.Judge0URL, "piston_url", cfg.PistonURL)
 
-	// Initialize database
-	if err := database.InitDatabase(configs.AppConfig.DatabasePath); err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+	// Initialize tracing
+	shutdownTracing, err := tracing.InitTracing(context.Background(), cfg.TracingExporter, cfg.TracingFile)
+	if err != nil {
+		slog.Error("Failed to initialize tracing", "error", err)
+		os.Exit(1)
 	}
-	log.Println("Database initialized")
+	slog.Info("Tracing initialized", "exporter", cfg.TracingExporter)
 
-	// Initialize Redis (optional)
-	if err := services.InitRedis(); err != nil {
-		log.Printf("Warning: Failed to initialize Redis: %v (continuing without Redis)", err)
-	} else {
-		log.Println("Redis initialized")
+	// Initialize database, Redis and services
+	application, err := app.Open(context.Background(), cfg)
+	if err != nil {
+		slog.Error("Failed to initialize application", "error", err)
+		os.Exit(1)
 	}
 
 	// Setup router
-	router := api.SetupRouter()
+	router := application.Router()
+
+	server := &http.Server{
+		Addr:         ":" + cfg.Port,
+		Handler:      router,
+		ReadTimeout:  time.Duration(cfg.ReadTimeout) * time.Second,
+		WriteTimeout: time.Duration(cfg.WriteTimeout) * time.Second,
+		IdleTimeout:  time.Duration(cfg.IdleTimeout) * time.Second,
+	}
+
+	// Stop on SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Reload rate limits, origins and languages on SIGHUP or file change
+	go configs.WatchConfig(ctx, cfg, 5*time.Second)
 
 	// Start server
-	addr := ":" + configs.AppConfig.Port
-	log.Printf("Starting server on %s", addr)
-	if err := router.Run(addr); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("Starting server", "addr", server.Addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+
+	exitCode := 0
+	select {
+	case err := <-serverErr:
+		slog.Error("Failed to start server", "error", err)
+		exitCode = 1
+	case <-ctx.Done():
+		stop()
+		slog.Info("Shutdown signal received, draining in-flight executions",
+			"timeout", time.Duration(cfg.ShutdownTimeout)*time.Second)
+		shutdown(server, application, time.Duration(cfg.ShutdownDrain)*time.Second, time.Duration(cfg.ShutdownTimeout)*time.Second)
+	}
+
+	// Release resources
+	application.Close()
+	if err := shutdownTracing(context.Background()); err != nil {
+		slog.Warn("Failed to flush traces", "error", err)
+	}
+
+	slog.Info("Server stopped")
+	os.Exit(exitCode)
+}
+
+// shutdown fails readiness checks for drainDelay so load balancers stop
+// routing traffic here, then stops accepting connections and waits for
+// in-flight requests and asynchronous submissions until the timeout, then
+// cancels what is left
+func shutdown(server *http.Server, application *app.App, drainDelay, timeout time.Duration) {
+	application.MarkShuttingDown()
+	if drainDelay > 0 {
+		slog.Info("Reporting not ready before closing the listener", "delay", drainDelay)
+		time.Sleep(drainDelay)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		cancelled := application.Submissions.CancelAllSubmissions()
+		slog.Warn("Shutdown deadline exceeded, cancelling executions", "cancelled", cancelled, "error", err)
+		server.Close()
+		return
+	}
+
+	if err := application.Submissions.WaitForSubmissions(ctx); err != nil {
+		cancelled := application.Submissions.CancelAllSubmissions()
+		slog.Warn("Shutdown deadline exceeded, cancelling submissions", "cancelled", cancelled, "error", err)
+
+		// Give cancelled submissions a moment to record their final state
+		waitCtx, waitCancel := context.WithTimeout(context.Background(), time.Second)
+		defer waitCancel()
+		application.Submissions.WaitForSubmissions(waitCtx)
+	}
+
+	// Let final results reach their callback URLs; retries still pending
+	// at the deadline are abandoned by Close
+	if err := application.Webhooks.Wait(ctx); err != nil {
+		slog.Warn("Shutdown deadline exceeded, abandoning webhook deliveries", "error", err)
 	}
 }
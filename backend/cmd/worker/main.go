@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/online-compiler/backend/configs"
+	"github.com/online-compiler/backend/internal/app"
+	"github.com/online-compiler/backend/internal/logging"
+	"github.com/online-compiler/backend/internal/services"
+	"github.com/online-compiler/backend/internal/tracing"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// The worker runs executions queued by servers started with
+// QUEUE_MODE=redis. Any number of workers can share one Redis.
+func main() {
+	// Load configuration
+	cfg, err := configs.LoadConfig()
+	if err != nil {
+		slog.Error("Failed to load configuration", "error", err)
+		os.Exit(1)
+	}
+
+	// Initialize logging
+	if err := logging.InitLogger(os.Stdout, cfg.LogFormat, cfg.LogLevel); err != nil {
+		slog.Error("Failed to initialize logging", "error", err)
+		os.Exit(1)
+	}
+
+	// Initialize tracing
+	shutdownTracing, err := tracing.InitTracing(context.Background(), cfg.TracingExporter, cfg.TracingFile)
+	if err != nil {
+		slog.Error("Failed to initialize tracing", "error", err)
+		os.Exit(1)
+	}
+
+	// The queue lives in Redis, so it is required here
+	redisClient, err := services.NewRedisClient(context.Background(), cfg)
+	if err != nil {
+		slog.Error("Failed to initialize Redis", "error", err)
+		os.Exit(1)
+	}
+
+	executors, pools := app.NewExecutors(cfg)
+	queue := services.NewStreamQueue(redisClient, cfg)
+	worker := services.NewStreamWorker(queue, executors, consumerName())
+
+	// Stop on SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Reload languages on SIGHUP or file change
+	go configs.WatchConfig(ctx, cfg, 5*time.Second)
+
+	// Expose metrics for scraping
+	var metricsServer *http.Server
+	if cfg.WorkerMetricsPort != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.Handler())
+		metricsServer = &http.Server{Addr: ":" + cfg.WorkerMetricsPort, Handler: mux}
+		go func() {
+			if err := metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("Metrics server failed", "error", err)
+			}
+		}()
+	}
+
+	// Start consuming
+	workerErr := make(chan error, 1)
+	go func() {
+		workerErr <- worker.Run(ctx, cfg.WorkerCount)
+	}()
+	slog.Info("Worker started", "consumer", worker.Consumer, "stream", cfg.QueueStream, "concurrency", cfg.WorkerCount)
+
+	exitCode := 0
+	select {
+	case err := <-workerErr:
+		slog.Error("Worker stopped unexpectedly", "error", err)
+		exitCode = 1
+	case <-ctx.Done():
+		stop()
+		timeout := time.Duration(cfg.ShutdownTimeout) * time.Second
+		slog.Info("Shutdown signal received, finishing running jobs", "timeout", timeout)
+
+		// Unfinished jobs are not acknowledged and will be reclaimed by
+		// another worker after the visibility timeout
+		select {
+		case <-workerErr:
+			for _, pool := range pools {
+				pool.Close()
+			}
+		case <-time.After(timeout):
+			slog.Warn("Shutdown deadline exceeded, leaving running jobs to other workers")
+		}
+	}
+
+	// Release resources
+	if metricsServer != nil {
+		metricsServer.Close()
+	}
+	if err := redisClient.Close(); err != nil {
+		slog.Warn("Failed to close Redis", "error", err)
+	}
+	if err := shutdownTracing(context.Background()); err != nil {
+		slog.Warn("Failed to flush traces", "error", err)
+	}
+
+	slog.Info("Worker stopped")
+	os.Exit(exitCode)
+}
+
+// consumerName identifies this process in the consumer group
+func consumerName() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "worker"
+	}
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
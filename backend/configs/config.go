@@ -1,70 +1,557 @@
 package configs
 
 import (
+	"fmt"
 	"log"
+	"maps"
+	"net/url"
 	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
 )
 
+// Backends lists the execution backends a language can be routed to
+var Backends = []string{"judge0", "piston", "wasm", "embedded"}
+
+// Interpreters lists the interpreters available to the embedded backend
+var Interpreters = []string{"javascript", "lua", "starlark"}
+
+// Config holds the application settings. Values are layered: built-in
+// defaults, then the optional CONFIG_FILE (YAML or TOML), then environment
+// variables. Rate limits, allowed origins, languages and auth tokens can be
+// reloaded at runtime and must be read through their accessor methods.
 type Config struct {
-	Port              string
-	GinMode           string
-	Judge0URL         string
-	Judge0Timeout     int
-	RedisURL          string
-	RedisPassword     string
-	RedisDB           int
-	DatabasePath      string
-	RateLimitRequests int
-	RateLimitWindow   int
-	AllowedOrigins    []string
-}
-
-var AppConfig *Config
-
-func LoadConfig() {
+	Port              string                    `yaml:"port" toml:"port"`
+	GinMode           string                    `yaml:"gin_mode" toml:"gin_mode"`
+	Judge0URL         string                    `yaml:"judge0_url" toml:"judge0_url"`
+	Judge0Timeout     int                       `yaml:"judge0_timeout" toml:"judge0_timeout"`
+	PistonURL         string                    `yaml:"piston_url" toml:"piston_url"`
+	RedisURL          string                    `yaml:"redis_url" toml:"redis_url"`
+	RedisPassword     string                    `yaml:"redis_password" toml:"redis_password"`
+	RedisDB           int                       `yaml:"redis_db" toml:"redis_db"`
+	DatabasePath      string                    `yaml:"database_path" toml:"database_path"`
+	RateLimitRequests int                       `yaml:"rate_limit_requests" toml:"rate_limit_requests"`
+	RateLimitWindow   int                       `yaml:"rate_limit_window" toml:"rate_limit_window"`
+	AllowedOrigins    []string                  `yaml:"allowed_origins" toml:"allowed_origins"`
+	TracingExporter   string                    `yaml:"tracing_exporter" toml:"tracing_exporter"`
+	TracingFile       string                    `yaml:"tracing_file" toml:"tracing_file"`
+	LogFormat         string                    `yaml:"log_format" toml:"log_format"`
+	LogLevel          string                    `yaml:"log_level" toml:"log_level"`
+	ReadTimeout       int                       `yaml:"read_timeout" toml:"read_timeout"`
+	WriteTimeout      int                       `yaml:"write_timeout" toml:"write_timeout"`
+	IdleTimeout       int                       `yaml:"idle_timeout" toml:"idle_timeout"`
+	ShutdownTimeout   int                       `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+	ShutdownDrain     int                       `yaml:"shutdown_drain_delay" toml:"shutdown_drain_delay"`
+	DefaultBackend    string                    `yaml:"default_backend" toml:"default_backend"`
+	EmbeddedEnabled   bool                      `yaml:"embedded_enabled" toml:"embedded_enabled"`
+	WorkerCount       int                       `yaml:"worker_count" toml:"worker_count"`
+	QueueDepth        int                       `yaml:"queue_depth" toml:"queue_depth"`
+	Pools             map[string]PoolConfig     `yaml:"pools" toml:"pools"`
+	QueueMode         string                    `yaml:"queue_mode" toml:"queue_mode"`
+	QueueStream       string                    `yaml:"queue_stream" toml:"queue_stream"`
+	QueueVisibility   int                       `yaml:"queue_visibility_timeout" toml:"queue_visibility_timeout"`
+	QueueMaxDeliver   int                       `yaml:"queue_max_deliveries" toml:"queue_max_deliveries"`
+	QueueResultTTL    int                       `yaml:"queue_result_ttl" toml:"queue_result_ttl"`
+	WorkerMetricsPort string                    `yaml:"worker_metrics_port" toml:"worker_metrics_port"`
+	WebhookSecret     string                    `yaml:"webhook_secret" toml:"webhook_secret"`
+	WebhookAttempts   int                       `yaml:"webhook_max_attempts" toml:"webhook_max_attempts"`
+	WebhookTimeout    int                       `yaml:"webhook_timeout" toml:"webhook_timeout"`
+	WebhookBackoff    int                       `yaml:"webhook_backoff_ms" toml:"webhook_backoff_ms"`
+	WebhookPrivate    bool                      `yaml:"webhook_allow_private" toml:"webhook_allow_private"`
+	SnippetSweep      int                       `yaml:"snippet_sweep_interval" toml:"snippet_sweep_interval"`
+	PublicURL         string                    `yaml:"public_url" toml:"public_url"`
+	ViewDedupWindow   int                       `yaml:"view_dedup_window" toml:"view_dedup_window"`
+	ViewFlush         int                       `yaml:"view_flush_interval" toml:"view_flush_interval"`
+	BlobCompressMin   int                       `yaml:"blob_compress_min_size" toml:"blob_compress_min_size"`
+	MockMode          string                    `yaml:"mock_mode" toml:"mock_mode"`
+	MockFixturesDir   string                    `yaml:"mock_fixtures_dir" toml:"mock_fixtures_dir"`
+	MockLatency       int                       `yaml:"mock_latency_ms" toml:"mock_latency_ms"`
+	MockFailureRate   float64                   `yaml:"mock_failure_rate" toml:"mock_failure_rate"`
+	Languages         map[string]LanguageConfig `yaml:"languages" toml:"languages"`
+
+	// AuthTokens maps bearer tokens to the user IDs they authenticate
+	AuthTokens map[string]string `yaml:"auth_tokens" toml:"auth_tokens"`
+
+	// ConfigFile is the file the configuration was loaded from, if any
+	ConfigFile string `yaml:"-" toml:"-"`
+
+	// mu guards the reloadable fields
+	mu sync.RWMutex
+}
+
+// LanguageConfig holds per-language settings, keyed by Judge0 language ID
+type LanguageConfig struct {
+	Enabled       *bool    `yaml:"enabled" toml:"enabled"`
+	Backend       string   `yaml:"backend" toml:"backend"`
+	TimeLimit     float64  `yaml:"time_limit" toml:"time_limit"`
+	MemoryLimitKB int      `yaml:"memory_limit_kb" toml:"memory_limit_kb"`
+	CompilerFlags string   `yaml:"compiler_flags" toml:"compiler_flags"`
+	Args          []string `yaml:"args" toml:"args"`
+
+	// WasmModule is the WASI module (compiler or interpreter) run by the
+	// wasm backend. WasmMounts maps guest paths to host directories that
+	// are exposed read-only, e.g. an interpreter's standard library.
+	WasmModule string            `yaml:"wasm_module" toml:"wasm_module"`
+	WasmMounts map[string]string `yaml:"wasm_mounts" toml:"wasm_mounts"`
+
+	// Interpreter selects the embedded backend's interpreter for languages
+	// it does not recognise by ID. MaxSteps bounds Starlark execution
+	// steps; other interpreters are bounded by TimeLimit only.
+	Interpreter string `yaml:"interpreter" toml:"interpreter"`
+	MaxSteps    uint64 `yaml:"max_steps" toml:"max_steps"`
+}
+
+// PoolConfig overrides the worker pool size for one backend. Zero values
+// fall back to worker_count and queue_depth.
+type PoolConfig struct {
+	Workers    int `yaml:"workers" toml:"workers"`
+	QueueDepth int `yaml:"queue_depth" toml:"queue_depth"`
+}
+
+// IsEnabled reports whether the language accepts executions (default true)
+func (l LanguageConfig) IsEnabled() bool {
+	return l.Enabled == nil || *l.Enabled
+}
+
+// LoadConfig loads .env and returns the validated configuration
+func LoadConfig() (*Config, error) {
 	// Load .env file
 	if err := godotenv.Load(); err != nil {
 		log.Println("No .env file found, using environment variables")
 	}
 
-	AppConfig = &Config{
-		Port:              getEnv("PORT", "8080"),
-		GinMode:           getEnv("GIN_MODE", "debug"),
-		Judge0URL:         getEnv("JUDGE0_URL", "http://localhost:2358"),
-		Judge0Timeout:     getEnvAsInt("JUDGE0_TIMEOUT", 10),
-		RedisURL:          getEnv("REDIS_URL", "localhost:6379"),
-		RedisPassword:     getEnv("REDIS_PASSWORD", ""),
-		RedisDB:           getEnvAsInt("REDIS_DB", 0),
-		DatabasePath:      getEnv("DATABASE_PATH", "./data/compiler.db"),
-		RateLimitRequests: getEnvAsInt("RATE_LIMIT_REQUESTS", 30),
-		RateLimitWindow:   getEnvAsInt("RATE_LIMIT_WINDOW", 900),
-		AllowedOrigins:    getEnvAsSlice("ALLOWED_ORIGINS", []string{"http://localhost:5173"}),
+	return Load(os.Getenv("CONFIG_FILE"))
+}
+
+// Load builds a validated configuration from defaults, the given file (may be
+// empty) and environment variables
+func Load(path string) (*Config, error) {
+	cfg := defaultConfig()
+
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+		cfg.ConfigFile = path
+	}
+
+	env := &envLoader{}
+	env.string("PORT", &cfg.Port)
+	env.string("GIN_MODE", &cfg.GinMode)
+	env.string("JUDGE0_URL", &cfg.Judge0URL)
+	env.int("JUDGE0_TIMEOUT", &cfg.Judge0Timeout)
+	env.string("PISTON_URL", &cfg.PistonURL)
+	env.string("REDIS_URL", &cfg.RedisURL)
+	env.string("REDIS_PASSWORD", &cfg.RedisPassword)
+	env.int("REDIS_DB", &cfg.RedisDB)
+	env.string("DATABASE_PATH", &cfg.DatabasePath)
+	env.int("RATE_LIMIT_REQUESTS", &cfg.RateLimitRequests)
+	env.int("RATE_LIMIT_WINDOW", &cfg.RateLimitWindow)
+	env.slice("ALLOWED_ORIGINS", &cfg.AllowedOrigins)
+	env.string("TRACING_EXPORTER", &cfg.TracingExporter)
+	env.string("TRACING_FILE", &cfg.TracingFile)
+	env.string("LOG_FORMAT", &cfg.LogFormat)
+	env.string("LOG_LEVEL", &cfg.LogLevel)
+	env.int("SERVER_READ_TIMEOUT", &cfg.ReadTimeout)
+	env.int("SERVER_WRITE_TIMEOUT", &cfg.WriteTimeout)
+	env.int("SERVER_IDLE_TIMEOUT", &cfg.IdleTimeout)
+	env.int("SHUTDOWN_TIMEOUT", &cfg.ShutdownTimeout)
+	env.int("SHUTDOWN_DRAIN_DELAY", &cfg.ShutdownDrain)
+	env.string("DEFAULT_BACKEND", &cfg.DefaultBackend)
+	env.bool("EMBEDDED_ENABLED", &cfg.EmbeddedEnabled)
+	env.int("WORKER_COUNT", &cfg.WorkerCount)
+	env.int("QUEUE_DEPTH", &cfg.QueueDepth)
+	env.string("QUEUE_MODE", &cfg.QueueMode)
+	env.string("QUEUE_STREAM", &cfg.QueueStream)
+	env.int("QUEUE_VISIBILITY_TIMEOUT", &cfg.QueueVisibility)
+	env.int("QUEUE_MAX_DELIVERIES", &cfg.QueueMaxDeliver)
+	env.int("QUEUE_RESULT_TTL", &cfg.QueueResultTTL)
+	env.string("WORKER_METRICS_PORT", &cfg.WorkerMetricsPort)
+	env.string("WEBHOOK_SECRET", &cfg.WebhookSecret)
+	env.int("WEBHOOK_MAX_ATTEMPTS", &cfg.WebhookAttempts)
+	env.int("WEBHOOK_TIMEOUT", &cfg.WebhookTimeout)
+	env.int("WEBHOOK_BACKOFF_MS", &cfg.WebhookBackoff)
+	env.bool("WEBHOOK_ALLOW_PRIVATE", &cfg.WebhookPrivate)
+	env.int("SNIPPET_SWEEP_INTERVAL", &cfg.SnippetSweep)
+	env.string("PUBLIC_URL", &cfg.PublicURL)
+	env.int("VIEW_DEDUP_WINDOW", &cfg.ViewDedupWindow)
+	env.int("VIEW_FLUSH_INTERVAL", &cfg.ViewFlush)
+	env.int("BLOB_COMPRESS_MIN_SIZE", &cfg.BlobCompressMin)
+	env.string("MOCK_MODE", &cfg.MockMode)
+	env.string("MOCK_FIXTURES_DIR", &cfg.MockFixturesDir)
+	env.int("MOCK_LATENCY_MS", &cfg.MockLatency)
+	env.float("MOCK_FAILURE_RATE", &cfg.MockFailureRate)
+	env.mapping("AUTH_TOKENS", &cfg.AuthTokens)
+
+	// Piston historically shared JUDGE0_URL
+	if cfg.PistonURL == "" {
+		cfg.PistonURL = cfg.Judge0URL
 	}
+
+	problems := append(env.problems, cfg.validate()...)
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid configuration:\n  - %s", strings.Join(problems, "\n  - "))
+	}
+
+	return cfg, nil
 }
 
-func getEnv(key, defaultVal string) string {
+// Defaults returns the built-in defaults without reading files or the
+// environment
+func Defaults() *Config {
+	cfg := defaultConfig()
+	cfg.PistonURL = cfg.Judge0URL
+	return cfg
+}
+
+// defaultConfig returns the built-in defaults
+func defaultConfig() *Config {
+	return &Config{
+		Port:              "8080",
+		GinMode:           "debug",
+		Judge0URL:         "http://localhost:2358",
+		Judge0Timeout:     10,
+		RedisURL:          "localhost:6379",
+		RedisDB:           0,
+		DatabasePath:      "./data/compiler.db",
+		RateLimitRequests: 30,
+		RateLimitWindow:   900,
+		AllowedOrigins:    []string{"http://localhost:5173"},
+		TracingExporter:   "none",
+		TracingFile:       "./data/traces.json",
+		LogFormat:         "text",
+		LogLevel:          "info",
+		ReadTimeout:       15,
+		WriteTimeout:      60,
+		IdleTimeout:       120,
+		ShutdownTimeout:   30,
+		ShutdownDrain:     5,
+		DefaultBackend:    "piston",
+		WorkerCount:       8,
+		QueueDepth:        100,
+		QueueMode:         "local",
+		QueueStream:       "compiler:jobs",
+		QueueVisibility:   120,
+		QueueMaxDeliver:   3,
+		QueueResultTTL:    3600,
+		WorkerMetricsPort: "9091",
+		WebhookAttempts:   5,
+		WebhookTimeout:    10,
+		WebhookBackoff:    1000,
+		SnippetSweep:      60,
+		ViewDedupWindow:   1800,
+		ViewFlush:         5,
+		BlobCompressMin:   1024,
+		MockMode:          "off",
+		MockFixturesDir:   "./data/fixtures",
+	}
+}
+
+// loadFile merges a YAML or TOML file over the current values
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		err = toml.Unmarshal(data, c)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, c)
+	default:
+		return fmt.Errorf("unsupported config file format %q (use .yaml, .yml or .toml)", filepath.Ext(path))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+
+	return nil
+}
+
+// validate returns a description of every invalid setting
+func (c *Config) validate() []string {
+	var problems []string
+
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		problems = append(problems, fmt.Sprintf("port: %q is not a valid TCP port", c.Port))
+	}
+	if !slices.Contains([]string{"debug", "release", "test"}, c.GinMode) {
+		problems = append(problems, fmt.Sprintf("gin_mode: %q must be debug, release or test", c.GinMode))
+	}
+	if u, err := url.Parse(c.Judge0URL); err != nil || u.Scheme == "" || u.Host == "" {
+		problems = append(problems, fmt.Sprintf("judge0_url: %q is not an absolute URL", c.Judge0URL))
+	}
+	if u, err := url.Parse(c.PistonURL); err != nil || u.Scheme == "" || u.Host == "" {
+		problems = append(problems, fmt.Sprintf("piston_url: %q is not an absolute URL", c.PistonURL))
+	}
+	if u, err := url.Parse(c.PublicURL); c.PublicURL != "" && (err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "") {
+		problems = append(problems, fmt.Sprintf("public_url: %q is not an absolute http(s) URL", c.PublicURL))
+	}
+	if c.DatabasePath == "" {
+		problems = append(problems, "database_path: must not be empty")
+	}
+	if c.RedisDB < 0 {
+		problems = append(problems, "redis_db: must not be negative")
+	}
+	if c.ShutdownDrain < 0 {
+		problems = append(problems, "shutdown_drain_delay: must not be negative")
+	}
+	if c.ViewDedupWindow < 0 {
+		problems = append(problems, "view_dedup_window: must not be negative")
+	}
+	if c.BlobCompressMin < 0 {
+		problems = append(problems, "blob_compress_min_size: must not be negative")
+	}
+
+	positive := map[string]int{
+		"judge0_timeout":           c.Judge0Timeout,
+		"rate_limit_requests":      c.RateLimitRequests,
+		"rate_limit_window":        c.RateLimitWindow,
+		"read_timeout":             c.ReadTimeout,
+		"write_timeout":            c.WriteTimeout,
+		"idle_timeout":             c.IdleTimeout,
+		"shutdown_timeout":         c.ShutdownTimeout,
+		"worker_count":             c.WorkerCount,
+		"queue_visibility_timeout": c.QueueVisibility,
+		"queue_max_deliveries":     c.QueueMaxDeliver,
+		"queue_result_ttl":         c.QueueResultTTL,
+		"webhook_max_attempts":     c.WebhookAttempts,
+		"webhook_timeout":          c.WebhookTimeout,
+		"webhook_backoff_ms":       c.WebhookBackoff,
+		"snippet_sweep_interval":   c.SnippetSweep,
+		"view_flush_interval":      c.ViewFlush,
+	}
+	for _, name := range slices.Sorted(maps.Keys(positive)) {
+		if positive[name] <= 0 {
+			problems = append(problems, fmt.Sprintf("%s: must be greater than zero, got %d", name, positive[name]))
+		}
+	}
+
+	if !slices.Contains([]string{"none", "otlp", "stdout", "file"}, c.TracingExporter) {
+		problems = append(problems, fmt.Sprintf("tracing_exporter: %q must be none, otlp, stdout or file", c.TracingExporter))
+	}
+	if !slices.Contains([]string{"text", "json"}, c.LogFormat) {
+		problems = append(problems, fmt.Sprintf("log_format: %q must be text or json", c.LogFormat))
+	}
+	if !slices.Contains([]string{"debug", "info", "warn", "error"}, strings.ToLower(c.LogLevel)) {
+		problems = append(problems, fmt.Sprintf("log_level: %q must be debug, info, warn or error", c.LogLevel))
+	}
+	if !slices.Contains(Backends, c.DefaultBackend) {
+		problems = append(problems, fmt.Sprintf("default_backend: %q must be one of %s", c.DefaultBackend, strings.Join(Backends, ", ")))
+	}
+	if c.DefaultBackend == "embedded" && !c.EmbeddedEnabled {
+		problems = append(problems, "default_backend: the embedded backend requires embedded_enabled")
+	}
+	if c.QueueDepth < 0 {
+		problems = append(problems, "queue_depth: must not be negative")
+	}
+	for _, backend := range slices.Sorted(maps.Keys(c.Pools)) {
+		pool := c.Pools[backend]
+		if !slices.Contains(Backends, backend) {
+			problems = append(problems, fmt.Sprintf("pools.%s: unknown backend, must be one of %s", backend, strings.Join(Backends, ", ")))
+		}
+		if pool.Workers < 0 || pool.QueueDepth < 0 {
+			problems = append(problems, fmt.Sprintf("pools.%s: workers and queue_depth must not be negative", backend))
+		}
+	}
+	if !slices.Contains([]string{"local", "redis"}, c.QueueMode) {
+		problems = append(problems, fmt.Sprintf("queue_mode: %q must be local or redis", c.QueueMode))
+	}
+	if c.QueueMode == "redis" && c.QueueStream == "" {
+		problems = append(problems, "queue_stream: must not be empty when queue_mode is redis")
+	}
+	if port, err := strconv.Atoi(c.WorkerMetricsPort); c.WorkerMetricsPort != "" && (err != nil || port < 1 || port > 65535) {
+		problems = append(problems, fmt.Sprintf("worker_metrics_port: %q is not a valid TCP port", c.WorkerMetricsPort))
+	}
+	if !slices.Contains([]string{"off", "record", "replay"}, c.MockMode) {
+		problems = append(problems, fmt.Sprintf("mock_mode: %q must be off, record or replay", c.MockMode))
+	}
+	if c.MockMode != "off" && c.MockFixturesDir == "" {
+		problems = append(problems, "mock_fixtures_dir: must not be empty when mock_mode is enabled")
+	}
+	if c.MockLatency < 0 {
+		problems = append(problems, "mock_latency_ms: must not be negative")
+	}
+	if c.MockFailureRate < 0 || c.MockFailureRate > 1 {
+		problems = append(problems, fmt.Sprintf("mock_failure_rate: must be between 0 and 1, got %g", c.MockFailureRate))
+	}
+
+	for token, user := range c.AuthTokens {
+		if token == "" || user == "" {
+			problems = append(problems, "auth_tokens: tokens and user IDs must not be empty")
+			break
+		}
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(c.Languages)) {
+		lang := c.Languages[key]
+		if id, err := strconv.Atoi(key); err != nil || id < 1 {
+			problems = append(problems, fmt.Sprintf("languages.%s: key must be a Judge0 language ID", key))
+		}
+		if lang.Backend != "" && !slices.Contains(Backends, lang.Backend) {
+			problems = append(problems, fmt.Sprintf("languages.%s.backend: %q must be one of %s", key, lang.Backend, strings.Join(Backends, ", ")))
+		}
+		if lang.Backend == "embedded" && !c.EmbeddedEnabled {
+			problems = append(problems, fmt.Sprintf("languages.%s.backend: the embedded backend requires embedded_enabled", key))
+		}
+		if lang.Backend == "wasm" && lang.WasmModule == "" {
+			problems = append(problems, fmt.Sprintf("languages.%s.wasm_module: required for the wasm backend", key))
+		}
+		if lang.Interpreter != "" && !slices.Contains(Interpreters, lang.Interpreter) {
+			problems = append(problems, fmt.Sprintf("languages.%s.interpreter: %q must be one of %s", key, lang.Interpreter, strings.Join(Interpreters, ", ")))
+		}
+		if lang.TimeLimit < 0 {
+			problems = append(problems, fmt.Sprintf("languages.%s.time_limit: must not be negative", key))
+		}
+		if lang.MemoryLimitKB < 0 {
+			problems = append(problems, fmt.Sprintf("languages.%s.memory_limit_kb: must not be negative", key))
+		}
+	}
+
+	return problems
+}
+
+// RateLimit returns the allowed requests per window and the window in seconds
+func (c *Config) RateLimit() (requests, window int) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.RateLimitRequests, c.RateLimitWindow
+}
+
+// Origins returns the allowed CORS origins
+func (c *Config) Origins() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.AllowedOrigins
+}
+
+// Language returns the settings for a language ID
+func (c *Config) Language(languageID int) LanguageConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Languages[strconv.Itoa(languageID)]
+}
+
+// BackendFor returns the execution backend configured for a language ID
+func (c *Config) BackendFor(languageID int) string {
+	if backend := c.Language(languageID).Backend; backend != "" {
+		return backend
+	}
+	return c.DefaultBackend
+}
+
+// UserForToken returns the user ID a bearer token authenticates
+func (c *Config) UserForToken(token string) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	user, exists := c.AuthTokens[token]
+	return user, exists
+}
+
+// Pool returns the worker count and queue depth for a backend
+func (c *Config) Pool(backend string) (workers, queueDepth int) {
+	workers, queueDepth = c.WorkerCount, c.QueueDepth
+	if pool, exists := c.Pools[backend]; exists {
+		if pool.Workers > 0 {
+			workers = pool.Workers
+		}
+		if pool.QueueDepth > 0 {
+			queueDepth = pool.QueueDepth
+		}
+	}
+	return workers, queueDepth
+}
+
+// applyReloadable copies the hot-reloadable settings from next
+func (c *Config) applyReloadable(next *Config) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.RateLimitRequests = next.RateLimitRequests
+	c.RateLimitWindow = next.RateLimitWindow
+	c.AllowedOrigins = next.AllowedOrigins
+	c.Languages = next.Languages
+	c.AuthTokens = next.AuthTokens
+}
+
+// envLoader applies environment overrides and collects parse errors
+type envLoader struct {
+	problems []string
+}
+
+func (e *envLoader) string(key string, dst *string) {
 	if value := os.Getenv(key); value != "" {
-		return value
+		*dst = value
+	}
+}
+
+func (e *envLoader) int(key string, dst *int) {
+	value := os.Getenv(key)
+	if value == "" {
+		return
 	}
-	return defaultVal
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		e.problems = append(e.problems, fmt.Sprintf("%s: %q is not an integer", key, value))
+		return
+	}
+	*dst = parsed
 }
 
-func getEnvAsInt(key string, defaultVal int) int {
-	valueStr := getEnv(key, "")
-	if value, err := strconv.Atoi(valueStr); err == nil {
-		return value
+func (e *envLoader) float(key string, dst *float64) {
+	value := os.Getenv(key)
+	if value == "" {
+		return
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		e.problems = append(e.problems, fmt.Sprintf("%s: %q is not a number", key, value))
+		return
 	}
-	return defaultVal
+	*dst = parsed
 }
 
-func getEnvAsSlice(key string, defaultVal []string) []string {
-	valueStr := getEnv(key, "")
-	if valueStr == "" {
-		return defaultVal
+func (e *envLoader) bool(key string, dst *bool) {
+	value := os.Getenv(key)
+	if value == "" {
+		return
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		e.problems = append(e.problems, fmt.Sprintf("%s: %q is not a boolean", key, value))
+		return
+	}
+	*dst = parsed
+}
+
+// mapping parses comma-separated key:value pairs
+func (e *envLoader) mapping(key string, dst *map[string]string) {
+	value := os.Getenv(key)
+	if value == "" {
+		return
+	}
+	parsed := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		k, v, ok := strings.Cut(pair, ":")
+		if !ok {
+			e.problems = append(e.problems, fmt.Sprintf("%s: %q is not a key:value pair", key, pair))
+			return
+		}
+		parsed[k] = v
+	}
+	*dst = parsed
+}
+
+func (e *envLoader) slice(key string, dst *[]string) {
+	if value := os.Getenv(key); value != "" {
+		*dst = strings.Split(value, ",")
 	}
-	return strings.Split(valueStr, ",")
 }
@@ -0,0 +1,180 @@
+package configs
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// writeConfig writes a config file named name into a temporary directory
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadLayers(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{"yaml", "config.yaml", `
+port: "9000"
+rate_limit_requests: 10
+allowed_origins: [https://file.example.com]
+languages:
+  "71":
+    backend: judge0
+    time_limit: 2
+`},
+		{"toml", "config.toml", `
+port = "9000"
+rate_limit_requests = 10
+allowed_origins = ["https://file.example.com"]
+
+[languages.71]
+backend = "judge0"
+time_limit = 2
+`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The environment overrides the file, which overrides defaults
+			t.Setenv("RATE_LIMIT_REQUESTS", "20")
+			cfg, err := Load(writeConfig(t, tt.file, tt.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if cfg.Port != "9000" || !slices.Equal(cfg.AllowedOrigins, []string{"https://file.example.com"}) {
+				t.Errorf("port = %q, origins = %v, want the file's values", cfg.Port, cfg.AllowedOrigins)
+			}
+			if cfg.RateLimitRequests != 20 {
+				t.Errorf("rate_limit_requests = %d, want the environment's 20", cfg.RateLimitRequests)
+			}
+			if cfg.RateLimitWindow != 900 || cfg.ShutdownTimeout != 30 {
+				t.Errorf("rate_limit_window = %d, shutdown_timeout = %d, want defaults", cfg.RateLimitWindow, cfg.ShutdownTimeout)
+			}
+			if lang := cfg.Language(71); lang.Backend != "judge0" || lang.TimeLimit != 2 || cfg.BackendFor(71) != "judge0" {
+				t.Errorf("language 71 = %+v", lang)
+			}
+			if cfg.BackendFor(54) != "piston" {
+				t.Errorf("backend for 54 = %q, want the default", cfg.BackendFor(54))
+			}
+		})
+	}
+}
+
+func TestLoadRejectsInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		content string
+		want    []string
+	}{
+		{"invalid values", nil, `
+port: "70000"
+default_backend: docker
+languages:
+  python:
+    backend: wasm
+`, []string{"port:", "default_backend:", "languages.python: key must be", "languages.python.wasm_module: required"}},
+		{"env parse errors", map[string]string{"RATE_LIMIT_WINDOW": "soon", "WEBHOOK_ALLOW_PRIVATE": "maybe", "AUTH_TOKENS": "no-separator"}, "", []string{
+			`RATE_LIMIT_WINDOW: "soon" is not an integer`,
+			`WEBHOOK_ALLOW_PRIVATE: "maybe" is not a boolean`,
+			`AUTH_TOKENS: "no-separator" is not a key:value pair`,
+		}},
+		{"embedded without opt-in", map[string]string{"DEFAULT_BACKEND": "embedded"}, "", []string{"embedded backend requires embedded_enabled"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			path := ""
+			if tt.content != "" {
+				path = writeConfig(t, "config.yaml", tt.content)
+			}
+
+			_, err := Load(path)
+			if err == nil {
+				t.Fatal("Load succeeded, want validation errors")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadRejectsUnknownFormat(t *testing.T) {
+	if _, err := Load(writeConfig(t, "config.json", "{}")); err == nil || !strings.Contains(err.Error(), "unsupported config file format") {
+		t.Errorf("err = %v, want unsupported format", err)
+	}
+}
+
+func TestReloadAppliesOnlyReloadableSettings(t *testing.T) {
+	path := writeConfig(t, "config.yaml", `
+port: "9000"
+rate_limit_requests: 10
+`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, []byte(`
+port: "9100"
+worker_count: 2
+rate_limit_requests: 5
+rate_limit_window: 60
+allowed_origins: [https://new.example.com]
+auth_tokens:
+  token: alice
+languages:
+  "71":
+    args: ["-u"]
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	if requests, window := cfg.RateLimit(); requests != 5 || window != 60 {
+		t.Errorf("rate limit = %d/%d, want 5/60", requests, window)
+	}
+	if origins := cfg.Origins(); !slices.Equal(origins, []string{"https://new.example.com"}) {
+		t.Errorf("origins = %v", origins)
+	}
+	if user, ok := cfg.UserForToken("token"); !ok || user != "alice" {
+		t.Errorf("token user = %q, %v", user, ok)
+	}
+	if args := cfg.Language(71).Args; !slices.Equal(args, []string{"-u"}) {
+		t.Errorf("language 71 args = %v", args)
+	}
+	if cfg.Port != "9000" || cfg.WorkerCount != 8 {
+		t.Errorf("port = %q, worker_count = %d, want the values loaded at startup", cfg.Port, cfg.WorkerCount)
+	}
+
+	// An invalid file is rejected and the current settings are kept
+	if err := os.WriteFile(path, []byte("rate_limit_requests: -1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Reload(); err == nil {
+		t.Error("Reload accepted an invalid file")
+	}
+	if requests, _ := cfg.RateLimit(); requests != 5 {
+		t.Errorf("rate limit requests = %d after a rejected reload, want 5", requests)
+	}
+}
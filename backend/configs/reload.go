@@ -0,0 +1,78 @@
+package configs
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Reload re-reads the config file and environment and applies the
+// hot-reloadable settings. Invalid configurations are rejected and the
+// current settings are kept.
+func (c *Config) Reload() error {
+	next, err := Load(c.ConfigFile)
+	if err != nil {
+		return err
+	}
+
+	c.applyReloadable(next)
+	return nil
+}
+
+// WatchConfig reloads cfg on SIGHUP and whenever the config file's
+// modification time changes. It returns when ctx is done.
+func WatchConfig(ctx context.Context, cfg *Config, interval time.Duration) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	lastMod := modTime(cfg.ConfigFile)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			slog.Info("SIGHUP received, reloading configuration", "file", cfg.ConfigFile)
+		case <-ticker.C:
+			if cfg.ConfigFile == "" {
+				continue
+			}
+			mod := modTime(cfg.ConfigFile)
+			if mod.Equal(lastMod) {
+				continue
+			}
+			lastMod = mod
+			slog.Info("Config file changed, reloading configuration", "file", cfg.ConfigFile)
+		}
+
+		if err := cfg.Reload(); err != nil {
+			slog.Error("Configuration reload rejected, keeping previous settings", "error", err)
+			continue
+		}
+		requests, window := cfg.RateLimit()
+		slog.Info("Configuration reloaded",
+			"rate_limit_requests", requests,
+			"rate_limit_window", window,
+			"allowed_origins", cfg.Origins(),
+		)
+	}
+}
+
+// modTime returns the file's modification time, or the zero time
+func modTime(path string) time.Time {
+	if path == "" {
+		return time.Time{}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,17 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/online-compiler/backend/internal/logging"
+	"github.com/online-compiler/backend/internal/models"
+)
+
+// respondError writes an ErrorResponse tagged with the request ID
+func respondError(c *gin.Context, status int, message, code string) {
+	c.JSON(status, models.ErrorResponse{
+		Success:   false,
+		Error:     message,
+		Code:      code,
+		RequestID: logging.RequestID(c.Request.Context()),
+	})
+}
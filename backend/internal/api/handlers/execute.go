@@ -3,50 +3,62 @@ package handlers
 import (
 	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/online-compiler/backend/internal/metrics"
 	"github.com/online-compiler/backend/internal/models"
 	"github.com/online-compiler/backend/internal/services"
 )
 
-// ExecuteCode handles code execution requests
-func ExecuteCode(c *gin.Context) {
+// ExecuteCode handles code execution requests using the backend configured
+// for the language
+func (h *Handler) ExecuteCode(c *gin.Context) {
 	var req models.ExecuteRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Success: false,
-			Error:   "Invalid request format",
-			Code:    "INVALID_INPUT",
-		})
+		respondError(c, http.StatusBadRequest, "Invalid request format", "INVALID_INPUT")
 		return
 	}
 
 	// Validate code size (max 64KB)
 	if len(req.Code) > 65536 {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Success: false,
-			Error:   "Code exceeds maximum size of 64KB",
-			Code:    "INVALID_INPUT",
-		})
+		respondError(c, http.StatusBadRequest, "Code exceeds maximum size of 64KB", "INVALID_INPUT")
 		return
 	}
 
 	// Validate language ID (1-100 for Judge0)
 	if req.LanguageID < 1 || req.LanguageID > 100 {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Success: false,
-			Error:   "Invalid language ID",
-			Code:    "INVALID_INPUT",
-		})
+		respondError(c, http.StatusBadRequest, "Invalid language ID", "INVALID_INPUT")
 		return
 	}
 
+	if !h.Languages.Language(req.LanguageID).IsEnabled() {
+		respondError(c, http.StatusBadRequest, "Language is disabled", "LANGUAGE_DISABLED")
+		return
+	}
+
+	priority, err := services.ParsePriority(req.Priority, services.PriorityInteractive)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid priority", "INVALID_INPUT")
+		return
+	}
+
+	// Register the execution so it can be cancelled by request ID
+	ctx, done, ok := h.trackExecution(c, req.LanguageID)
+	if !ok {
+		return
+	}
+	defer done()
+	ctx = services.WithPriority(ctx, priority)
+
 	// Check cache
-	codeHash := generateHash(fmt.Sprintf("%d:%s:%s", req.LanguageID, req.Code, req.Stdin))
-	if cached, err := services.GetCachedResult(codeHash); err == nil {
+	codeHash := h.cacheKey(req.LanguageID, req.Code, req.Stdin)
+	if cached, err := h.Cache.GetCachedResult(ctx, codeHash); err == nil {
 		var response models.ExecuteResponse
 		if json.Unmarshal(cached, &response) == nil {
 			c.JSON(http.StatusOK, response)
@@ -54,26 +66,56 @@ func ExecuteCode(c *gin.Context) {
 		}
 	}
 
-	// Create Judge0 service instance
-	judge0Service := services.NewJudge0Service()
+	// Pick the executor for this language
+	executor, backend, err := h.Executors.ExecutorFor(req.LanguageID)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "no executor for language", "language_id", req.LanguageID, "error", err)
+		respondError(c, http.StatusServiceUnavailable, "Execution backend unavailable", "BACKEND_UNAVAILABLE")
+		return
+	}
 
 	// Execute code
-	result, err := judge0Service.ExecuteCode(req.LanguageID, req.Code, req.Stdin)
+	result, err := executor.ExecuteCode(ctx, req.LanguageID, req.Code, req.Stdin)
+	if respondQueueFull(c, err) {
+		return
+	}
+	metrics.ObserveExecution(services.LanguageName(req.LanguageID), backend, result)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Success: false,
-			Error:   "Code execution failed",
-			Code:    "EXECUTION_ERROR",
-		})
+		slog.ErrorContext(c.Request.Context(), "execution failed", "backend", backend, "language_id", req.LanguageID, "error", err)
+		respondError(c, http.StatusInternalServerError, "Code execution failed", "EXECUTION_ERROR")
 		return
 	}
 
-	// Cache result
-	services.CacheResult(codeHash, result)
+	// Cache result unless the execution was cancelled
+	if ctx.Err() == nil {
+		h.Cache.CacheResult(ctx, codeHash, result)
+	}
 
 	c.JSON(http.StatusOK, result)
 }
 
+// respondQueueFull sends 503 with a Retry-After header when err reports a
+// full execution queue
+func respondQueueFull(c *gin.Context, err error) bool {
+	var queueFull *services.QueueFullError
+	if !errors.As(err, &queueFull) {
+		return false
+	}
+
+	slog.WarnContext(c.Request.Context(), "execution queue full", "backend", queueFull.Backend, "retry_after", queueFull.RetryAfter)
+	c.Header("Retry-After", strconv.Itoa(int(queueFull.RetryAfter.Seconds())))
+	respondError(c, http.StatusServiceUnavailable, "Execution queue is full", "QUEUE_FULL")
+	return true
+}
+
+// cacheKey identifies an execution result by the request and the effective
+// settings of its language, so results from before a config reload that
+// changed the language's flags, args or limits are not served
+func (h *Handler) cacheKey(languageID int, code, stdin string) string {
+	settings, _ := json.Marshal(h.Languages.Language(languageID))
+	return generateHash(fmt.Sprintf("%d:%s:%s:%s", languageID, code, stdin, settings))
+}
+
 // generateHash creates a SHA256 hash for caching
 func generateHash(input string) string {
 	hash := sha256.Sum256([]byte(input))
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/online-compiler/backend/internal/metrics"
+	"github.com/online-compiler/backend/internal/models"
+	"github.com/online-compiler/backend/internal/services"
+)
+
+// maxBatchTestCases limits the number of test cases in one batch execution
+const maxBatchTestCases = 100
+
+// ExecuteBatch runs one program against several test cases and judges the
+// outputs of those with an expected output
+func (h *Handler) ExecuteBatch(c *gin.Context) {
+	var req models.BatchExecuteRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid request format", "INVALID_INPUT")
+		return
+	}
+
+	// Validate code size (max 64KB)
+	if len(req.Code) > 65536 {
+		respondError(c, http.StatusBadRequest, "Code exceeds maximum size of 64KB", "INVALID_INPUT")
+		return
+	}
+
+	// Validate language ID (1-100 for Judge0)
+	if req.LanguageID < 1 || req.LanguageID > 100 {
+		respondError(c, http.StatusBadRequest, "Invalid language ID", "INVALID_INPUT")
+		return
+	}
+
+	if len(req.TestCases) == 0 || len(req.TestCases) > maxBatchTestCases {
+		respondError(c, http.StatusBadRequest, "Between 1 and 100 test cases are required", "INVALID_INPUT")
+		return
+	}
+
+	if !h.Languages.Language(req.LanguageID).IsEnabled() {
+		respondError(c, http.StatusBadRequest, "Language is disabled", "LANGUAGE_DISABLED")
+		return
+	}
+
+	priority, err := services.ParsePriority(req.Priority, services.PriorityBatch)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid priority", "INVALID_INPUT")
+		return
+	}
+
+	// Register the execution so it can be cancelled by request ID
+	ctx, done, ok := h.trackExecution(c, req.LanguageID)
+	if !ok {
+		return
+	}
+	defer done()
+	ctx = services.WithPriority(ctx, priority)
+
+	executor, backend, err := h.Executors.ExecutorFor(req.LanguageID)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "no executor for language", "language_id", req.LanguageID, "error", err)
+		respondError(c, http.StatusServiceUnavailable, "Execution backend unavailable", "BACKEND_UNAVAILABLE")
+		return
+	}
+
+	results, err := services.ExecuteTestCases(ctx, executor, req.LanguageID, req.Code, req.TestCases)
+	if respondQueueFull(c, err) {
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "batch execution failed", "backend", backend, "language_id", req.LanguageID, "error", err)
+		respondError(c, http.StatusInternalServerError, "Code execution failed", "EXECUTION_ERROR")
+		return
+	}
+
+	response := models.BatchExecuteResponse{Success: true, Results: make([]models.TestCaseResult, len(results))}
+	language := services.LanguageName(req.LanguageID)
+	for i, result := range results {
+		metrics.ObserveExecution(language, backend, result)
+
+		passed := services.JudgeTestCase(req.TestCases[i], result)
+		response.Results[i] = models.TestCaseResult{ExecuteResponse: *result, Passed: passed}
+		response.Success = response.Success && result.Success
+		if passed != nil {
+			response.Total++
+			if *passed {
+				response.Passed++
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/online-compiler/backend/internal/metrics"
+	"github.com/online-compiler/backend/internal/models"
+	"github.com/online-compiler/backend/internal/services"
+)
+
+// ExecuteProject runs a program made of several files. The first file is
+// the entry point. Results are not cached.
+func (h *Handler) ExecuteProject(c *gin.Context) {
+	var req models.ProjectExecuteRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid request format", "INVALID_INPUT")
+		return
+	}
+
+	// Validate code size (max 64KB across all files)
+	if filesSize(req.Files) > 65536 {
+		respondError(c, http.StatusBadRequest, "Code exceeds maximum size of 64KB", "INVALID_INPUT")
+		return
+	}
+
+	if err := services.ValidateFiles(req.Files); err != nil {
+		respondError(c, http.StatusBadRequest, err.Error(), "INVALID_INPUT")
+		return
+	}
+
+	// Validate language ID (1-100 for Judge0)
+	if req.LanguageID < 1 || req.LanguageID > 100 {
+		respondError(c, http.StatusBadRequest, "Invalid language ID", "INVALID_INPUT")
+		return
+	}
+
+	if !h.Languages.Language(req.LanguageID).IsEnabled() {
+		respondError(c, http.StatusBadRequest, "Language is disabled", "LANGUAGE_DISABLED")
+		return
+	}
+
+	priority, err := services.ParsePriority(req.Priority, services.PriorityInteractive)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid priority", "INVALID_INPUT")
+		return
+	}
+
+	// Register the execution so it can be cancelled by request ID
+	ctx, done, ok := h.trackExecution(c, req.LanguageID)
+	if !ok {
+		return
+	}
+	defer done()
+	ctx = services.WithPriority(ctx, priority)
+
+	executor, backend, err := h.Executors.ExecutorFor(req.LanguageID)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "no executor for language", "language_id", req.LanguageID, "error", err)
+		respondError(c, http.StatusServiceUnavailable, "Execution backend unavailable", "BACKEND_UNAVAILABLE")
+		return
+	}
+
+	result, err := services.ExecuteProject(ctx, executor, req.LanguageID, req.Files, req.Stdin)
+	if respondQueueFull(c, err) {
+		return
+	}
+	if errors.Is(err, services.ErrProjectUnsupported) {
+		respondError(c, http.StatusBadRequest, "The "+backend+" backend cannot run multi-file projects", "MULTI_FILE_UNSUPPORTED")
+		return
+	}
+	metrics.ObserveExecution(services.LanguageName(req.LanguageID), backend, result)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "project execution failed", "backend", backend, "language_id", req.LanguageID, "error", err)
+		respondError(c, http.StatusInternalServerError, "Code execution failed", "EXECUTION_ERROR")
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
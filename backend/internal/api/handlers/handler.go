@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/online-compiler/backend/configs"
+	"github.com/online-compiler/backend/internal/services"
+)
+
+// Pinger reports whether a dependency is reachable
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// PingFunc adapts a function to the Pinger interface
+type PingFunc func(ctx context.Context) error
+
+// Ping implements Pinger
+func (f PingFunc) Ping(ctx context.Context) error {
+	return f(ctx)
+}
+
+// LanguageSettings provides per-language settings
+type LanguageSettings interface {
+	Language(languageID int) configs.LanguageConfig
+}
+
+// Handler serves the HTTP API using its injected dependencies
+type Handler struct {
+	Languages   LanguageSettings
+	Snippets    services.SnippetStore
+	Cache       services.ResultCache
+	Executors   *services.ExecutorRegistry
+	Submissions *services.SubmissionManager
+	Webhooks    *services.WebhookNotifier
+
+	// PublicURL is the base URL used in embed codes; the request's host is
+	// used when it is empty
+	PublicURL string
+
+	// Health checks reported by /health; nil checks are reported as
+	// disconnected or unknown
+	RedisHealth    Pinger
+	DatabaseHealth Pinger
+	Judge0Health   Pinger
+
+	// shuttingDown is set once the server starts draining connections
+	shuttingDown atomic.Bool
+}
+
+// MarkShuttingDown makes readiness checks fail while the server drains
+func (h *Handler) MarkShuttingDown() {
+	h.shuttingDown.Store(true)
+}
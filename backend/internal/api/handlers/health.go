@@ -4,14 +4,21 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/go-redis/redis/v8"
-	"github.com/online-compiler/backend/internal/database"
 	"github.com/online-compiler/backend/internal/models"
-	"github.com/online-compiler/backend/internal/services"
 )
 
+// ReadinessCheck reports whether the server accepts new traffic
+func (h *Handler) ReadinessCheck(c *gin.Context) {
+	if h.shuttingDown.Load() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "shutting_down"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ready"})
+}
+
 // HealthCheck handles health check requests
-func HealthCheck(c *gin.Context) {
+func (h *Handler) HealthCheck(c *gin.Context) {
 	response := models.HealthResponse{
 		Status:   "healthy",
 		Redis:    "disconnected",
@@ -19,30 +26,27 @@ func HealthCheck(c *gin.Context) {
 		Judge0:   "unknown",
 	}
 
+	if h.shuttingDown.Load() {
+		response.Status = "shutting_down"
+	}
+
 	// Check Redis
-	if services.RedisClient != nil {
-		if _, err := services.RedisClient.Ping(c).Result(); err == nil {
-			response.Redis = "connected"
-		} else if err == redis.Nil {
-			response.Redis = "connected"
-		}
+	if h.RedisHealth != nil && h.RedisHealth.Ping(c.Request.Context()) == nil {
+		response.Redis = "connected"
 	}
 
 	// Check Database
-	if database.DB != nil {
-		if sqlDB, err := database.DB.DB(); err == nil {
-			if err := sqlDB.Ping(); err == nil {
-				response.Database = "connected"
-			}
-		}
+	if h.DatabaseHealth != nil && h.DatabaseHealth.Ping(c.Request.Context()) == nil {
+		response.Database = "connected"
 	}
 
 	// Check Judge0
-	j := services.NewJudge0Service()
-	if _, err := j.SubmitCode(71, "print('health')", ""); err == nil {
-		response.Judge0 = "available"
-	} else {
-		response.Judge0 = "unavailable"
+	if h.Judge0Health != nil {
+		if err := h.Judge0Health.Ping(c.Request.Context()); err == nil {
+			response.Judge0 = "available"
+		} else {
+			response.Judge0 = "unavailable"
+		}
 	}
 
 	c.JSON(http.StatusOK, response)
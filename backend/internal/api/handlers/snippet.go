@@ -1,43 +1,51 @@
 package handlers
 
 import (
+	"errors"
+	"log/slog"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/online-compiler/backend/internal/models"
 	"github.com/online-compiler/backend/internal/services"
 )
 
+// Headers carrying snippet credentials
+const (
+	// EditTokenHeader carries the edit token returned at creation
+	EditTokenHeader = "X-Edit-Token"
+	// SnippetPasswordHeader carries the password of password snippets
+	SnippetPasswordHeader = "X-Snippet-Password"
+)
+
 // CreateSnippet handles snippet creation
-func CreateSnippet(c *gin.Context) {
+func (h *Handler) CreateSnippet(c *gin.Context) {
 	var req models.SnippetRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Success: false,
-			Error:   "Invalid request format",
-			Code:    "INVALID_INPUT",
-		})
+		respondError(c, http.StatusBadRequest, "Invalid request format", "INVALID_INPUT")
 		return
 	}
 
 	// Validate code size
-	if len(req.Code) > 65536 {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Success: false,
-			Error:   "Code exceeds maximum size of 64KB",
-			Code:    "INVALID_INPUT",
-		})
+	if len(req.Code)+filesSize(req.Files) > 65536 {
+		respondError(c, http.StatusBadRequest, "Code exceeds maximum size of 64KB", "INVALID_INPUT")
+		return
+	}
+	if req.Stdin != nil && len(*req.Stdin) > 65536 {
+		respondError(c, http.StatusBadRequest, "Stdin exceeds maximum size of 64KB", "INVALID_INPUT")
 		return
 	}
 
-	snippet, err := services.CreateSnippet(&req)
+	snippet, editToken, err := h.Snippets.CreateSnippet(c.Request.Context(), &req)
+	if errors.Is(err, services.ErrInvalidSnippetOptions) || errors.Is(err, services.ErrInvalidSnippetFiles) {
+		respondError(c, http.StatusBadRequest, err.Error(), "INVALID_INPUT")
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Success: false,
-			Error:   "Failed to create snippet",
-			Code:    "INTERNAL_ERROR",
-		})
+		slog.ErrorContext(c.Request.Context(), "failed to create snippet", "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to create snippet", "INTERNAL_ERROR")
 		return
 	}
 
@@ -45,22 +53,228 @@ func CreateSnippet(c *gin.Context) {
 		Success:   true,
 		SnippetID: snippet.ID,
 		ShareURL:  "/snippets/" + snippet.ID,
+		EditToken: editToken,
 	})
 }
 
 // GetSnippet handles snippet retrieval
-func GetSnippet(c *gin.Context) {
+func (h *Handler) GetSnippet(c *gin.Context) {
 	id := c.Param("id")
 
-	snippet, err := services.GetSnippet(id)
+	snippet, err := h.Snippets.GetSnippet(c.Request.Context(), id, snippetAccess(c))
+	if err != nil {
+		respondSnippetError(c, err, "get")
+		return
+	}
+
+	c.JSON(http.StatusOK, snippet)
+}
+
+// UpdateSnippet replaces a snippet's contents. The caller must be the owner
+// or send the edit token in the X-Edit-Token header.
+func (h *Handler) UpdateSnippet(c *gin.Context) {
+	var req models.SnippetRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid request format", "INVALID_INPUT")
+		return
+	}
+
+	// Validate code size
+	if len(req.Code)+filesSize(req.Files) > 65536 {
+		respondError(c, http.StatusBadRequest, "Code exceeds maximum size of 64KB", "INVALID_INPUT")
+		return
+	}
+	if req.Stdin != nil && len(*req.Stdin) > 65536 {
+		respondError(c, http.StatusBadRequest, "Stdin exceeds maximum size of 64KB", "INVALID_INPUT")
+		return
+	}
+
+	snippet, err := h.Snippets.UpdateSnippet(c.Request.Context(), c.Param("id"), &req, snippetAccess(c))
 	if err != nil {
-		c.JSON(http.StatusNotFound, models.ErrorResponse{
-			Success: false,
-			Error:   "Snippet not found",
-			Code:    "NOT_FOUND",
-		})
+		respondSnippetError(c, err, "update")
 		return
 	}
 
 	c.JSON(http.StatusOK, snippet)
 }
+
+// DeleteSnippet removes a snippet. The caller must be the owner or send the
+// edit token in the X-Edit-Token header.
+func (h *Handler) DeleteSnippet(c *gin.Context) {
+	if err := h.Snippets.DeleteSnippet(c.Request.Context(), c.Param("id"), snippetAccess(c)); err != nil {
+		respondSnippetError(c, err, "delete")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ForkSnippet copies a snippet under a new ID that records its parent. The
+// body may pick a revision to fork and a new title.
+func (h *Handler) ForkSnippet(c *gin.Context) {
+	var req models.ForkSnippetRequest
+
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid request format", "INVALID_INPUT")
+			return
+		}
+	}
+	if req.Revision < 0 {
+		respondError(c, http.StatusBadRequest, "Revision must be a positive integer", "INVALID_INPUT")
+		return
+	}
+
+	fork, editToken, err := h.Snippets.ForkSnippet(c.Request.Context(), c.Param("id"), &req, snippetAccess(c))
+	if err != nil {
+		respondSnippetError(c, err, "fork")
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.SnippetResponse{
+		Success:   true,
+		SnippetID: fork.ID,
+		ShareURL:  "/snippets/" + fork.ID,
+		EditToken: editToken,
+		ParentID:  *fork.ParentID,
+	})
+}
+
+// ListSnippetForks lists a snippet's direct forks
+func (h *Handler) ListSnippetForks(c *gin.Context) {
+	id := c.Param("id")
+
+	forks, err := h.Snippets.ListForks(c.Request.Context(), id, snippetAccess(c))
+	if err != nil {
+		respondSnippetError(c, err, "list forks of")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SnippetForksResponse{SnippetID: id, ForkCount: len(forks), Forks: forks})
+}
+
+// ListSnippetRevisions lists a snippet's revisions, oldest first
+func (h *Handler) ListSnippetRevisions(c *gin.Context) {
+	id := c.Param("id")
+
+	revisions, err := h.Snippets.ListRevisions(c.Request.Context(), id, snippetAccess(c))
+	if err != nil {
+		respondSnippetError(c, err, "list revisions of")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SnippetRevisionsResponse{SnippetID: id, Revisions: revisions})
+}
+
+// GetSnippetRevision returns one revision of a snippet
+func (h *Handler) GetSnippetRevision(c *gin.Context) {
+	revision, err := strconv.Atoi(c.Param("rev"))
+	if err != nil || revision < 1 {
+		respondError(c, http.StatusBadRequest, "Revision must be a positive integer", "INVALID_INPUT")
+		return
+	}
+
+	rev, err := h.Snippets.GetRevision(c.Request.Context(), c.Param("id"), revision, snippetAccess(c))
+	if err != nil {
+		respondSnippetError(c, err, "get revision of")
+		return
+	}
+
+	c.JSON(http.StatusOK, rev)
+}
+
+// DiffSnippetRevisions returns a unified diff between the revisions given
+// by the from and to query parameters. to defaults to the latest revision
+// and from to the one before to.
+func (h *Handler) DiffSnippetRevisions(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := c.Param("id")
+
+	to, err := revisionQuery(c, "to")
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "to must be a positive integer", "INVALID_INPUT")
+		return
+	}
+	from, err := revisionQuery(c, "from")
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "from must be a positive integer", "INVALID_INPUT")
+		return
+	}
+
+	if to == 0 {
+		revisions, err := h.Snippets.ListRevisions(ctx, id, snippetAccess(c))
+		if err != nil {
+			respondSnippetError(c, err, "diff")
+			return
+		}
+		if len(revisions) > 0 {
+			to = revisions[len(revisions)-1].Revision
+		}
+	}
+	if from == 0 {
+		from = max(to-1, 1)
+	}
+
+	diff, err := h.Snippets.DiffRevisions(ctx, id, from, to, snippetAccess(c))
+	if err != nil {
+		respondSnippetError(c, err, "diff")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SnippetDiffResponse{SnippetID: id, From: from, To: to, Diff: diff})
+}
+
+// revisionQuery parses an optional revision number, returning 0 if absent
+func revisionQuery(c *gin.Context, name string) (int, error) {
+	value := c.Query(name)
+	if value == "" {
+		return 0, nil
+	}
+	revision, err := strconv.Atoi(value)
+	if err == nil && revision < 1 {
+		err = strconv.ErrRange
+	}
+	return revision, err
+}
+
+// filesSize returns the combined size of the files' contents
+func filesSize(files []models.SnippetFile) int {
+	size := 0
+	for _, file := range files {
+		size += len(file.Content)
+	}
+	return size
+}
+
+// snippetAccess returns the snippet credentials sent with the request
+func snippetAccess(c *gin.Context) services.SnippetAccess {
+	return services.SnippetAccess{
+		EditToken: c.GetHeader(EditTokenHeader),
+		Password:  c.GetHeader(SnippetPasswordHeader),
+		Visitor:   c.ClientIP() + " " + c.Request.UserAgent(),
+	}
+}
+
+// respondSnippetError maps snippet store errors to responses
+func respondSnippetError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidSnippetOptions), errors.Is(err, services.ErrInvalidSnippetFiles):
+		respondError(c, http.StatusBadRequest, err.Error(), "INVALID_INPUT")
+	case errors.Is(err, services.ErrSnippetPasswordRequired):
+		respondError(c, http.StatusUnauthorized, "This snippet requires a password", "PASSWORD_REQUIRED")
+	case errors.Is(err, services.ErrSnippetPasswordInvalid):
+		respondError(c, http.StatusForbidden, "Invalid snippet password", "INVALID_PASSWORD")
+	case errors.Is(err, services.ErrSnippetNotFound):
+		respondError(c, http.StatusNotFound, "Snippet not found", "NOT_FOUND")
+	case errors.Is(err, services.ErrRevisionNotFound):
+		respondError(c, http.StatusNotFound, "Revision not found", "NOT_FOUND")
+	case errors.Is(err, services.ErrSnippetForbidden):
+		respondError(c, http.StatusForbidden, "Only the owner or a valid edit token may modify this snippet", "FORBIDDEN")
+	case errors.Is(err, services.ErrSnippetConflict):
+		respondError(c, http.StatusConflict, "Snippet was modified concurrently, retry the update", "CONFLICT")
+	default:
+		slog.ErrorContext(c.Request.Context(), "snippet "+action+" failed", "snippet_id", c.Param("id"), "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to "+action+" snippet", "INTERNAL_ERROR")
+	}
+}
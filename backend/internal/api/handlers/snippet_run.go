@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/online-compiler/backend/internal/metrics"
+	"github.com/online-compiler/backend/internal/models"
+	"github.com/online-compiler/backend/internal/services"
+)
+
+// RunSnippet executes a snippet with the executor configured for its
+// language. The body may override the saved stdin and args; runs with the
+// saved ones are stored as the snippet's last_run. Running counts as a
+// view.
+func (h *Handler) RunSnippet(c *gin.Context) {
+	var req models.SnippetRunRequest
+
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid request format", "INVALID_INPUT")
+			return
+		}
+	}
+	if err := services.ValidateArgs(req.Args); err != nil {
+		respondError(c, http.StatusBadRequest, err.Error(), "INVALID_INPUT")
+		return
+	}
+
+	priority, err := services.ParsePriority(req.Priority, services.PriorityInteractive)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid priority", "INVALID_INPUT")
+		return
+	}
+
+	snippet, err := h.Snippets.GetSnippet(c.Request.Context(), c.Param("id"), snippetAccess(c))
+	if err != nil {
+		respondSnippetError(c, err, "run")
+		return
+	}
+
+	languageID := snippet.LanguageID
+	if languageID == 0 {
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("Language %q cannot be run; set language_id", snippet.Language), "UNSUPPORTED_LANGUAGE")
+		return
+	}
+	if !h.Languages.Language(languageID).IsEnabled() {
+		respondError(c, http.StatusBadRequest, "Language is disabled", "LANGUAGE_DISABLED")
+		return
+	}
+
+	stdin, args := snippet.Stdin, []string(snippet.Args)
+	if req.Stdin != nil {
+		stdin = *req.Stdin
+	}
+	if req.Args != nil {
+		args = req.Args
+	}
+	saved := stdin == snippet.Stdin && slices.Equal(args, snippet.Args)
+
+	// Register the execution so it can be cancelled by request ID
+	ctx, done, ok := h.trackExecution(c, languageID)
+	if !ok {
+		return
+	}
+	defer done()
+	ctx = services.WithArgs(services.WithPriority(ctx, priority), args)
+
+	executor, backend, err := h.Executors.ExecutorFor(languageID)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "no executor for language", "language_id", languageID, "error", err)
+		respondError(c, http.StatusServiceUnavailable, "Execution backend unavailable", "BACKEND_UNAVAILABLE")
+		return
+	}
+
+	var result *models.ExecuteResponse
+	if len(snippet.Files) > 0 {
+		result, err = services.ExecuteProject(ctx, executor, languageID, snippet.Files, stdin)
+	} else {
+		result, err = executor.ExecuteCode(ctx, languageID, snippet.Code, stdin)
+	}
+	if respondQueueFull(c, err) {
+		return
+	}
+	if errors.Is(err, services.ErrProjectUnsupported) {
+		respondError(c, http.StatusBadRequest, "The "+backend+" backend cannot run multi-file projects", "MULTI_FILE_UNSUPPORTED")
+		return
+	}
+	metrics.ObserveExecution(services.LanguageName(languageID), backend, result)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "snippet run failed", "snippet_id", snippet.ID, "backend", backend, "error", err)
+		respondError(c, http.StatusInternalServerError, "Code execution failed", "EXECUTION_ERROR")
+		return
+	}
+	if err := h.Snippets.RecordRun(c.Request.Context(), snippet.ID); err != nil {
+		slog.WarnContext(c.Request.Context(), "failed to record snippet run", "snippet_id", snippet.ID, "error", err)
+	}
+
+	// Keep the stored result unless the run was cancelled
+	if saved && ctx.Err() == nil {
+		if err := h.Snippets.SaveRun(c.Request.Context(), snippet, result); err != nil {
+			slog.ErrorContext(c.Request.Context(), "failed to save snippet run", "snippet_id", snippet.ID, "error", err)
+		}
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/online-compiler/backend/internal/models"
+	"github.com/online-compiler/backend/internal/services"
+)
+
+// ListSnippets lists and searches snippets. Query parameters: q (searched
+// in titles and code), language, tag (repeatable, all must match), owner,
+// sort (recent or views), limit and cursor (the next_cursor of the previous
+// page).
+func (h *Handler) ListSnippets(c *gin.Context) {
+	query := services.SnippetQuery{
+		Query:    c.Query("q"),
+		Language: c.Query("language"),
+		Tags:     c.QueryArray("tag"),
+		Owner:    c.Query("owner"),
+		Sort:     c.Query("sort"),
+		Cursor:   c.Query("cursor"),
+	}
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > services.MaxSnippetPageSize {
+			respondError(c, http.StatusBadRequest, "limit must be between 1 and 100", "INVALID_INPUT")
+			return
+		}
+		query.Limit = limit
+	}
+
+	snippets, next, err := h.Snippets.ListSnippets(c.Request.Context(), query)
+	if errors.Is(err, services.ErrInvalidCursor) {
+		respondError(c, http.StatusBadRequest, "Invalid cursor", "INVALID_INPUT")
+		return
+	}
+	if err != nil {
+		respondSnippetError(c, err, "list")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SnippetListResponse{Snippets: snippets, NextCursor: next})
+}
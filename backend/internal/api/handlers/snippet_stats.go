@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/online-compiler/backend/internal/services"
+)
+
+// GetSnippetStats returns a snippet's total views and runs and its daily
+// counts over the last ?days= days (30 by default). The caller must be the
+// owner or send the edit token in the X-Edit-Token header.
+func (h *Handler) GetSnippetStats(c *gin.Context) {
+	days := services.DefaultStatsDays
+	if value, set := c.GetQuery("days"); set {
+		var err error
+		if days, err = strconv.Atoi(value); err != nil {
+			respondError(c, http.StatusBadRequest, "days must be an integer", "INVALID_INPUT")
+			return
+		}
+	}
+
+	stats, err := h.Snippets.SnippetStats(c.Request.Context(), c.Param("id"), days, snippetAccess(c))
+	if err != nil {
+		respondSnippetError(c, err, "get stats for")
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"html/template"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/online-compiler/backend/internal/models"
+	"github.com/online-compiler/backend/internal/services"
+)
+
+const (
+	// embedWidth and embedHeight are the default size of embedded snippets
+	embedWidth  = 640
+	embedHeight = 400
+	// oEmbedProvider names the service in oEmbed responses
+	oEmbedProvider = "Online Code Compiler"
+)
+
+// oEmbedURLPattern matches the snippet URLs accepted by the oEmbed endpoint
+var oEmbedURLPattern = regexp.MustCompile(`/snippets/([A-Za-z0-9-]+)(?:/(?:embed|raw))?/?$`)
+
+// embedPage renders a read-only view of a snippet for iframes
+var embedPage = template.Must(template.New("embed").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body{margin:0;font:13px/1.5 system-ui,sans-serif;background:#fff;color:#1f2328}
+body.dark{background:#0d1117;color:#e6edf3}
+header,.name{display:flex;justify-content:space-between;gap:8px;padding:6px 12px;border-bottom:1px solid #d0d7de;background:#f6f8fa}
+.dark header,.dark .name{border-color:#30363d;background:#161b22}
+a{color:inherit}
+pre{margin:0;padding:12px;overflow:auto;font:12px/1.45 ui-monospace,monospace;tab-size:4}
+.output pre{border-top:1px solid #d0d7de;opacity:.85}
+.dark .output pre{border-color:#30363d}
+</style>
+</head>
+<body class="{{.Theme}}">
+<header><strong>{{.Title}}</strong><a href="{{.RawURL}}" target="_blank" rel="noopener">{{.Language}} · view raw</a></header>
+{{range .Files}}{{if $.MultiFile}}<div class="name">{{.Name}}</div>{{end}}<pre><code>{{.Content}}</code></pre>
+{{end}}{{with .Output}}<div class="output"><div class="name">Output</div><pre>{{.}}</pre></div>
+{{end}}</body>
+</html>
+`))
+
+// embedScript inserts an iframe showing the snippet after the script tag
+const embedScript = `(function () {
+  var script = document.currentScript;
+  var frame = document.createElement("iframe");
+  frame.src = %s;
+  frame.title = %s;
+  frame.width = "100%%";
+  frame.height = "%d";
+  frame.loading = "lazy";
+  frame.style.border = "0";
+  script.parentNode.insertBefore(frame, script.nextSibling);
+})();
+`
+
+// RawSnippet sends a snippet's code as plain text. Multi-file snippets send
+// their entry point, or the file named by ?file=. Reading counts as a view.
+func (h *Handler) RawSnippet(c *gin.Context) {
+	file, ok := h.snippetFile(c, "read")
+	if !ok {
+		return
+	}
+
+	// Never let browsers render user code as anything but text
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Content-Security-Policy", "default-src 'none'; sandbox")
+	c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(file.Content))
+}
+
+// DownloadSnippet sends a snippet's code, or the file named by ?file=, as an
+// attachment named with the language's file extension. Downloading counts
+// as a view.
+func (h *Handler) DownloadSnippet(c *gin.Context) {
+	file, ok := h.snippetFile(c, "download")
+	if !ok {
+		return
+	}
+
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": path.Base(file.Name)}))
+	c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(file.Content))
+}
+
+// snippetFile reads a snippet and picks its entry point or the file named
+// by ?file=, responding with an error if that fails
+func (h *Handler) snippetFile(c *gin.Context, action string) (models.SnippetFile, bool) {
+	snippet, err := h.Snippets.GetSnippet(c.Request.Context(), c.Param("id"), snippetAccess(c))
+	if err != nil {
+		respondSnippetError(c, err, action)
+		return models.SnippetFile{}, false
+	}
+
+	files := services.SnippetFiles(snippet)
+	name, named := c.GetQuery("file")
+	if !named {
+		return files[0], true
+	}
+	for _, file := range files {
+		if file.Name == name {
+			return file, true
+		}
+	}
+	respondError(c, http.StatusNotFound, "File not found", "NOT_FOUND")
+	return models.SnippetFile{}, false
+}
+
+// EmbedSnippet renders a snippet as an HTML page meant to be shown in an
+// iframe on other sites, including the output of its last run. ?theme= may
+// be light or dark. Viewing counts as a view.
+func (h *Handler) EmbedSnippet(c *gin.Context) {
+	theme, ok := embedTheme(c)
+	if !ok {
+		return
+	}
+
+	snippet, err := h.Snippets.GetSnippet(c.Request.Context(), c.Param("id"), snippetAccess(c))
+	if err != nil {
+		respondSnippetError(c, err, "embed")
+		return
+	}
+
+	files := services.SnippetFiles(snippet)
+	var output string
+	if snippet.LastRun != nil {
+		output = snippet.LastRun.Result.Output + snippet.LastRun.Result.Error
+	}
+	page := struct {
+		Title, Language, Theme, RawURL, Output string
+		Files                                  []models.SnippetFile
+		MultiFile                              bool
+	}{
+		Title:     snippetTitle(snippet.Title),
+		Language:  snippet.Language,
+		Theme:     theme,
+		RawURL:    h.snippetURL(c, snippet.ID) + "/raw",
+		Output:    output,
+		Files:     files,
+		MultiFile: len(files) > 1,
+	}
+
+	// Allow any site to frame the page but nothing to run inside it
+	c.Header("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; frame-ancestors *")
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(http.StatusOK)
+	if err := embedPage.Execute(c.Writer, page); err != nil {
+		slog.ErrorContext(c.Request.Context(), "snippet embed failed", "snippet_id", snippet.ID, "error", err)
+	}
+}
+
+// EmbedSnippetScript returns a script that shows the snippet in an iframe
+// where it is included, for pages that accept script tags but not iframes.
+// It does not count as a view; the iframe does.
+func (h *Handler) EmbedSnippetScript(c *gin.Context) {
+	theme, ok := embedTheme(c)
+	if !ok {
+		return
+	}
+
+	summary, err := h.Snippets.GetSnippetSummary(c.Request.Context(), c.Param("id"), snippetAccess(c))
+	if err != nil {
+		respondSnippetError(c, err, "embed")
+		return
+	}
+
+	src, _ := json.Marshal(h.embedURL(c, summary.ID, theme))
+	title, _ := json.Marshal(snippetTitle(summary.Title))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Data(http.StatusOK, "text/javascript; charset=utf-8", fmt.Appendf(nil, embedScript, src, title, embedHeight))
+}
+
+// OEmbed describes the snippet at ?url= in the oEmbed rich format so that
+// course pages and learning platforms can embed it. ?maxwidth= and
+// ?maxheight= bound the iframe size. It does not count as a view.
+func (h *Handler) OEmbed(c *gin.Context) {
+	if format := c.DefaultQuery("format", "json"); format != "json" {
+		respondError(c, http.StatusNotImplemented, "Only the json format is supported", "UNSUPPORTED_FORMAT")
+		return
+	}
+
+	var match []string
+	if target, err := url.Parse(c.Query("url")); err == nil {
+		match = oEmbedURLPattern.FindStringSubmatch(target.EscapedPath())
+	}
+	if match == nil {
+		respondError(c, http.StatusNotFound, "url is not a snippet URL", "NOT_FOUND")
+		return
+	}
+	width, okWidth := embedBound(c, "maxwidth", embedWidth)
+	height, okHeight := embedBound(c, "maxheight", embedHeight)
+	if !okWidth || !okHeight {
+		respondError(c, http.StatusBadRequest, "maxwidth and maxheight must be positive integers", "INVALID_INPUT")
+		return
+	}
+
+	summary, err := h.Snippets.GetSnippetSummary(c.Request.Context(), match[1], snippetAccess(c))
+	if err != nil {
+		respondSnippetError(c, err, "embed")
+		return
+	}
+
+	title := snippetTitle(summary.Title)
+	c.JSON(http.StatusOK, models.OEmbedResponse{
+		Version:      "1.0",
+		Type:         "rich",
+		Title:        title,
+		AuthorName:   summary.Owner,
+		ProviderName: oEmbedProvider,
+		ProviderURL:  h.baseURL(c),
+		HTML: fmt.Sprintf(`<iframe src="%s" width="%d" height="%d" title="%s" loading="lazy" style="border:0"></iframe>`,
+			html.EscapeString(h.embedURL(c, summary.ID, "")), width, height, html.EscapeString(title)),
+		Width:  width,
+		Height: height,
+	})
+}
+
+// embedTheme reads ?theme=, responding with an error if it is invalid
+func embedTheme(c *gin.Context) (string, bool) {
+	theme := c.DefaultQuery("theme", "light")
+	if theme != "light" && theme != "dark" {
+		respondError(c, http.StatusBadRequest, "theme must be light or dark", "INVALID_INPUT")
+		return "", false
+	}
+	return theme, true
+}
+
+// embedBound returns the default size, capped by the query parameter if
+// it is set
+func embedBound(c *gin.Context, param string, size int) (int, bool) {
+	value, set := c.GetQuery(param)
+	if !set {
+		return size, true
+	}
+	bound, err := strconv.Atoi(value)
+	if err != nil || bound < 1 {
+		return 0, false
+	}
+	return min(size, bound), true
+}
+
+// snippetTitle names untitled snippets
+func snippetTitle(title string) string {
+	if title == "" {
+		return "Untitled snippet"
+	}
+	return title
+}
+
+// baseURL returns the public base URL of the server: the configured one,
+// or else the one the request was made to
+func (h *Handler) baseURL(c *gin.Context) string {
+	if h.PublicURL != "" {
+		return strings.TrimSuffix(h.PublicURL, "/")
+	}
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+// snippetURL returns the absolute API URL of a snippet
+func (h *Handler) snippetURL(c *gin.Context, id string) string {
+	return h.baseURL(c) + "/api/v1/snippets/" + url.PathEscape(id)
+}
+
+// embedURL returns the absolute URL of a snippet's embed page
+func (h *Handler) embedURL(c *gin.Context, id, theme string) string {
+	embed := h.snippetURL(c, id) + "/embed"
+	if theme != "" && theme != "light" {
+		embed += "?theme=" + url.QueryEscape(theme)
+	}
+	return embed
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/online-compiler/backend/internal/logging"
+	"github.com/online-compiler/backend/internal/models"
+	"github.com/online-compiler/backend/internal/services"
+)
+
+// CreateSubmission handles asynchronous code execution requests
+func (h *Handler) CreateSubmission(c *gin.Context) {
+	var req models.ExecuteRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid request format", "INVALID_INPUT")
+		return
+	}
+
+	// Validate code size (max 64KB)
+	if len(req.Code) > 65536 {
+		respondError(c, http.StatusBadRequest, "Code exceeds maximum size of 64KB", "INVALID_INPUT")
+		return
+	}
+
+	if !h.Languages.Language(req.LanguageID).IsEnabled() {
+		respondError(c, http.StatusBadRequest, "Language is disabled", "LANGUAGE_DISABLED")
+		return
+	}
+
+	if req.CallbackURL != "" {
+		err := h.Webhooks.ValidateCallbackURL(req.CallbackURL)
+		if errors.Is(err, services.ErrWebhooksDisabled) {
+			respondError(c, http.StatusBadRequest, err.Error(), "WEBHOOKS_DISABLED")
+			return
+		}
+		if err != nil {
+			respondError(c, http.StatusBadRequest, err.Error(), "INVALID_INPUT")
+			return
+		}
+	}
+
+	// Asynchronous submissions are batch work unless asked otherwise
+	priority, err := services.ParsePriority(req.Priority, services.PriorityBatch)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid priority", "INVALID_INPUT")
+		return
+	}
+
+	// Background jobs would be cut off by the shutdown deadline
+	if h.shuttingDown.Load() {
+		respondError(c, http.StatusServiceUnavailable, "Server is shutting down", "SHUTTING_DOWN")
+		return
+	}
+
+	executor, _, err := h.Executors.ExecutorFor(req.LanguageID)
+	if err != nil {
+		respondError(c, http.StatusServiceUnavailable, "Execution backend unavailable", "BACKEND_UNAVAILABLE")
+		return
+	}
+
+	submission, err := h.Submissions.CreateSubmission(c.Request.Context(), executor, &req, priority, submissionCaller(c))
+	if respondQueueFull(c, err) {
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to create submission", "language_id", req.LanguageID, "error", err)
+		respondError(c, http.StatusServiceUnavailable, "Execution backend unavailable", "BACKEND_UNAVAILABLE")
+		return
+	}
+	slog.InfoContext(c.Request.Context(), "submission created", "submission_id", submission.ID, "language_id", req.LanguageID)
+
+	c.JSON(http.StatusAccepted, models.SubmissionResponse{
+		Success:       true,
+		SubmissionID:  submission.ID,
+		Status:        submission.Status,
+		QueuePosition: submission.QueuePosition,
+	})
+}
+
+// GetSubmission handles submission status and result retrieval
+func (h *Handler) GetSubmission(c *gin.Context) {
+	submission, err := h.Submissions.GetSubmission(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, "Submission not found", "NOT_FOUND")
+		return
+	}
+
+	c.JSON(http.StatusOK, submission)
+}
+
+// CancelSubmission handles cancellation of a running submission. Synchronous
+// executions can be cancelled using their X-Request-ID. Only the caller who
+// started a run may cancel it.
+func (h *Handler) CancelSubmission(c *gin.Context) {
+	id := c.Param("id")
+
+	err := h.Submissions.CancelSubmission(id, submissionCaller(c))
+	switch {
+	case errors.Is(err, services.ErrSubmissionNotFound):
+		respondError(c, http.StatusNotFound, "Submission not found", "NOT_FOUND")
+		return
+	case errors.Is(err, services.ErrSubmissionForbidden):
+		respondError(c, http.StatusForbidden, "Only the caller who started a submission may cancel it", "FORBIDDEN")
+		return
+	case errors.Is(err, services.ErrSubmissionFinished):
+		respondError(c, http.StatusConflict, "Submission already finished", "CONFLICT")
+		return
+	}
+
+	slog.InfoContext(c.Request.Context(), "submission cancelled", "submission_id", id)
+	c.JSON(http.StatusOK, models.SubmissionResponse{
+		Success:      true,
+		SubmissionID: id,
+		Status:       services.SubmissionCancelled,
+	})
+}
+
+// GetSubmissionDeliveries lists the webhook delivery attempts of a
+// submission
+func (h *Handler) GetSubmissionDeliveries(c *gin.Context) {
+	deliveries, err := h.Webhooks.Deliveries(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to list webhook deliveries", "submission_id", c.Param("id"), "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to list deliveries", "INTERNAL_ERROR")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.WebhookDeliveriesResponse{
+		Success:    true,
+		Deliveries: deliveries,
+	})
+}
+
+// trackExecution registers a synchronous execution under the request ID so
+// the caller can cancel it. It responds with 409 and reports false if the
+// caller is already running an execution with that ID.
+func (h *Handler) trackExecution(c *gin.Context, languageID int) (context.Context, func(), bool) {
+	ctx, done, err := h.Submissions.TrackExecution(c.Request.Context(), submissionCaller(c), logging.RequestID(c.Request.Context()), languageID)
+	if err != nil {
+		respondError(c, http.StatusConflict, "Request ID is already used by a running execution", "REQUEST_ID_IN_USE")
+		return nil, nil, false
+	}
+	return ctx, done, true
+}
+
+// submissionCaller identifies who starts or cancels a run: the signed-in
+// user, or else the client's address
+func submissionCaller(c *gin.Context) string {
+	if user := services.UserFrom(c.Request.Context()); user != "" {
+		return "user:" + user
+	}
+	return "ip:" + c.ClientIP()
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/online-compiler/backend/internal/logging"
+	"github.com/online-compiler/backend/internal/models"
+	"github.com/online-compiler/backend/internal/services"
+)
+
+// AuthSettings resolves bearer tokens to user IDs
+type AuthSettings interface {
+	UserForToken(token string) (string, bool)
+}
+
+// AuthMiddleware attaches the user authenticated by an
+// "Authorization: Bearer <token>" header. Requests without the header stay
+// anonymous; unknown tokens are rejected.
+func AuthMiddleware(settings AuthSettings) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		if header == "" {
+			c.Next()
+			return
+		}
+
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		user, known := settings.UserForToken(strings.TrimSpace(token))
+		if !ok || !known {
+			slog.WarnContext(c.Request.Context(), "rejected bearer token", "client_ip", c.ClientIP())
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+				Success:   false,
+				Error:     "Invalid bearer token",
+				Code:      "UNAUTHORIZED",
+				RequestID: logging.RequestID(c.Request.Context()),
+			})
+			c.Abort()
+			return
+		}
+
+		c.Request = c.Request.WithContext(services.WithUser(c.Request.Context(), user))
+		c.Next()
+	}
+}
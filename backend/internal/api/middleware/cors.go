@@ -2,17 +2,21 @@ package middleware
 
 import (
 	"github.com/gin-gonic/gin"
-	"github.com/online-compiler/backend/configs"
 )
 
+// OriginSettings provides the allowed CORS origins
+type OriginSettings interface {
+	Origins() []string
+}
+
 // CORSMiddleware handles CORS
-func CORSMiddleware() gin.HandlerFunc {
+func CORSMiddleware(settings OriginSettings) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		origin := c.Request.Header.Get("Origin")
 
 		// Check if origin is allowed
 		allowed := false
-		for _, allowedOrigin := range configs.AppConfig.AllowedOrigins {
+		for _, allowedOrigin := range settings.Origins() {
 			if origin == allowedOrigin || allowedOrigin == "*" {
 				allowed = true
 				break
@@ -22,7 +26,8 @@ func CORSMiddleware() gin.HandlerFunc {
 		if allowed {
 			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
 			c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-			c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+			c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-Request-ID, X-Edit-Token, X-Snippet-Password")
+			c.Writer.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
 			c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
 		}
 
@@ -1,7 +1,7 @@
 package middleware
 
 import (
-	"log"
+	"log/slog"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -16,17 +16,25 @@ func LoggerMiddleware() gin.HandlerFunc {
 
 		c.Next()
 
-		latency := time.Since(start)
 		status := c.Writer.Status()
-		clientIP := c.ClientIP()
+		level := slog.LevelInfo
+		if status >= 500 {
+			level = slog.LevelError
+		} else if status >= 400 {
+			level = slog.LevelWarn
+		}
 
-		log.Printf("[%s] %s %s | %d | %v | %s",
-			method,
-			path,
-			clientIP,
-			status,
-			latency,
-			c.Errors.String(),
-		)
+		attrs := []slog.Attr{
+			slog.String("method", method),
+			slog.String("path", path),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("client_ip", c.ClientIP()),
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("errors", c.Errors.String()))
+		}
+
+		slog.LogAttrs(c.Request.Context(), level, "request completed", attrs...)
 	}
 }
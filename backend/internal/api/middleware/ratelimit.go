@@ -1,34 +1,42 @@
 package middleware
 
 import (
+	"log/slog"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/online-compiler/backend/internal/logging"
+	"github.com/online-compiler/backend/internal/metrics"
 	"github.com/online-compiler/backend/internal/models"
 	"github.com/online-compiler/backend/internal/services"
 )
 
 // RateLimitMiddleware implements rate limiting
-func RateLimitMiddleware() gin.HandlerFunc {
+func RateLimitMiddleware(limiter services.RateLimiter) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ip := c.ClientIP()
 
-		allowed, err := services.CheckRateLimit(ip)
+		allowed, err := limiter.CheckRateLimit(c.Request.Context(), ip)
 		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "rate limit check failed", "client_ip", ip, "error", err)
 			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-				Success: false,
-				Error:   "Rate limit check failed",
-				Code:    "INTERNAL_ERROR",
+				Success:   false,
+				Error:     "Rate limit check failed",
+				Code:      "INTERNAL_ERROR",
+				RequestID: logging.RequestID(c.Request.Context()),
 			})
 			c.Abort()
 			return
 		}
 
 		if !allowed {
+			metrics.RateLimitRejections.Inc()
+			slog.WarnContext(c.Request.Context(), "rate limit exceeded", "client_ip", ip)
 			c.JSON(http.StatusTooManyRequests, models.ErrorResponse{
-				Success: false,
-				Error:   "Rate limit exceeded. Please try again later.",
-				Code:    "RATE_LIMIT_EXCEEDED",
+				Success:   false,
+				Error:     "Rate limit exceeded. Please try again later.",
+				Code:      "RATE_LIMIT_EXCEEDED",
+				RequestID: logging.RequestID(c.Request.Context()),
 			})
 			c.Abort()
 			return
@@ -4,28 +4,72 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/online-compiler/backend/internal/api/handlers"
 	"github.com/online-compiler/backend/internal/api/middleware"
+	"github.com/online-compiler/backend/internal/services"
+	"github.com/online-compiler/backend/internal/tracing"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
+// RouterSettings provides the reloadable settings used by middleware
+type RouterSettings interface {
+	middleware.OriginSettings
+	middleware.AuthSettings
+}
+
 // SetupRouter configures all routes
-func SetupRouter() *gin.Engine {
-	router := gin.Default()
+func SetupRouter(h *handlers.Handler, limiter services.RateLimiter, settings RouterSettings) *gin.Engine {
+	router := gin.New()
 
 	// Apply middleware
-	router.Use(middleware.CORSMiddleware())
+	router.Use(gin.Recovery())
+	router.Use(middleware.RequestIDMiddleware())
+	router.Use(otelgin.Middleware(tracing.ServiceName))
+	router.Use(middleware.CORSMiddleware(settings))
 	router.Use(middleware.LoggerMiddleware())
+	router.Use(middleware.MetricsMiddleware())
+
+	// Prometheus metrics
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
+	rateLimit := middleware.RateLimitMiddleware(limiter)
 
 	// API v1 routes
-	v1 := router.Group("/api/v1")
+	v1 := router.Group("/api/v1", middleware.AuthMiddleware(settings))
 	{
 		// Health check
-		v1.GET("/health", handlers.HealthCheck)
+		v1.GET("/health", h.HealthCheck)
+		v1.GET("/ready", h.ReadinessCheck)
+
+		// Code execution (with rate limiting) - backend chosen per language
+		v1.POST("/execute", rateLimit, h.ExecuteCode)
+		v1.POST("/execute/batch", rateLimit, h.ExecuteBatch)
+		v1.POST("/execute/project", rateLimit, h.ExecuteProject)
 
-		// Code execution (with rate limiting) - Using Piston
-		v1.POST("/execute", middleware.RateLimitMiddleware(), handlers.ExecuteCodePiston)
+		// Asynchronous submissions
+		v1.POST("/submissions", rateLimit, h.CreateSubmission)
+		v1.GET("/submissions/:id", h.GetSubmission)
+		v1.DELETE("/submissions/:id", h.CancelSubmission)
+		v1.GET("/submissions/:id/deliveries", h.GetSubmissionDeliveries)
 
 		// Snippet management
-		v1.POST("/snippets", handlers.CreateSnippet)
-		v1.GET("/snippets/:id", handlers.GetSnippet)
+		v1.POST("/snippets", h.CreateSnippet)
+		v1.GET("/snippets", h.ListSnippets)
+		v1.GET("/snippets/:id", h.GetSnippet)
+		v1.PUT("/snippets/:id", h.UpdateSnippet)
+		v1.DELETE("/snippets/:id", h.DeleteSnippet)
+		v1.GET("/snippets/:id/revisions", h.ListSnippetRevisions)
+		v1.GET("/snippets/:id/revisions/:rev", h.GetSnippetRevision)
+		v1.GET("/snippets/:id/diff", h.DiffSnippetRevisions)
+		v1.POST("/snippets/:id/fork", h.ForkSnippet)
+		v1.GET("/snippets/:id/forks", h.ListSnippetForks)
+		v1.GET("/snippets/:id/archive", h.DownloadSnippetArchive)
+		v1.POST("/snippets/:id/run", rateLimit, h.RunSnippet)
+		v1.GET("/snippets/:id/stats", h.GetSnippetStats)
+		v1.GET("/snippets/:id/raw", h.RawSnippet)
+		v1.GET("/snippets/:id/download", h.DownloadSnippet)
+		v1.GET("/snippets/:id/embed", h.EmbedSnippet)
+		v1.GET("/snippets/:id/embed.js", h.EmbedSnippetScript)
+		v1.GET("/oembed", h.OEmbed)
 	}
 
 	return router
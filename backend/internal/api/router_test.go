@@ -0,0 +1,1385 @@
+package api_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/online-compiler/backend/configs"
+	"github.com/online-compiler/backend/internal/app"
+	"github.com/online-compiler/backend/internal/models"
+	"github.com/online-compiler/backend/internal/services"
+	"github.com/online-compiler/backend/internal/testutil"
+)
+
+const (
+	python = 71
+	cpp    = 54
+)
+
+// useBackend routes languageID to backend
+func useBackend(languageID int, backend string) func(cfg *configs.Config) {
+	return func(cfg *configs.Config) {
+		if cfg.Languages == nil {
+			cfg.Languages = make(map[string]configs.LanguageConfig)
+		}
+		key := strconv.Itoa(languageID)
+		lang := cfg.Languages[key]
+		lang.Backend = backend
+		cfg.Languages[key] = lang
+	}
+}
+
+// request sends a request through the router and returns the recorder
+func request(t *testing.T, ta *testutil.TestApp, method, path string, body interface{}, headers ...string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var reader *bytes.Reader
+	switch b := body.(type) {
+	case nil:
+		reader = bytes.NewReader(nil)
+	case string:
+		reader = bytes.NewReader([]byte(b))
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			t.Fatalf("failed to encode body: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	w := httptest.NewRecorder()
+	ta.Router.ServeHTTP(w, req)
+	return w
+}
+
+// decode unmarshals a response body into v
+func decode(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("failed to decode response %q: %v", w.Body.String(), err)
+	}
+}
+
+// expectError checks the status and error code of an error response
+func expectError(t *testing.T, w *httptest.ResponseRecorder, status int, code string) {
+	t.Helper()
+	if w.Code != status {
+		t.Fatalf("status = %d, want %d (body %s)", w.Code, status, w.Body.String())
+	}
+	var resp models.ErrorResponse
+	decode(t, w, &resp)
+	if resp.Success || resp.Code != code {
+		t.Fatalf("error response = %+v, want code %s", resp, code)
+	}
+	if resp.RequestID == "" {
+		t.Errorf("error response has no request_id")
+	}
+}
+
+func execute(t *testing.T, ta *testutil.TestApp, body interface{}) models.ExecuteResponse {
+	t.Helper()
+	w := request(t, ta, http.MethodPost, "/api/v1/execute", body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("execute status = %d (body %s)", w.Code, w.Body.String())
+	}
+	var resp models.ExecuteResponse
+	decode(t, w, &resp)
+	return resp
+}
+
+// waitForSubmission polls until the submission finishes
+func waitForSubmission(t *testing.T, ta *testutil.TestApp, id string) models.Submission {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		w := request(t, ta, http.MethodGet, "/api/v1/submissions/"+id, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("get submission status = %d (body %s)", w.Code, w.Body.String())
+		}
+		var submission models.Submission
+		decode(t, w, &submission)
+		if submission.FinishedAt != nil {
+			return submission
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("submission %s did not finish", id)
+	return models.Submission{}
+}
+
+// failingExecutor always returns an error
+type failingExecutor struct{}
+
+func (failingExecutor) ExecuteCode(ctx context.Context, languageID int, code, stdin string) (*models.ExecuteResponse, error) {
+	return nil, context.DeadlineExceeded
+}
+
+func TestHealthCheck(t *testing.T) {
+	ta := testutil.NewTestApp(t)
+
+	w := request(t, ta, http.MethodGet, "/api/v1/health", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d", w.Code)
+	}
+
+	var resp models.HealthResponse
+	decode(t, w, &resp)
+	want := models.HealthResponse{Status: "healthy", Redis: "connected", Database: "connected", Judge0: "available"}
+	if resp != want {
+		t.Errorf("health = %+v, want %+v", resp, want)
+	}
+}
+
+func TestHealthCheckDegraded(t *testing.T) {
+	ta := testutil.NewTestApp(t)
+	ta.Judge0.FailSubmissions(http.StatusInternalServerError)
+	ta.Redis.Close()
+
+	var resp models.HealthResponse
+	decode(t, request(t, ta, http.MethodGet, "/api/v1/health", nil), &resp)
+	if resp.Redis != "disconnected" || resp.Judge0 != "unavailable" {
+		t.Errorf("health = %+v, want redis disconnected and judge0 unavailable", resp)
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	ta := testutil.NewTestApp(t)
+
+	if w := request(t, ta, http.MethodGet, "/api/v1/ready", nil); w.Code != http.StatusOK {
+		t.Fatalf("ready status = %d", w.Code)
+	}
+
+	ta.MarkShuttingDown()
+
+	w := request(t, ta, http.MethodGet, "/api/v1/ready", nil)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("ready status after shutdown = %d", w.Code)
+	}
+
+	body := models.ExecuteRequest{LanguageID: python, Code: "print(1)"}
+	expectError(t, request(t, ta, http.MethodPost, "/api/v1/submissions", body), http.StatusServiceUnavailable, "SHUTTING_DOWN")
+}
+
+func TestMetrics(t *testing.T) {
+	ta := testutil.NewTestApp(t)
+	request(t, ta, http.MethodGet, "/api/v1/ready", nil)
+
+	w := request(t, ta, http.MethodGet, "/metrics", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), `compiler_http_requests_total{method="GET",route="/api/v1/ready"`) {
+		t.Errorf("metrics do not include the ready request")
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	ta := testutil.NewTestApp(t)
+
+	w := request(t, ta, http.MethodGet, "/api/v1/ready", nil, "X-Request-ID", "test-request-1")
+	if got := w.Header().Get("X-Request-ID"); got != "test-request-1" {
+		t.Errorf("X-Request-ID = %q, want echoed value", got)
+	}
+
+	w = request(t, ta, http.MethodGet, "/api/v1/ready", nil, "X-Request-ID", "bad id\n")
+	if got := w.Header().Get("X-Request-ID"); got == "" || got == "bad id\n" {
+		t.Errorf("X-Request-ID = %q, want a generated ID", got)
+	}
+}
+
+func TestExecutePiston(t *testing.T) {
+	ta := testutil.NewTestApp(t)
+
+	resp := execute(t, ta, models.ExecuteRequest{LanguageID: python, Code: "print(input())", Stdin: "hello"})
+	if !resp.Success || resp.Output != "hello" {
+		t.Errorf("response = %+v, want echoed stdin", resp)
+	}
+	if ta.Piston.RequestCount() != 1 || ta.Judge0.SubmissionCount() != 0 {
+		t.Errorf("piston requests = %d, judge0 submissions = %d", ta.Piston.RequestCount(), ta.Judge0.SubmissionCount())
+	}
+	if got := ta.Piston.Requests[0].Language; got != "python" {
+		t.Errorf("piston language = %q, want python", got)
+	}
+}
+
+func TestExecutePistonFailures(t *testing.T) {
+	tests := []struct {
+		name    string
+		outcome testutil.PistonOutcome
+		want    string
+	}{
+		{"compile error", testutil.CompileFailure("main.cpp:1: error"), "main.cpp:1: error"},
+		{"runtime error", testutil.RunFailure(1, "segfault"), "segfault"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ta := testutil.NewTestApp(t)
+			ta.Piston.SetRespond(func(services.PistonRequest) testutil.PistonOutcome { return tt.outcome })
+
+			resp := execute(t, ta, models.ExecuteRequest{LanguageID: cpp, Code: "int main() {}"})
+			if !strings.Contains(resp.Error, tt.want) {
+				t.Errorf("error = %q, want %q", resp.Error, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecutePistonUnavailable(t *testing.T) {
+	ta := testutil.NewTestApp(t)
+	ta.Piston.FailWith(http.StatusBadGateway)
+
+	resp := execute(t, ta, models.ExecuteRequest{LanguageID: python, Code: "print(1)"})
+	if resp.Success || !strings.Contains(resp.Error, "Piston error") {
+		t.Errorf("response = %+v, want Piston error", resp)
+	}
+}
+
+func TestExecuteJudge0(t *testing.T) {
+	tests := []struct {
+		name       string
+		outcome    testutil.Judge0Outcome
+		wantStatus string
+		wantOutput string
+		wantError  string
+	}{
+		{"accepted", testutil.Accepted("42\n"), "Accepted", "42\n", ""},
+		{"compilation error", testutil.CompilationError("expected ';'"), "Compilation Error", "", "expected ';'"},
+		{"time limit exceeded", testutil.TimeLimitExceeded(), "Time Limit Exceeded", "", "Time limit exceeded"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ta := testutil.NewTestApp(t, useBackend(cpp, "judge0"))
+			ta.Judge0.SetRespond(func(models.Judge0Submission) testutil.Judge0Outcome { return tt.outcome })
+
+			resp := execute(t, ta, models.ExecuteRequest{LanguageID: cpp, Code: "int main() {}"})
+			if resp.Status != tt.wantStatus || resp.Output != tt.wantOutput || resp.Error != tt.wantError {
+				t.Errorf("response = %+v, want status %q output %q error %q", resp, tt.wantStatus, tt.wantOutput, tt.wantError)
+			}
+			// In Queue and Processing are reported before the final status
+			if polls := ta.Judge0.PollCount(); polls != 3 {
+				t.Errorf("polls = %d, want 3", polls)
+			}
+		})
+	}
+}
+
+func TestExecuteJudge0Limits(t *testing.T) {
+	ta := testutil.NewTestApp(t, useBackend(cpp, "judge0"), func(cfg *configs.Config) {
+		lang := cfg.Languages["54"]
+		lang.TimeLimit = 2
+		lang.MemoryLimitKB = 65536
+		lang.CompilerFlags = "-O2"
+		cfg.Languages["54"] = lang
+	})
+
+	execute(t, ta, models.ExecuteRequest{LanguageID: cpp, Code: "int main() {}"})
+
+	sub := ta.Judge0.Submitted[0]
+	if sub.CPUTimeLimit != 2 || sub.MemoryLimit != 65536 || sub.CompilerOptions != "-O2" {
+		t.Errorf("submission = %+v, want configured limits", sub)
+	}
+}
+
+func TestExecuteJudge0SubmitFailure(t *testing.T) {
+	ta := testutil.NewTestApp(t, useBackend(cpp, "judge0"))
+	ta.Judge0.FailSubmissions(http.StatusServiceUnavailable)
+
+	resp := execute(t, ta, models.ExecuteRequest{LanguageID: cpp, Code: "int main() {}"})
+	if resp.Success || !strings.Contains(resp.Error, "Judge0 submission failed") {
+		t.Errorf("response = %+v, want submission failure", resp)
+	}
+}
+
+// executeBatch posts a batch execution and decodes the response
+func executeBatch(t *testing.T, ta *testutil.TestApp, body models.BatchExecuteRequest) models.BatchExecuteResponse {
+	t.Helper()
+	w := request(t, ta, http.MethodPost, "/api/v1/execute/batch", body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("batch execute status = %d (body %s)", w.Code, w.Body.String())
+	}
+	var resp models.BatchExecuteResponse
+	decode(t, w, &resp)
+	return resp
+}
+
+// testCases builds n echo test cases; every third expects the wrong output
+func testCases(n int) ([]models.TestCase, int) {
+	cases := make([]models.TestCase, n)
+	passing := 0
+	for i := range cases {
+		stdin := strconv.Itoa(i)
+		expected := stdin
+		if i%3 == 2 {
+			expected = "wrong"
+		} else {
+			passing++
+		}
+		cases[i] = models.TestCase{Stdin: stdin, ExpectedOutput: &expected}
+	}
+	return cases, passing
+}
+
+func TestExecuteBatchJudge0(t *testing.T) {
+	ta := testutil.NewTestApp(t, useBackend(cpp, "judge0"))
+
+	// More test cases than fit in one Judge0 batch
+	cases, passing := testCases(25)
+	resp := executeBatch(t, ta, models.BatchExecuteRequest{LanguageID: cpp, Code: "int main() {}", TestCases: cases})
+
+	if len(resp.Results) != 25 || resp.Total != 25 || resp.Passed != passing {
+		t.Fatalf("response = %d results, %d/%d passed, want %d/25", len(resp.Results), resp.Passed, resp.Total, passing)
+	}
+	for i, result := range resp.Results {
+		if result.Output != strconv.Itoa(i) {
+			t.Errorf("result %d output = %q, want results in test case order", i, result.Output)
+		}
+		if wantPassed := i%3 != 2; *result.Passed != wantPassed {
+			t.Errorf("result %d passed = %v (status %q), want %v", i, *result.Passed, result.Status, wantPassed)
+		}
+	}
+	if ta.Judge0.Submitted[2].ExpectedOutput != "wrong" {
+		t.Errorf("expected output was not sent to Judge0")
+	}
+	// In Queue, Processing and the final status, for all tokens at once
+	if polls := ta.Judge0.PollCount(); polls != 3 {
+		t.Errorf("polls = %d, want 3 batch polls", polls)
+	}
+}
+
+func TestExecuteBatchSequentialFallback(t *testing.T) {
+	ta := testutil.NewTestApp(t)
+
+	cases, passing := testCases(4)
+	cases = append(cases, models.TestCase{Stdin: "no expectation"})
+	resp := executeBatch(t, ta, models.BatchExecuteRequest{LanguageID: python, Code: "print(input())", TestCases: cases})
+
+	if len(resp.Results) != 5 || resp.Total != 4 || resp.Passed != passing {
+		t.Errorf("response = %+v, want %d/4 passed", resp, passing)
+	}
+	if resp.Results[4].Passed != nil {
+		t.Errorf("test case without expected output was judged")
+	}
+	if ta.Piston.RequestCount() != 5 {
+		t.Errorf("piston requests = %d, want 5", ta.Piston.RequestCount())
+	}
+}
+
+func TestExecuteBatchValidation(t *testing.T) {
+	ta := testutil.NewTestApp(t)
+
+	tooMany, _ := testCases(101)
+	tests := []struct {
+		name string
+		body models.BatchExecuteRequest
+	}{
+		{"no test cases", models.BatchExecuteRequest{LanguageID: python, Code: "print(1)"}},
+		{"too many test cases", models.BatchExecuteRequest{LanguageID: python, Code: "print(1)", TestCases: tooMany}},
+		{"invalid priority", models.BatchExecuteRequest{LanguageID: python, Code: "print(1)", TestCases: tooMany[:1], Priority: "urgent"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := request(t, ta, http.MethodPost, "/api/v1/execute/batch", tt.body)
+			expectError(t, w, http.StatusBadRequest, "INVALID_INPUT")
+		})
+	}
+}
+
+func TestExecuteProject(t *testing.T) {
+	ta := testutil.NewTestApp(t, useBackend(cpp, "judge0"))
+
+	files := []models.SnippetFile{
+		{Name: "main.py", Content: "from lib.greet import hello\nhello()"},
+		{Name: "lib/greet.py", Content: "def hello(): print('hi')"},
+	}
+	w := request(t, ta, http.MethodPost, "/api/v1/execute/project", models.ProjectExecuteRequest{LanguageID: python, Files: files, Stdin: "in"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d (body %s)", w.Code, w.Body.String())
+	}
+	var resp models.ExecuteResponse
+	decode(t, w, &resp)
+	if !resp.Success || resp.Output != "in" {
+		t.Errorf("response = %+v", resp)
+	}
+	sent := ta.Piston.Requests[0].Files
+	if len(sent) != 2 || sent[0].Name != "main.py" || sent[1].Name != "lib/greet.py" || sent[1].Content != files[1].Content {
+		t.Errorf("piston files = %+v, want the project in order", sent)
+	}
+
+	// Judge0 only runs single files
+	w = request(t, ta, http.MethodPost, "/api/v1/execute/project", models.ProjectExecuteRequest{LanguageID: cpp, Files: files})
+	expectError(t, w, http.StatusBadRequest, "MULTI_FILE_UNSUPPORTED")
+	w = request(t, ta, http.MethodPost, "/api/v1/execute/project", models.ProjectExecuteRequest{LanguageID: cpp, Files: files[:1]})
+	if w.Code != http.StatusOK || ta.Judge0.SubmissionCount() != 1 {
+		t.Errorf("single-file project status = %d, judge0 submissions = %d", w.Code, ta.Judge0.SubmissionCount())
+	}
+
+	for _, name := range []string{"", "../main.py", "/main.py", "lib/../main.py", "main.py"} {
+		invalid := []models.SnippetFile{{Name: "main.py"}, {Name: name}}
+		w = request(t, ta, http.MethodPost, "/api/v1/execute/project", models.ProjectExecuteRequest{LanguageID: python, Files: invalid})
+		expectError(t, w, http.StatusBadRequest, "INVALID_INPUT")
+	}
+}
+
+func TestExecuteValidation(t *testing.T) {
+	ta := testutil.NewTestApp(t, func(cfg *configs.Config) {
+		disabled := false
+		cfg.Languages = map[string]configs.LanguageConfig{"72": {Enabled: &disabled}}
+	})
+
+	tests := []struct {
+		name string
+		body interface{}
+		code string
+	}{
+		{"invalid json", `{"language_id":`, "INVALID_INPUT"},
+		{"missing code", map[string]int{"language_id": python}, "INVALID_INPUT"},
+		{"code too large", models.ExecuteRequest{LanguageID: python, Code: strings.Repeat("x", 65537)}, "INVALID_INPUT"},
+		{"language too low", models.ExecuteRequest{LanguageID: -1, Code: "x"}, "INVALID_INPUT"},
+		{"language too high", models.ExecuteRequest{LanguageID: 101, Code: "x"}, "INVALID_INPUT"},
+		{"language disabled", models.ExecuteRequest{LanguageID: 72, Code: "puts 1"}, "LANGUAGE_DISABLED"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expectError(t, request(t, ta, http.MethodPost, "/api/v1/execute", tt.body), http.StatusBadRequest, tt.code)
+		})
+	}
+
+	if ta.Piston.RequestCount() != 0 {
+		t.Errorf("invalid requests reached Piston")
+	}
+}
+
+func TestExecuteBackendUnavailable(t *testing.T) {
+	ta := testutil.NewTestApp(t, useBackend(python, "missing"))
+
+	body := models.ExecuteRequest{LanguageID: python, Code: "print(1)"}
+	expectError(t, request(t, ta, http.MethodPost, "/api/v1/execute", body), http.StatusServiceUnavailable, "BACKEND_UNAVAILABLE")
+	expectError(t, request(t, ta, http.MethodPost, "/api/v1/submissions", body), http.StatusServiceUnavailable, "BACKEND_UNAVAILABLE")
+}
+
+func TestExecuteExecutorError(t *testing.T) {
+	ta := testutil.NewTestApp(t)
+	ta.Executors.Register("piston", failingExecutor{})
+
+	body := models.ExecuteRequest{LanguageID: python, Code: "print(1)"}
+	expectError(t, request(t, ta, http.MethodPost, "/api/v1/execute", body), http.StatusInternalServerError, "EXECUTION_ERROR")
+}
+
+func TestExecuteCache(t *testing.T) {
+	ta := testutil.NewTestApp(t)
+	body := models.ExecuteRequest{LanguageID: python, Code: "print(input())", Stdin: "cached"}
+
+	first := execute(t, ta, body)
+	second := execute(t, ta, body)
+	if first != second {
+		t.Errorf("cached response = %+v, want %+v", second, first)
+	}
+	if n := ta.Piston.RequestCount(); n != 1 {
+		t.Errorf("piston requests = %d, want 1", n)
+	}
+
+	// Different stdin is a different cache entry
+	execute(t, ta, models.ExecuteRequest{LanguageID: python, Code: body.Code, Stdin: "other"})
+	if n := ta.Piston.RequestCount(); n != 2 {
+		t.Errorf("piston requests = %d, want 2", n)
+	}
+}
+
+func TestExecuteCacheFollowsLanguageConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("languages: {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ta := testutil.NewTestApp(t, func(cfg *configs.Config) { cfg.ConfigFile = path })
+	body := models.ExecuteRequest{LanguageID: python, Code: "print(1)"}
+
+	execute(t, ta, body)
+	execute(t, ta, body)
+	if count := ta.Piston.RequestCount(); count != 1 {
+		t.Fatalf("%d Piston requests, want the repeat served from cache", count)
+	}
+
+	// Results from before a reload that changed the language are not reused
+	if err := os.WriteFile(path, []byte("languages:\n  \"71\":\n    args: [\"-u\"]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ta.Config.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	execute(t, ta, body)
+	if count := ta.Piston.RequestCount(); count != 2 {
+		t.Errorf("%d Piston requests after the reload, want 2", count)
+	}
+}
+
+func TestExecuteWithoutRedis(t *testing.T) {
+	ta := testutil.NewTestApp(t)
+	ta.Redis.Close()
+
+	resp := execute(t, ta, models.ExecuteRequest{LanguageID: python, Code: "print(1)", Stdin: "ok"})
+	if !resp.Success || resp.Output != "ok" {
+		t.Errorf("response = %+v, want execution despite Redis being down", resp)
+	}
+}
+
+func TestExecuteCancelByRequestID(t *testing.T) {
+	ta := testutil.NewTestApp(t)
+	// Cached, so reusing the request ID below does not wait for Piston
+	execute(t, ta, models.ExecuteRequest{LanguageID: python, Code: "print(1)"})
+	ta.Piston.SetDelay(5 * time.Second)
+
+	done := make(chan models.ExecuteResponse)
+	go func() {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/execute", strings.NewReader(`{"language_id":71,"code":"while True: pass"}`))
+		req.Header.Set("X-Request-ID", "slow-request")
+		ta.Router.ServeHTTP(w, req)
+
+		var resp models.ExecuteResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		done <- resp
+	}()
+
+	// The execution is registered before it reaches Piston
+	deadline := time.Now().Add(2 * time.Second)
+	for ta.Piston.RequestCount() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("execution never reached Piston")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// The request ID cannot be reused while the execution runs
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/execute", strings.NewReader(`{"language_id":71,"code":"print(1)"}`))
+	req.Header.Set("X-Request-ID", "slow-request")
+	w := httptest.NewRecorder()
+	ta.Router.ServeHTTP(w, req)
+	expectError(t, w, http.StatusConflict, "REQUEST_ID_IN_USE")
+
+	// Request IDs only name the caller's own executions
+	other := httptest.NewRequest(http.MethodDelete, "/api/v1/submissions/slow-request", nil)
+	other.RemoteAddr = "198.51.100.7:1234"
+	w = httptest.NewRecorder()
+	ta.Router.ServeHTTP(w, other)
+	expectError(t, w, http.StatusNotFound, "NOT_FOUND")
+
+	if w := request(t, ta, http.MethodDelete, "/api/v1/submissions/slow-request", nil); w.Code != http.StatusOK {
+		t.Fatalf("cancel status = %d (body %s)", w.Code, w.Body.String())
+	}
+
+	select {
+	case resp := <-done:
+		if resp.Status != "Cancelled" {
+			t.Errorf("response = %+v, want cancelled", resp)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("cancelled execution did not return")
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	ta := testutil.NewTestApp(t, func(cfg *configs.Config) {
+		cfg.RateLimitRequests = 2
+		cfg.RateLimitWindow = 60
+	})
+	body := models.ExecuteRequest{LanguageID: python, Code: "print(1)"}
+
+	for i := 0; i < 2; i++ {
+		if w := request(t, ta, http.MethodPost, "/api/v1/execute", body); w.Code != http.StatusOK {
+			t.Fatalf("request %d status = %d", i+1, w.Code)
+		}
+	}
+	expectError(t, request(t, ta, http.MethodPost, "/api/v1/execute", body), http.StatusTooManyRequests, "RATE_LIMIT_EXCEEDED")
+	expectError(t, request(t, ta, http.MethodPost, "/api/v1/submissions", body), http.StatusTooManyRequests, "RATE_LIMIT_EXCEEDED")
+
+	// Other routes are not limited
+	if w := request(t, ta, http.MethodGet, "/api/v1/health", nil); w.Code != http.StatusOK {
+		t.Errorf("health status = %d while rate limited", w.Code)
+	}
+
+	// The window expiring resets the counter
+	ta.Redis.FastForward(61 * time.Second)
+	if w := request(t, ta, http.MethodPost, "/api/v1/execute", body); w.Code != http.StatusOK {
+		t.Errorf("status after window = %d", w.Code)
+	}
+}
+
+func TestSubmissionLifecycle(t *testing.T) {
+	ta := testutil.NewTestApp(t)
+
+	w := request(t, ta, http.MethodPost, "/api/v1/submissions", models.ExecuteRequest{LanguageID: python, Code: "print(input())", Stdin: "async"})
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d (body %s)", w.Code, w.Body.String())
+	}
+	var created models.SubmissionResponse
+	decode(t, w, &created)
+	if created.SubmissionID == "" || created.Status != services.SubmissionQueued {
+		t.Fatalf("created = %+v", created)
+	}
+
+	submission := waitForSubmission(t, ta, created.SubmissionID)
+	if submission.Status != services.SubmissionCompleted || submission.Result == nil || submission.Result.Output != "async" {
+		t.Errorf("submission = %+v, want completed with output", submission)
+	}
+
+	expectError(t, request(t, ta, http.MethodDelete, "/api/v1/submissions/"+created.SubmissionID, nil), http.StatusConflict, "CONFLICT")
+}
+
+func TestSubmissionQueuePosition(t *testing.T) {
+	ta := testutil.NewTestApp(t, func(cfg *configs.Config) {
+		cfg.WorkerCount = 1
+	})
+	ta.Piston.SetDelay(5 * time.Second)
+
+	var first, second models.SubmissionResponse
+	decode(t, request(t, ta, http.MethodPost, "/api/v1/submissions", models.ExecuteRequest{LanguageID: python, Code: "first"}), &first)
+	decode(t, request(t, ta, http.MethodPost, "/api/v1/submissions", models.ExecuteRequest{LanguageID: python, Code: "second"}), &second)
+
+	// The first submission occupies the only worker
+	deadline := time.Now().Add(5 * time.Second)
+	var submission models.Submission
+	for {
+		decode(t, request(t, ta, http.MethodGet, "/api/v1/submissions/"+second.SubmissionID, nil), &submission)
+		if submission.Status == services.SubmissionQueued && submission.QueuePosition == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("second submission = %+v, want queued at position 1", submission)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// Cancelling a queued submission removes it without running it
+	request(t, ta, http.MethodDelete, "/api/v1/submissions/"+second.SubmissionID, nil)
+	if submission := waitForSubmission(t, ta, second.SubmissionID); submission.Status != services.SubmissionCancelled {
+		t.Errorf("second submission = %+v, want cancelled", submission)
+	}
+	request(t, ta, http.MethodDelete, "/api/v1/submissions/"+first.SubmissionID, nil)
+	waitForSubmission(t, ta, first.SubmissionID)
+
+	if count := ta.Piston.RequestCount(); count != 1 {
+		t.Errorf("piston requests = %d, want 1", count)
+	}
+}
+
+func TestExecuteQueueFull(t *testing.T) {
+	ta := testutil.NewTestApp(t, func(cfg *configs.Config) {
+		cfg.WorkerCount = 1
+		cfg.QueueDepth = 0
+	})
+	ta.Piston.SetDelay(5 * time.Second)
+
+	var busy models.SubmissionResponse
+	decode(t, request(t, ta, http.MethodPost, "/api/v1/submissions", models.ExecuteRequest{LanguageID: python, Code: "busy"}), &busy)
+	defer func() {
+		request(t, ta, http.MethodDelete, "/api/v1/submissions/"+busy.SubmissionID, nil)
+		waitForSubmission(t, ta, busy.SubmissionID)
+	}()
+
+	w := request(t, ta, http.MethodPost, "/api/v1/execute", models.ExecuteRequest{LanguageID: python, Code: "print(1)"})
+	expectError(t, w, http.StatusServiceUnavailable, "QUEUE_FULL")
+	if retryAfter := w.Header().Get("Retry-After"); retryAfter == "" || retryAfter == "0" {
+		t.Errorf("Retry-After = %q", retryAfter)
+	}
+
+	expectError(t, request(t, ta, http.MethodPost, "/api/v1/execute", models.ExecuteRequest{LanguageID: python, Code: "x", Priority: "urgent"}), http.StatusBadRequest, "INVALID_INPUT")
+}
+
+func TestSubmissionWebhook(t *testing.T) {
+	ta := testutil.NewTestApp(t, func(cfg *configs.Config) {
+		cfg.WebhookSecret = "lms-secret"
+		cfg.WebhookPrivate = true
+	})
+
+	received := make(chan models.WebhookPayload, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload models.WebhookPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		received <- payload
+	}))
+	defer receiver.Close()
+
+	var created models.SubmissionResponse
+	decode(t, request(t, ta, http.MethodPost, "/api/v1/submissions", models.ExecuteRequest{
+		LanguageID: python, Code: "print(input())", Stdin: "pushed", CallbackURL: receiver.URL,
+	}), &created)
+
+	select {
+	case payload := <-received:
+		if payload.Submission.ID != created.SubmissionID || payload.Submission.Result == nil || payload.Submission.Result.Output != "pushed" {
+			t.Errorf("payload = %+v", payload)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("callback was not called")
+	}
+
+	// The attempt is logged once the receiver has answered
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var deliveries models.WebhookDeliveriesResponse
+		decode(t, request(t, ta, http.MethodGet, "/api/v1/submissions/"+created.SubmissionID+"/deliveries", nil), &deliveries)
+		if len(deliveries.Deliveries) == 1 && deliveries.Deliveries[0].Delivered {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("deliveries = %+v", deliveries)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	expectError(t, request(t, ta, http.MethodPost, "/api/v1/submissions", models.ExecuteRequest{
+		LanguageID: python, Code: "x", CallbackURL: "ftp://example.com/hook",
+	}), http.StatusBadRequest, "INVALID_INPUT")
+
+	// Callbacks cannot be signed without a secret
+	unsigned := testutil.NewTestApp(t)
+	expectError(t, request(t, unsigned, http.MethodPost, "/api/v1/submissions", models.ExecuteRequest{
+		LanguageID: python, Code: "x", CallbackURL: receiver.URL,
+	}), http.StatusBadRequest, "WEBHOOKS_DISABLED")
+}
+
+func TestSubmissionCancel(t *testing.T) {
+	ta := testutil.NewTestApp(t)
+	ta.Piston.SetDelay(5 * time.Second)
+
+	var created models.SubmissionResponse
+	decode(t, request(t, ta, http.MethodPost, "/api/v1/submissions", models.ExecuteRequest{LanguageID: python, Code: "while True: pass"}), &created)
+
+	// Only the caller who started the submission may cancel it
+	other := httptest.NewRequest(http.MethodDelete, "/api/v1/submissions/"+created.SubmissionID, nil)
+	other.RemoteAddr = "198.51.100.7:1234"
+	w := httptest.NewRecorder()
+	ta.Router.ServeHTTP(w, other)
+	expectError(t, w, http.StatusForbidden, "FORBIDDEN")
+
+	w = request(t, ta, http.MethodDelete, "/api/v1/submissions/"+created.SubmissionID, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("cancel status = %d (body %s)", w.Code, w.Body.String())
+	}
+
+	submission := waitForSubmission(t, ta, created.SubmissionID)
+	if submission.Status != services.SubmissionCancelled {
+		t.Errorf("status = %q, want cancelled", submission.Status)
+	}
+}
+
+func TestSubmissionValidation(t *testing.T) {
+	ta := testutil.NewTestApp(t)
+
+	expectError(t, request(t, ta, http.MethodPost, "/api/v1/submissions", `not json`), http.StatusBadRequest, "INVALID_INPUT")
+	expectError(t, request(t, ta, http.MethodGet, "/api/v1/submissions/unknown", nil), http.StatusNotFound, "NOT_FOUND")
+	expectError(t, request(t, ta, http.MethodDelete, "/api/v1/submissions/unknown", nil), http.StatusNotFound, "NOT_FOUND")
+}
+
+func TestSnippets(t *testing.T) {
+	ta := testutil.NewTestApp(t)
+
+	w := request(t, ta, http.MethodPost, "/api/v1/snippets", models.SnippetRequest{Language: "python", Code: "print(1)", Title: "One"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create status = %d (body %s)", w.Code, w.Body.String())
+	}
+	var created models.SnippetResponse
+	decode(t, w, &created)
+	if created.SnippetID == "" || created.ShareURL != "/snippets/"+created.SnippetID {
+		t.Fatalf("created = %+v", created)
+	}
+
+	// Refreshes by the same visitor count once
+	for i, want := range []int{1, 1, 2} {
+		w = request(t, ta, http.MethodGet, "/api/v1/snippets/"+created.SnippetID, nil, "User-Agent", "visitor-"+strconv.Itoa(want))
+		if w.Code != http.StatusOK {
+			t.Fatalf("get status = %d", w.Code)
+		}
+		var snippet models.Snippet
+		decode(t, w, &snippet)
+		if snippet.Code != "print(1)" || snippet.Title != "One" || snippet.Views != want {
+			t.Errorf("read %d: snippet = %+v, want %d views", i, snippet, want)
+		}
+	}
+}
+
+// createSnippet creates a snippet, optionally as the user of bearer token
+func createSnippet(t *testing.T, ta *testutil.TestApp, body models.SnippetRequest, headers ...string) models.SnippetResponse {
+	t.Helper()
+	w := request(t, ta, http.MethodPost, "/api/v1/snippets", body, headers...)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create status = %d (body %s)", w.Code, w.Body.String())
+	}
+	var created models.SnippetResponse
+	decode(t, w, &created)
+	return created
+}
+
+func TestSnippetEditToken(t *testing.T) {
+	ta := testutil.NewTestApp(t)
+	created := createSnippet(t, ta, models.SnippetRequest{Language: "python", Code: "print(1)"})
+	if created.EditToken == "" {
+		t.Fatal("no edit token returned")
+	}
+	path := "/api/v1/snippets/" + created.SnippetID
+	update := models.SnippetRequest{Language: "python", Code: "print(2)", Title: "Fixed"}
+
+	expectError(t, request(t, ta, http.MethodPut, path, update), http.StatusForbidden, "FORBIDDEN")
+	expectError(t, request(t, ta, http.MethodPut, path, update, "X-Edit-Token", "wrong"), http.StatusForbidden, "FORBIDDEN")
+
+	w := request(t, ta, http.MethodPut, path, update, "X-Edit-Token", created.EditToken)
+	if w.Code != http.StatusOK {
+		t.Fatalf("update status = %d (body %s)", w.Code, w.Body.String())
+	}
+	var snippet models.Snippet
+	decode(t, request(t, ta, http.MethodGet, path, nil), &snippet)
+	if snippet.Code != "print(2)" || snippet.Title != "Fixed" || !snippet.UpdatedAt.After(snippet.CreatedAt) {
+		t.Errorf("snippet = %+v, want updated contents", snippet)
+	}
+	if strings.Contains(w.Body.String(), created.EditToken) {
+		t.Error("edit token leaked in snippet response")
+	}
+
+	expectError(t, request(t, ta, http.MethodDelete, path, nil), http.StatusForbidden, "FORBIDDEN")
+	if w := request(t, ta, http.MethodDelete, path, nil, "X-Edit-Token", created.EditToken); w.Code != http.StatusNoContent {
+		t.Fatalf("delete status = %d (body %s)", w.Code, w.Body.String())
+	}
+	expectError(t, request(t, ta, http.MethodGet, path, nil), http.StatusNotFound, "NOT_FOUND")
+	expectError(t, request(t, ta, http.MethodDelete, path, nil, "X-Edit-Token", created.EditToken), http.StatusNotFound, "NOT_FOUND")
+}
+
+func TestSnippetOwner(t *testing.T) {
+	ta := testutil.NewTestApp(t, func(cfg *configs.Config) {
+		cfg.AuthTokens = map[string]string{"alice-token": "alice", "bob-token": "bob"}
+	})
+	created := createSnippet(t, ta, models.SnippetRequest{Language: "python", Code: "print(1)"}, "Authorization", "Bearer alice-token")
+	path := "/api/v1/snippets/" + created.SnippetID
+	update := models.SnippetRequest{Language: "python", Code: "print(2)"}
+
+	var snippet models.Snippet
+	decode(t, request(t, ta, http.MethodGet, path, nil), &snippet)
+	if snippet.Owner != "alice" {
+		t.Errorf("owner = %q, want alice", snippet.Owner)
+	}
+
+	expectError(t, request(t, ta, http.MethodPut, path, update, "Authorization", "Bearer bob-token"), http.StatusForbidden, "FORBIDDEN")
+	expectError(t, request(t, ta, http.MethodPut, path, update, "Authorization", "Bearer unknown"), http.StatusUnauthorized, "UNAUTHORIZED")
+	if w := request(t, ta, http.MethodPut, path, update, "Authorization", "Bearer alice-token"); w.Code != http.StatusOK {
+		t.Errorf("owner update status = %d (body %s)", w.Code, w.Body.String())
+	}
+	if w := request(t, ta, http.MethodDelete, path, nil, "Authorization", "Bearer alice-token"); w.Code != http.StatusNoContent {
+		t.Errorf("owner delete status = %d (body %s)", w.Code, w.Body.String())
+	}
+}
+
+func TestSnippetRevisions(t *testing.T) {
+	ta := testutil.NewTestApp(t)
+	created := createSnippet(t, ta, models.SnippetRequest{Language: "python", Code: "a = 1\nprint(a)\n", Title: "v1"})
+	path := "/api/v1/snippets/" + created.SnippetID
+
+	for _, code := range []string{"a = 2\nprint(a)\n", "a = 2\nprint(a * 2)\n"} {
+		w := request(t, ta, http.MethodPut, path, models.SnippetRequest{Language: "python", Code: code}, "X-Edit-Token", created.EditToken)
+		if w.Code != http.StatusOK {
+			t.Fatalf("update status = %d (body %s)", w.Code, w.Body.String())
+		}
+	}
+
+	var list models.SnippetRevisionsResponse
+	decode(t, request(t, ta, http.MethodGet, path+"/revisions", nil), &list)
+	if len(list.Revisions) != 3 || list.Revisions[0].Revision != 1 || list.Revisions[2].Revision != 3 || list.Revisions[0].Code != "" {
+		t.Fatalf("revisions = %+v, want 3 revisions without code", list.Revisions)
+	}
+
+	var rev models.SnippetRevision
+	decode(t, request(t, ta, http.MethodGet, path+"/revisions/1", nil), &rev)
+	if rev.Code != "a = 1\nprint(a)\n" || rev.Title != "v1" {
+		t.Errorf("revision 1 = %+v, want the original contents", rev)
+	}
+
+	var diff models.SnippetDiffResponse
+	decode(t, request(t, ta, http.MethodGet, path+"/diff?from=1&to=3", nil), &diff)
+	want := "--- revision 1\n+++ revision 3\n@@ -1,2 +1,2 @@\n-a = 1\n-print(a)\n+a = 2\n+print(a * 2)\n"
+	if diff.Diff != want {
+		t.Errorf("diff = %q, want %q", diff.Diff, want)
+	}
+
+	// Defaults to the latest change
+	decode(t, request(t, ta, http.MethodGet, path+"/diff", nil), &diff)
+	if diff.From != 2 || diff.To != 3 || !strings.Contains(diff.Diff, "+print(a * 2)") {
+		t.Errorf("default diff = %+v, want revision 2 to 3", diff)
+	}
+
+	expectError(t, request(t, ta, http.MethodGet, path+"/revisions/9", nil), http.StatusNotFound, "NOT_FOUND")
+	expectError(t, request(t, ta, http.MethodGet, path+"/revisions/x", nil), http.StatusBadRequest, "INVALID_INPUT")
+	expectError(t, request(t, ta, http.MethodGet, path+"/diff?from=0", nil), http.StatusBadRequest, "INVALID_INPUT")
+	expectError(t, request(t, ta, http.MethodGet, "/api/v1/snippets/missing/revisions", nil), http.StatusNotFound, "NOT_FOUND")
+}
+
+func TestSnippetForks(t *testing.T) {
+	ta := testutil.NewTestApp(t, func(cfg *configs.Config) {
+		cfg.AuthTokens = map[string]string{"student-token": "student"}
+	})
+	template := createSnippet(t, ta, models.SnippetRequest{Language: "python", Code: "# TODO", Title: "Template"})
+	templatePath := "/api/v1/snippets/" + template.SnippetID
+	request(t, ta, http.MethodPut, templatePath, models.SnippetRequest{Language: "python", Code: "# TODO v2", Title: "Template"}, "X-Edit-Token", template.EditToken)
+
+	fork := func(body interface{}, headers ...string) models.SnippetResponse {
+		t.Helper()
+		w := request(t, ta, http.MethodPost, templatePath+"/fork", body, headers...)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("fork status = %d (body %s)", w.Code, w.Body.String())
+		}
+		var created models.SnippetResponse
+		decode(t, w, &created)
+		return created
+	}
+	latest := fork(nil, "Authorization", "Bearer student-token")
+	original := fork(models.ForkSnippetRequest{Revision: 1, Title: "Mine"})
+	if latest.ParentID != template.SnippetID || latest.EditToken == "" || latest.EditToken == template.EditToken {
+		t.Fatalf("fork = %+v, want parent and a new edit token", latest)
+	}
+
+	var snippet models.Snippet
+	decode(t, request(t, ta, http.MethodGet, "/api/v1/snippets/"+latest.SnippetID, nil), &snippet)
+	if snippet.Code != "# TODO v2" || snippet.Owner != "student" || snippet.ParentRevision != 2 || snippet.Revision != 1 {
+		t.Errorf("fork of latest = %+v", snippet)
+	}
+	decode(t, request(t, ta, http.MethodGet, "/api/v1/snippets/"+original.SnippetID, nil), &snippet)
+	if snippet.Code != "# TODO" || snippet.Title != "Mine" || snippet.ParentRevision != 1 {
+		t.Errorf("fork of revision 1 = %+v", snippet)
+	}
+
+	var forks models.SnippetForksResponse
+	decode(t, request(t, ta, http.MethodGet, templatePath+"/forks", nil), &forks)
+	if forks.ForkCount != 2 || len(forks.Forks) != 2 || forks.Forks[0].ID != latest.SnippetID {
+		t.Errorf("forks = %+v, want both forks", forks)
+	}
+	decode(t, request(t, ta, http.MethodGet, templatePath, nil), &snippet)
+	if snippet.ForkCount != 2 {
+		t.Errorf("fork_count = %d, want 2", snippet.ForkCount)
+	}
+
+	// Deleting a fork updates the count; deleting the parent keeps lineage
+	request(t, ta, http.MethodDelete, "/api/v1/snippets/"+original.SnippetID, nil, "X-Edit-Token", original.EditToken)
+	decode(t, request(t, ta, http.MethodGet, templatePath, nil), &snippet)
+	if snippet.ForkCount != 1 {
+		t.Errorf("fork_count after delete = %d, want 1", snippet.ForkCount)
+	}
+	request(t, ta, http.MethodDelete, templatePath, nil, "X-Edit-Token", template.EditToken)
+	decode(t, request(t, ta, http.MethodGet, "/api/v1/snippets/"+latest.SnippetID, nil), &snippet)
+	if snippet.ParentID == nil || *snippet.ParentID != template.SnippetID {
+		t.Errorf("parent_id = %v after parent deletion", snippet.ParentID)
+	}
+
+	expectError(t, request(t, ta, http.MethodPost, "/api/v1/snippets/missing/fork", nil), http.StatusNotFound, "NOT_FOUND")
+	expectError(t, request(t, ta, http.MethodPost, "/api/v1/snippets/"+latest.SnippetID+"/fork", models.ForkSnippetRequest{Revision: 5}), http.StatusNotFound, "NOT_FOUND")
+}
+
+func TestSnippetFiles(t *testing.T) {
+	ta := testutil.NewTestApp(t)
+	created := createSnippet(t, ta, models.SnippetRequest{
+		Language: "python",
+		Title:    "Exercise",
+		Files: []models.SnippetFile{
+			{Name: "main.py", Content: "import util\n"},
+			{Name: "util.py", Content: "x = 1\n"},
+			{Name: "data/input.txt", Language: "text", Content: "42\n"},
+		},
+	})
+	path := "/api/v1/snippets/" + created.SnippetID
+
+	var snippet models.Snippet
+	decode(t, request(t, ta, http.MethodGet, path, nil), &snippet)
+	if len(snippet.Files) != 3 || snippet.Files[1].Language != "python" || snippet.Files[2].Language != "text" {
+		t.Fatalf("files = %+v, want three files with languages", snippet.Files)
+	}
+	if snippet.Language != "python" || snippet.Code != "import util\n" {
+		t.Errorf("snippet = %+v, want the entry point as its code", snippet)
+	}
+
+	update := models.SnippetRequest{Language: "python", Files: []models.SnippetFile{
+		{Name: "main.py", Content: "import util\n"},
+		{Name: "util.py", Content: "x = 2\n"},
+	}}
+	w := request(t, ta, http.MethodPut, path, update, "X-Edit-Token", created.EditToken)
+	if w.Code != http.StatusOK {
+		t.Fatalf("update status = %d (body %s)", w.Code, w.Body.String())
+	}
+	var diff models.SnippetDiffResponse
+	decode(t, request(t, ta, http.MethodGet, path+"/diff", nil), &diff)
+	want := "--- revision 1/util.py\n+++ revision 2/util.py\n@@ -1 +1 @@\n-x = 1\n+x = 2\n" +
+		"--- revision 1/data/input.txt\n+++ revision 2/data/input.txt\n@@ -1 +0,0 @@\n-42\n"
+	if diff.Diff != want {
+		t.Errorf("diff = %q, want %q", diff.Diff, want)
+	}
+
+	w = request(t, ta, http.MethodGet, path+"/archive", nil)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/zip" {
+		t.Fatalf("zip status = %d, content type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("invalid zip: %v", err)
+	}
+	dir := "snippet-" + created.SnippetID + "/"
+	if len(archive.File) != 2 || archive.File[0].Name != dir+"main.py" || archive.File[1].Name != dir+"util.py" {
+		t.Errorf("zip entries = %+v", archive.File)
+	}
+
+	// Single-file snippets are archived under their language's file name
+	single := createSnippet(t, ta, models.SnippetRequest{Language: "python", Code: "print(1)"})
+	w = request(t, ta, http.MethodGet, "/api/v1/snippets/"+single.SnippetID+"/archive?format=tar", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("tar status = %d (body %s)", w.Code, w.Body.String())
+	}
+	compressed, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("invalid gzip: %v", err)
+	}
+	entries := tar.NewReader(compressed)
+	header, err := entries.Next()
+	if err != nil || header.Name != "snippet-"+single.SnippetID+"/main.py" {
+		t.Fatalf("tar entry = %+v, %v", header, err)
+	}
+	if content, _ := io.ReadAll(entries); string(content) != "print(1)" {
+		t.Errorf("tar content = %q", content)
+	}
+
+	expectError(t, request(t, ta, http.MethodGet, path+"/archive?format=rar", nil), http.StatusBadRequest, "INVALID_INPUT")
+	for _, body := range []models.SnippetRequest{
+		{Language: "python", Code: "x", Files: update.Files},
+		{Files: []models.SnippetFile{{Name: "main.py"}}},
+		{Language: "python", Files: []models.SnippetFile{{Name: "a.py"}, {Name: "a.py"}}},
+		{Language: "python", Files: []models.SnippetFile{{Name: "../a.py"}}},
+	} {
+		expectError(t, request(t, ta, http.MethodPost, "/api/v1/snippets", body), http.StatusBadRequest, "INVALID_INPUT")
+	}
+}
+
+func TestListSnippets(t *testing.T) {
+	ta := testutil.NewTestApp(t)
+	for i := 0; i < 3; i++ {
+		createSnippet(t, ta, models.SnippetRequest{Language: "rust", Code: "fn main() {}", Title: "Example " + strconv.Itoa(i), Tags: []string{"ownership"}})
+	}
+	createSnippet(t, ta, models.SnippetRequest{Language: "rust", Code: "fn main() {}", Title: "Untagged"})
+
+	var page models.SnippetListResponse
+	decode(t, request(t, ta, http.MethodGet, "/api/v1/snippets?language=rust&tag=ownership&limit=2", nil), &page)
+	if len(page.Snippets) != 2 || page.NextCursor == "" || page.Snippets[0].Title != "Example 2" {
+		t.Fatalf("first page = %+v", page)
+	}
+	path := "/api/v1/snippets?language=rust&tag=ownership&limit=2&cursor=" + page.NextCursor
+	page = models.SnippetListResponse{}
+	decode(t, request(t, ta, http.MethodGet, path, nil), &page)
+	if len(page.Snippets) != 1 || page.NextCursor != "" || page.Snippets[0].Title != "Example 0" {
+		t.Fatalf("last page = %+v", page)
+	}
+	if tags := page.Snippets[0].Tags; len(tags) != 1 || tags[0] != "ownership" {
+		t.Errorf("tags = %v", tags)
+	}
+
+	expectError(t, request(t, ta, http.MethodGet, "/api/v1/snippets?sort=oldest", nil), http.StatusBadRequest, "INVALID_INPUT")
+	expectError(t, request(t, ta, http.MethodGet, "/api/v1/snippets?limit=500", nil), http.StatusBadRequest, "INVALID_INPUT")
+	expectError(t, request(t, ta, http.MethodGet, "/api/v1/snippets?cursor=x", nil), http.StatusBadRequest, "INVALID_INPUT")
+}
+
+func TestSnippetRun(t *testing.T) {
+	ta := testutil.NewTestApp(t)
+	stdin := "saved"
+	created := createSnippet(t, ta, models.SnippetRequest{Language: "Python3", Code: "print(input())", Stdin: &stdin, Args: []string{"-v"}})
+	path := "/api/v1/snippets/" + created.SnippetID
+
+	run := func(body interface{}) models.ExecuteResponse {
+		t.Helper()
+		w := request(t, ta, http.MethodPost, path+"/run", body)
+		if w.Code != http.StatusOK {
+			t.Fatalf("run status = %d (body %s)", w.Code, w.Body.String())
+		}
+		var resp models.ExecuteResponse
+		decode(t, w, &resp)
+		return resp
+	}
+	get := func() models.Snippet {
+		t.Helper()
+		var snippet models.Snippet
+		decode(t, request(t, ta, http.MethodGet, path, nil, "X-Edit-Token", created.EditToken), &snippet)
+		return snippet
+	}
+
+	if resp := run(nil); resp.Output != "saved" {
+		t.Errorf("run output = %q, want the saved stdin", resp.Output)
+	}
+	if got := ta.Piston.Requests[0]; got.Language != "python" || !slices.Equal(got.Args, []string{"-v"}) {
+		t.Errorf("piston request = %+v, want python with the saved args", got)
+	}
+	snippet := get()
+	if snippet.LanguageID != python || snippet.LastRun == nil || snippet.LastRun.Result.Output != "saved" || snippet.LastRun.Revision != 1 {
+		t.Fatalf("snippet = %+v, want the saved run", snippet)
+	}
+
+	// Overrides are run but not stored
+	if resp := run(models.SnippetRunRequest{Stdin: &[]string{"other"}[0], Args: []string{}}); resp.Output != "other" {
+		t.Errorf("override output = %q", resp.Output)
+	}
+	if got := ta.Piston.Requests[1].Args; len(got) != 0 {
+		t.Errorf("override args = %v, want none", got)
+	}
+	if snippet := get(); snippet.LastRun.Result.Output != "saved" {
+		t.Errorf("last run = %+v, want the saved run kept", snippet.LastRun)
+	}
+
+	// Updates clear the stored run and keep the saved stdin
+	w := request(t, ta, http.MethodPut, path, models.SnippetRequest{Language: "python", Code: "print(2)"}, "X-Edit-Token", created.EditToken)
+	if w.Code != http.StatusOK {
+		t.Fatalf("update status = %d (body %s)", w.Code, w.Body.String())
+	}
+	if snippet := get(); snippet.LastRun != nil || snippet.Stdin != "saved" {
+		t.Errorf("updated snippet = %+v, want no last run and the saved stdin", snippet)
+	}
+
+	unknown := createSnippet(t, ta, models.SnippetRequest{Language: "brainfuck", Code: "+."})
+	expectError(t, request(t, ta, http.MethodPost, "/api/v1/snippets/"+unknown.SnippetID+"/run", nil), http.StatusBadRequest, "UNSUPPORTED_LANGUAGE")
+	explicit := createSnippet(t, ta, models.SnippetRequest{Language: "python 3.10", LanguageID: python, Code: "print(1)"})
+	if w := request(t, ta, http.MethodPost, "/api/v1/snippets/"+explicit.SnippetID+"/run", nil); w.Code != http.StatusOK {
+		t.Errorf("explicit language_id run status = %d", w.Code)
+	}
+	expectError(t, request(t, ta, http.MethodPost, "/api/v1/snippets/missing/run", nil), http.StatusNotFound, "NOT_FOUND")
+}
+
+func TestSnippetViews(t *testing.T) {
+	ta := testutil.NewTestApp(t)
+	created := createSnippet(t, ta, models.SnippetRequest{Title: "Hello", Language: "py", Code: "print('<b>hi</b>')", SnippetOptions: models.SnippetOptions{MaxViews: 10}})
+	path := "/api/v1/snippets/" + created.SnippetID
+
+	w := request(t, ta, http.MethodGet, path+"/raw", nil)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/plain; charset=utf-8" || w.Body.String() != "print('<b>hi</b>')" {
+		t.Errorf("raw = %d %q %q", w.Code, w.Header().Get("Content-Type"), w.Body.String())
+	}
+	w = request(t, ta, http.MethodGet, path+"/download", nil)
+	if got := w.Header().Get("Content-Disposition"); w.Code != http.StatusOK || got != "attachment; filename=main.py" {
+		t.Errorf("download = %d %q", w.Code, got)
+	}
+
+	w = request(t, ta, http.MethodGet, path+"/embed?theme=dark", nil)
+	if body := w.Body.String(); w.Code != http.StatusOK || !strings.Contains(body, "&lt;b&gt;hi&lt;/b&gt;") || strings.Contains(body, "<b>hi") {
+		t.Errorf("embed = %d %s, want escaped code", w.Code, body)
+	}
+	if got := w.Header().Get("Content-Security-Policy"); !strings.Contains(got, "frame-ancestors *") {
+		t.Errorf("embed CSP = %q", got)
+	}
+	expectError(t, request(t, ta, http.MethodGet, path+"/embed?theme=neon", nil), http.StatusBadRequest, "INVALID_INPUT")
+
+	embedURL := "http://example.com" + path + "/embed"
+	w = request(t, ta, http.MethodGet, path+"/embed.js", nil)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"`+embedURL+`"`) {
+		t.Errorf("embed.js = %d %s", w.Code, w.Body.String())
+	}
+
+	var oembed models.OEmbedResponse
+	w = request(t, ta, http.MethodGet, "/api/v1/oembed?maxwidth=300&url="+url.QueryEscape("https://example.com"+path), nil)
+	decode(t, w, &oembed)
+	if oembed.Type != "rich" || oembed.Title != "Hello" || oembed.Width != 300 || !strings.Contains(oembed.HTML, `src="`+embedURL+`"`) {
+		t.Errorf("oembed = %+v", oembed)
+	}
+	expectError(t, request(t, ta, http.MethodGet, "/api/v1/oembed?url=https://example.com/other", nil), http.StatusNotFound, "NOT_FOUND")
+	expectError(t, request(t, ta, http.MethodGet, "/api/v1/oembed?format=xml&url="+url.QueryEscape(embedURL), nil), http.StatusNotImplemented, "UNSUPPORTED_FORMAT")
+
+	// The script and oEmbed lookups do not use up views
+	var snippet models.Snippet
+	decode(t, request(t, ta, http.MethodGet, path, nil, "X-Edit-Token", created.EditToken), &snippet)
+	if snippet.Views != 3 {
+		t.Errorf("views = %d, want 3", snippet.Views)
+	}
+
+	project := createSnippet(t, ta, models.SnippetRequest{Language: "python", Files: []models.SnippetFile{
+		{Name: "main.py", Content: "import util"},
+		{Name: "lib/util.py", Content: "X = 1"},
+	}})
+	path = "/api/v1/snippets/" + project.SnippetID
+	if w := request(t, ta, http.MethodGet, path+"/raw?file=lib/util.py", nil); w.Body.String() != "X = 1" {
+		t.Errorf("raw file = %q", w.Body.String())
+	}
+	if got := request(t, ta, http.MethodGet, path+"/download?file=lib/util.py", nil).Header().Get("Content-Disposition"); got != "attachment; filename=util.py" {
+		t.Errorf("download file disposition = %q", got)
+	}
+	expectError(t, request(t, ta, http.MethodGet, path+"/raw?file=missing.py", nil), http.StatusNotFound, "NOT_FOUND")
+
+	private := createSnippet(t, ta, models.SnippetRequest{Language: "python", Code: "secret", SnippetOptions: models.SnippetOptions{Visibility: "private"}})
+	expectError(t, request(t, ta, http.MethodGet, "/api/v1/snippets/"+private.SnippetID+"/raw", nil), http.StatusNotFound, "NOT_FOUND")
+	expectError(t, request(t, ta, http.MethodGet, "/api/v1/oembed?url="+url.QueryEscape("https://example.com/api/v1/snippets/"+private.SnippetID), nil), http.StatusNotFound, "NOT_FOUND")
+}
+
+func TestSnippetStats(t *testing.T) {
+	ta := testutil.NewTestApp(t)
+	created := createSnippet(t, ta, models.SnippetRequest{Language: "python", Code: "print(1)"})
+	path := "/api/v1/snippets/" + created.SnippetID
+
+	// The repeated read and the run are by the same visitor
+	request(t, ta, http.MethodGet, path, nil)
+	request(t, ta, http.MethodGet, path, nil)
+	request(t, ta, http.MethodGet, path, nil, "User-Agent", "other")
+	request(t, ta, http.MethodPost, path+"/run", nil)
+
+	var stats models.SnippetStats
+	w := request(t, ta, http.MethodGet, path+"/stats?days=3", nil, "X-Edit-Token", created.EditToken)
+	decode(t, w, &stats)
+	if stats.Views != 2 || stats.Runs != 1 || len(stats.Daily) != 3 || stats.Daily[2].Views != 2 || stats.Daily[2].Runs != 1 {
+		t.Errorf("stats = %+v, want 2 views and 1 run today", stats)
+	}
+	expectError(t, request(t, ta, http.MethodGet, path+"/stats", nil), http.StatusForbidden, "FORBIDDEN")
+	expectError(t, request(t, ta, http.MethodGet, path+"/stats?days=0", nil, "X-Edit-Token", created.EditToken), http.StatusBadRequest, "INVALID_INPUT")
+}
+
+func TestSnippetVisibility(t *testing.T) {
+	ta := testutil.NewTestApp(t)
+
+	private := createSnippet(t, ta, models.SnippetRequest{Language: "python", Code: "secret", SnippetOptions: models.SnippetOptions{Visibility: "private"}})
+	path := "/api/v1/snippets/" + private.SnippetID
+	expectError(t, request(t, ta, http.MethodGet, path, nil), http.StatusNotFound, "NOT_FOUND")
+	expectError(t, request(t, ta, http.MethodGet, path+"/revisions/1", nil), http.StatusNotFound, "NOT_FOUND")
+	expectError(t, request(t, ta, http.MethodPost, path+"/fork", nil), http.StatusNotFound, "NOT_FOUND")
+	if w := request(t, ta, http.MethodGet, path, nil, "X-Edit-Token", private.EditToken); w.Code != http.StatusOK {
+		t.Errorf("editor read status = %d, want 200", w.Code)
+	}
+
+	protected := createSnippet(t, ta, models.SnippetRequest{Language: "python", Code: "guarded", SnippetOptions: models.SnippetOptions{Visibility: "password", Password: "open sesame"}})
+	path = "/api/v1/snippets/" + protected.SnippetID
+	expectError(t, request(t, ta, http.MethodGet, path, nil), http.StatusUnauthorized, "PASSWORD_REQUIRED")
+	expectError(t, request(t, ta, http.MethodGet, path, nil, "X-Snippet-Password", "wrong"), http.StatusForbidden, "INVALID_PASSWORD")
+	w := request(t, ta, http.MethodGet, path, nil, "X-Snippet-Password", "open sesame")
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), "password_hash") {
+		t.Errorf("password read = %d %s", w.Code, w.Body.String())
+	}
+
+	expectError(t, request(t, ta, http.MethodPost, "/api/v1/snippets", models.SnippetRequest{Language: "python", Code: "x", SnippetOptions: models.SnippetOptions{Visibility: "password"}}), http.StatusBadRequest, "INVALID_INPUT")
+}
+
+func TestSnippetBurnAfterReading(t *testing.T) {
+	ta := testutil.NewTestApp(t)
+	created := createSnippet(t, ta, models.SnippetRequest{Language: "python", Code: "token = 'oops'", SnippetOptions: models.SnippetOptions{MaxViews: 2, ExpiresIn: 3600}})
+	path := "/api/v1/snippets/" + created.SnippetID
+
+	// Editors can check the snippet without using up views
+	var snippet models.Snippet
+	decode(t, request(t, ta, http.MethodGet, path, nil, "X-Edit-Token", created.EditToken), &snippet)
+	if snippet.MaxViews != 2 || snippet.ExpiresAt == nil || snippet.Views != 0 {
+		t.Fatalf("snippet = %+v, want max_views and expires_at", snippet)
+	}
+
+	for read := 1; read <= 2; read++ {
+		if w := request(t, ta, http.MethodGet, path, nil); w.Code != http.StatusOK {
+			t.Fatalf("read %d status = %d", read, w.Code)
+		}
+	}
+	expectError(t, request(t, ta, http.MethodGet, path, nil), http.StatusNotFound, "NOT_FOUND")
+	expectError(t, request(t, ta, http.MethodGet, path+"/revisions", nil, "X-Edit-Token", created.EditToken), http.StatusNotFound, "NOT_FOUND")
+}
+
+func TestSnippetErrors(t *testing.T) {
+	ta := testutil.NewTestApp(t)
+
+	expectError(t, request(t, ta, http.MethodPost, "/api/v1/snippets", map[string]string{"code": "x"}), http.StatusBadRequest, "INVALID_INPUT")
+	expectError(t, request(t, ta, http.MethodPost, "/api/v1/snippets", models.SnippetRequest{Language: "python", Code: strings.Repeat("x", 65537)}), http.StatusBadRequest, "INVALID_INPUT")
+	expectError(t, request(t, ta, http.MethodGet, "/api/v1/snippets/missing", nil), http.StatusNotFound, "NOT_FOUND")
+}
+
+func TestCORS(t *testing.T) {
+	ta := testutil.NewTestApp(t)
+
+	w := request(t, ta, http.MethodOptions, "/api/v1/execute", nil, "Origin", "http://localhost:5173")
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "http://localhost:5173" {
+		t.Errorf("Access-Control-Allow-Origin = %q", got)
+	}
+}
+
+func TestExecuteRecordAndReplay(t *testing.T) {
+	fixtures := t.TempDir()
+	body := models.ExecuteRequest{LanguageID: python, Code: "print(input())", Stdin: "recorded"}
+
+	recorder := testutil.NewTestApp(t, func(cfg *configs.Config) {
+		cfg.MockMode = "record"
+		cfg.MockFixturesDir = fixtures
+	})
+	recorded := execute(t, recorder, body)
+
+	replayer := testutil.NewTestApp(t, func(cfg *configs.Config) {
+		cfg.MockMode = "replay"
+		cfg.MockFixturesDir = fixtures
+	})
+	replayed := execute(t, replayer, body)
+
+	if replayed != recorded || recorded.Output != "recorded" {
+		t.Errorf("replayed = %+v, want %+v", replayed, recorded)
+	}
+	if n := replayer.Piston.RequestCount(); n != 0 {
+		t.Errorf("replay contacted Piston %d times", n)
+	}
+}
+
+func TestExecuteEmbedded(t *testing.T) {
+	ta := testutil.NewTestApp(t, func(cfg *configs.Config) {
+		cfg.DefaultBackend = "embedded"
+		cfg.EmbeddedEnabled = true
+	})
+
+	resp := execute(t, ta, models.ExecuteRequest{LanguageID: 63, Code: `console.log("sum", 1 + 2)`})
+	if !resp.Success || resp.Output != "sum 3\n" {
+		t.Errorf("response = %+v, want embedded JavaScript output", resp)
+	}
+	if ta.Piston.RequestCount() != 0 || ta.Judge0.SubmissionCount() != 0 {
+		t.Errorf("embedded execution contacted an external backend")
+	}
+}
+
+func TestExecuteDistributed(t *testing.T) {
+	ta := testutil.NewTestApp(t, func(cfg *configs.Config) {
+		cfg.QueueMode = "redis"
+	})
+
+	// A separate worker runs the executions the server queues
+	executors, pools := app.NewExecutors(ta.Config)
+	defer func() {
+		for _, pool := range pools {
+			pool.Close()
+		}
+	}()
+	worker := services.NewStreamWorker(services.NewStreamQueue(ta.App.Redis, ta.Config), executors, "test")
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		worker.Run(ctx, 1)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	resp := execute(t, ta, models.ExecuteRequest{LanguageID: python, Code: "print(input())", Stdin: "distributed"})
+	if !resp.Success || resp.Output != "distributed" {
+		t.Errorf("response = %+v, want output from the worker", resp)
+	}
+	if ta.Piston.RequestCount() != 1 {
+		t.Errorf("piston requests = %d, want 1", ta.Piston.RequestCount())
+	}
+}
@@ -0,0 +1,254 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/online-compiler/backend/configs"
+	"github.com/online-compiler/backend/internal/api"
+	"github.com/online-compiler/backend/internal/api/handlers"
+	"github.com/online-compiler/backend/internal/database"
+	"github.com/online-compiler/backend/internal/services"
+	"gorm.io/gorm"
+)
+
+// App holds the configuration and every service the server depends on.
+// Fields may be replaced after New and before Router to swap implementations.
+type App struct {
+	Config      *configs.Config
+	DB          *gorm.DB
+	Redis       *redis.Client
+	Snippets    services.SnippetStore
+	Cache       services.ResultCache
+	Limiter     services.RateLimiter
+	Executors   *services.ExecutorRegistry
+	Submissions *services.SubmissionManager
+	Pools       []*services.WorkerPool
+	Webhooks    *services.WebhookNotifier
+	Sweeper     *services.SnippetSweeper
+	Views       *services.ViewCounter
+
+	handler *handlers.Handler
+}
+
+// Open connects to the database and Redis described by cfg and builds the
+// application. Redis is optional; caching and rate limiting are disabled
+// when it is unreachable, unless queue_mode requires it.
+func Open(ctx context.Context, cfg *configs.Config) (*App, error) {
+	db, err := database.InitDatabase(cfg.DatabasePath)
+	if err != nil {
+		return nil, err
+	}
+	if err := newSnippetService(cfg, db).MigrateContents(ctx); err != nil {
+		database.CloseDatabase(db)
+		return nil, fmt.Errorf("failed to migrate snippet contents: %v", err)
+	}
+	slog.Info("Database initialized", "path", cfg.DatabasePath)
+
+	redisClient, err := services.NewRedisClient(ctx, cfg)
+	if err != nil && cfg.QueueMode == "redis" {
+		database.CloseDatabase(db)
+		return nil, fmt.Errorf("queue_mode is redis but Redis is unavailable: %v", err)
+	}
+	if err != nil {
+		slog.Warn("Failed to initialize Redis, continuing without Redis", "error", err)
+		redisClient = nil
+	} else {
+		slog.Info("Redis initialized")
+	}
+
+	return New(cfg, db, redisClient), nil
+}
+
+// New builds the application from already opened connections. redisClient
+// may be nil, in which case executions always run in-process.
+func New(cfg *configs.Config, db *gorm.DB, redisClient *redis.Client) *App {
+	var executors *services.ExecutorRegistry
+	var pools []*services.WorkerPool
+	if cfg.QueueMode == "redis" && redisClient != nil {
+		executors = remoteExecutors(cfg, redisClient)
+	} else {
+		if cfg.QueueMode == "redis" {
+			slog.Warn("Redis is unavailable, running executions in-process")
+		}
+		executors, pools = NewExecutors(cfg)
+	}
+
+	snippets := newSnippetService(cfg, db)
+	snippets.Views = services.StartViewCounter(db, time.Duration(cfg.ViewDedupWindow)*time.Second, time.Duration(cfg.ViewFlush)*time.Second)
+	webhooks := services.NewWebhookNotifier(db, cfg)
+	submissions := services.NewSubmissionManager()
+	submissions.Notifier = webhooks
+
+	return &App{
+		Config:      cfg,
+		DB:          db,
+		Redis:       redisClient,
+		Snippets:    snippets,
+		Sweeper:     services.StartSnippetSweeper(snippets, time.Duration(cfg.SnippetSweep)*time.Second),
+		Views:       snippets.Views,
+		Cache:       services.NewRedisCache(redisClient),
+		Limiter:     services.NewRedisRateLimiter(redisClient, cfg),
+		Executors:   executors,
+		Submissions: submissions,
+		Pools:       pools,
+		Webhooks:    webhooks,
+	}
+}
+
+// newSnippetService creates the snippet store with the configured blob
+// compression
+func newSnippetService(cfg *configs.Config, db *gorm.DB) *services.SnippetService {
+	snippets := services.NewSnippetService(db)
+	snippets.Blobs = services.NewBlobStore(cfg.BlobCompressMin)
+	return snippets
+}
+
+// NewExecutors registers every execution backend, each running on its own
+// worker pool. It is shared by the server and the queue worker.
+func NewExecutors(cfg *configs.Config) (*services.ExecutorRegistry, []*services.WorkerPool) {
+	timeout := time.Duration(cfg.Judge0Timeout) * time.Second
+
+	executors := services.NewExecutorRegistry(cfg)
+	executors.Register("judge0", services.NewJudge0Service(cfg.Judge0URL, timeout, cfg))
+	executors.Register("piston", services.NewPistonService(cfg.PistonURL, timeout, cfg))
+	executors.Register("wasm", services.NewWasmService(cfg))
+	// Embedded interpreters share the server's memory, so they are only
+	// offered for trusted code
+	if cfg.EmbeddedEnabled {
+		executors.Register("embedded", services.NewEmbeddedService(cfg))
+	}
+	useMockExecutors(cfg, executors)
+	pools := useWorkerPools(cfg, executors)
+
+	return executors, pools
+}
+
+// remoteExecutors sends every backend's executions to queue workers
+func remoteExecutors(cfg *configs.Config, redisClient *redis.Client) *services.ExecutorRegistry {
+	queue := services.NewStreamQueue(redisClient, cfg)
+	executors := services.NewExecutorRegistry(cfg)
+	for _, backend := range configs.Backends {
+		executors.Register(backend, queue)
+	}
+	slog.Info("Executions are queued for workers", "stream", cfg.QueueStream)
+	return executors
+}
+
+// useMockExecutors wraps every backend with a fixture recorder, or replaces
+// them with a replayer, according to cfg.MockMode
+func useMockExecutors(cfg *configs.Config, executors *services.ExecutorRegistry) {
+	fixtures := services.NewFixtureStore(cfg.MockFixturesDir)
+
+	switch cfg.MockMode {
+	case "record":
+		for _, backend := range configs.Backends {
+			if executor, exists := executors.Get(backend); exists {
+				executors.Register(backend, services.NewMockRecorder(executor, fixtures))
+			}
+		}
+		slog.Info("Recording execution fixtures", "dir", cfg.MockFixturesDir)
+	case "replay":
+		replayer := services.NewMockReplayer(fixtures, time.Duration(cfg.MockLatency)*time.Millisecond, cfg.MockFailureRate)
+		for _, backend := range configs.Backends {
+			executors.Register(backend, replayer)
+		}
+		slog.Info("Replaying execution fixtures, backends will not be contacted", "dir", cfg.MockFixturesDir)
+	}
+}
+
+// useWorkerPools runs every backend on its own bounded worker pool
+func useWorkerPools(cfg *configs.Config, executors *services.ExecutorRegistry) []*services.WorkerPool {
+	var pools []*services.WorkerPool
+	for _, backend := range configs.Backends {
+		executor, exists := executors.Get(backend)
+		if !exists {
+			continue
+		}
+		workers, depth := cfg.Pool(backend)
+		pool := services.NewWorkerPool(backend, workers, depth)
+		executors.Register(backend, services.NewPooledExecutor(executor, pool))
+		pools = append(pools, pool)
+	}
+	return pools
+}
+
+// Router builds the HTTP router wired to the application's services
+func (a *App) Router() *gin.Engine {
+	a.handler = &handlers.Handler{
+		Languages:      a.Config,
+		Snippets:       a.Snippets,
+		Cache:          a.Cache,
+		Executors:      a.Executors,
+		Submissions:    a.Submissions,
+		Webhooks:       a.Webhooks,
+		PublicURL:      a.Config.PublicURL,
+		RedisHealth:    a.redisHealth(),
+		DatabaseHealth: a.databaseHealth(),
+	}
+	if judge0, exists := a.Executors.Get("judge0"); exists {
+		if pooled, ok := judge0.(*services.PooledExecutor); ok {
+			judge0 = pooled.Executor
+		}
+		if pinger, ok := judge0.(handlers.Pinger); ok {
+			a.handler.Judge0Health = pinger
+		}
+	}
+
+	return api.SetupRouter(a.handler, a.Limiter, a.Config)
+}
+
+// MarkShuttingDown makes readiness checks fail while the server drains
+func (a *App) MarkShuttingDown() {
+	if a.handler != nil {
+		a.handler.MarkShuttingDown()
+	}
+}
+
+// Close stops the worker pools, webhook deliveries and snippet sweeper,
+// writes buffered view counts and releases the Redis and database
+// connections
+func (a *App) Close() {
+	a.Webhooks.Close()
+	a.Sweeper.Close()
+	a.Views.Close()
+	for _, pool := range a.Pools {
+		pool.Close()
+	}
+	if a.Redis != nil {
+		if err := a.Redis.Close(); err != nil {
+			slog.Warn("Failed to close Redis", "error", err)
+		}
+	}
+	if err := database.CloseDatabase(a.DB); err != nil {
+		slog.Warn("Failed to close database", "error", err)
+	}
+}
+
+// redisHealth pings Redis, or returns nil when Redis is not configured
+func (a *App) redisHealth() handlers.Pinger {
+	if a.Redis == nil {
+		return nil
+	}
+	return handlers.PingFunc(func(ctx context.Context) error {
+		return a.Redis.Ping(ctx).Err()
+	})
+}
+
+// databaseHealth pings the SQL connection
+func (a *App) databaseHealth() handlers.Pinger {
+	if a.DB == nil {
+		return nil
+	}
+	return handlers.PingFunc(func(ctx context.Context) error {
+		sqlDB, err := a.DB.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	})
+}
@@ -1,35 +1,78 @@
 package database
 
 import (
-	"log"
+	"log/slog"
+	"time"
 
 	"github.com/online-compiler/backend/internal/models"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/plugin/opentelemetry/tracing"
 )
 
-var DB *gorm.DB
+// SnippetSearchTable is the FTS5 index over snippets, present only when
+// SQLite was built with FTS5. It is contentless: the snippet service adds
+// and removes entries, since code is stored in blobs, keyed by
+// snippets.search_key.
+const SnippetSearchTable = "snippets_fts"
 
-// InitDatabase initializes the SQLite database
-func InitDatabase(dbPath string) error {
-	var err error
-
-	DB, err = gorm.Open(sqlite.Open(dbPath), &gorm.Config{})
+// InitDatabase opens the SQLite database and applies migrations
+func InitDatabase(dbPath string) (*gorm.DB, error) {
+	db, err := gorm.Open(sqlite.New(sqlite.Config{DriverName: driverName, DSN: dbPath}), &gorm.Config{
+		// Log slow queries and errors through slog so they carry request IDs
+		Logger: logger.NewSlogLogger(slog.Default(), logger.Config{
+			LogLevel:                  logger.Warn,
+			SlowThreshold:             200 * time.Millisecond,
+			IgnoreRecordNotFoundError: true,
+		}),
+	})
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	// Trace GORM queries; metrics are exported through Prometheus instead
+	if err := db.Use(tracing.NewPlugin(tracing.WithoutMetrics())); err != nil {
+		return nil, err
 	}
 
 	// Auto-migrate models
-	err = DB.AutoMigrate(&models.Snippet{})
+	err = db.AutoMigrate(&models.Snippet{}, &models.SnippetRevision{}, &models.SnippetDailyStats{}, &models.Blob{}, &models.WebhookDelivery{})
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	if err := setupSnippetSearch(db); err != nil {
+		return nil, err
 	}
 
-	log.Println("Database initialized successfully")
-	return nil
+	slog.Debug("database migrations applied", "path", dbPath)
+	return db, nil
+}
+
+// setupSnippetSearch creates the FTS5 index over snippet titles, code and
+// files. SQLite builds without FTS5 (see the sqlite_fts5 build tag) skip it
+// and search with LIKE instead.
+func setupSnippetSearch(db *gorm.DB) error {
+	var fts5 bool
+	if err := db.Raw("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&fts5).Error; err != nil {
+		return err
+	}
+	if !fts5 {
+		slog.Warn("SQLite was built without FTS5, snippet search falls back to LIKE, which decompresses code on every search; build with -tags sqlite_fts5")
+		return nil
+	}
+	return db.Exec(`CREATE VIRTUAL TABLE IF NOT EXISTS ` + SnippetSearchTable + ` USING fts5(title, code, files, content='', contentless_delete=1)`).Error
 }
 
-// GetDB returns the database instance
-func GetDB() *gorm.DB {
-	return DB
+// CloseDatabase closes the underlying database connection
+func CloseDatabase(db *gorm.DB) error {
+	if db == nil {
+		return nil
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
 }
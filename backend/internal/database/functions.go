@@ -0,0 +1,33 @@
+package database
+
+import (
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"io"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// driverName is the SQLite driver with the application's SQL functions
+const driverName = "sqlite3_compiler"
+
+func init() {
+	sql.Register(driverName, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("gunzip", gunzipText, true)
+		},
+	})
+}
+
+// gunzipText implements gunzip(data), which returns gzipped blob data as
+// text so snippet search without FTS5 can match compressed code
+func gunzipText(data []byte) (string, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	text, err := io.ReadAll(reader)
+	return string(text), err
+}
@@ -0,0 +1,162 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/online-compiler/backend/internal/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "compiler"
+
+var (
+	// HTTPRequests counts handled HTTP requests per route and status
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Total number of HTTP requests by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	// HTTPDuration tracks HTTP request latency per route and status
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method, route and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// Executions counts code executions per language, backend and status
+	Executions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "executions_total",
+		Help:      "Total number of code executions by language, backend and status.",
+	}, []string{"language", "backend", "status"})
+
+	// ExecutionDuration tracks execution time reported by the backend
+	ExecutionDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "execution_duration_seconds",
+		Help:      "Program execution time reported by the execution backend.",
+		Buckets:   []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	}, []string{"language", "backend"})
+
+	// ExecutionMemory tracks memory usage reported by the backend
+	ExecutionMemory = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "execution_memory_kb",
+		Help:      "Program memory usage in kilobytes reported by the execution backend.",
+		Buckets:   prometheus.ExponentialBuckets(256, 2, 12),
+	}, []string{"language", "backend"})
+
+	// CacheLookups counts result cache lookups by outcome (hit or miss)
+	CacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_lookups_total",
+		Help:      "Total number of execution result cache lookups by result (hit, miss).",
+	}, []string{"result"})
+
+	// RateLimitRejections counts requests rejected by the rate limiter
+	RateLimitRejections = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limit_rejections_total",
+		Help:      "Total number of requests rejected by the rate limiter.",
+	})
+
+	// Judge0Polls counts result polls sent to Judge0
+	Judge0Polls = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "judge0_polls_total",
+		Help:      "Total number of submission result polls sent to Judge0.",
+	})
+
+	// Judge0QueueDepth tracks submissions still queued or processing in Judge0
+	Judge0QueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "judge0_queue_depth",
+		Help:      "Number of submissions accepted by Judge0 that are still queued or processing.",
+	})
+
+	// QueueDepth tracks executions waiting for a worker per backend and priority
+	QueueDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "queue_depth",
+		Help:      "Number of executions waiting for a worker by backend and priority.",
+	}, []string{"backend", "priority"})
+
+	// WorkersBusy tracks workers currently running an execution per backend
+	WorkersBusy = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "workers_busy",
+		Help:      "Number of workers currently running an execution by backend.",
+	}, []string{"backend"})
+
+	// QueueWait tracks how long executions waited for a worker
+	QueueWait = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "queue_wait_seconds",
+		Help:      "Time executions spent queued before a worker picked them up.",
+		Buckets:   []float64{0.001, 0.01, 0.05, 0.1, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"backend", "priority"})
+
+	// QueueRejections counts executions rejected because the queue was full
+	QueueRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "queue_rejections_total",
+		Help:      "Total number of executions rejected because the backend queue was full.",
+	}, []string{"backend"})
+
+	// StreamJobs counts jobs handled by distributed queue workers by outcome
+	StreamJobs = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "stream_jobs_total",
+		Help:      "Total number of distributed queue jobs by outcome (completed, cancelled, reclaimed or dead_lettered).",
+	}, []string{"outcome"})
+
+	// SnippetsPurged counts snippets removed by the sweeper by reason
+	SnippetsPurged = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "snippets_purged_total",
+		Help:      "Total number of snippets purged by the sweeper because they expired or were burned after reading.",
+	}, []string{"reason"})
+)
+
+// ObserveHTTPRequest records a finished HTTP request
+func ObserveHTTPRequest(method, route string, status int, latency time.Duration) {
+	code := strconv.Itoa(status)
+	HTTPRequests.WithLabelValues(method, route, code).Inc()
+	HTTPDuration.WithLabelValues(method, route, code).Observe(latency.Seconds())
+}
+
+// ObserveExecution records the outcome of a code execution
+func ObserveExecution(language, backend string, result *models.ExecuteResponse) {
+	status := "failed"
+	if result != nil && result.Success {
+		status = result.Status
+		if status == "" {
+			status = "ok"
+		}
+	}
+	Executions.WithLabelValues(language, backend, status).Inc()
+
+	if result == nil || !result.Success {
+		return
+	}
+	if result.ExecutionTime > 0 {
+		// ExecutionTime is reported in milliseconds
+		ExecutionDuration.WithLabelValues(language, backend).Observe(result.ExecutionTime / 1000)
+	}
+	if result.MemoryKB > 0 {
+		ExecutionMemory.WithLabelValues(language, backend).Observe(float64(result.MemoryKB))
+	}
+}
+
+// ObserveCacheLookup records a cache hit or miss
+func ObserveCacheLookup(hit bool) {
+	if hit {
+		CacheLookups.WithLabelValues("hit").Inc()
+		return
+	}
+	CacheLookups.WithLabelValues("miss").Inc()
+}
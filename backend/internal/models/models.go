@@ -1,12 +1,21 @@
 package models
 
-import "time"
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
 
 // ExecuteRequest represents a code execution request
 type ExecuteRequest struct {
 	LanguageID int    `json:"language_id" binding:"required"`
 	Code       string `json:"code" binding:"required"`
 	Stdin      string `json:"stdin"`
+	// Priority is "interactive" or "batch"; see the queueing docs
+	Priority string `json:"priority,omitempty"`
+	// CallbackURL receives the final result of asynchronous submissions
+	CallbackURL string `json:"callback_url,omitempty"`
 }
 
 // ExecuteResponse represents a code execution response
@@ -19,11 +28,90 @@ type ExecuteResponse struct {
 	Status        string  `json:"status,omitempty"`
 }
 
+// ProjectExecuteRequest runs a multi-file program. The first file is the
+// entry point.
+type ProjectExecuteRequest struct {
+	LanguageID int           `json:"language_id" binding:"required"`
+	Files      []SnippetFile `json:"files" binding:"required"`
+	Stdin      string        `json:"stdin"`
+	// Priority is "interactive" or "batch"; see the queueing docs
+	Priority string `json:"priority,omitempty"`
+}
+
+// TestCase is one input of a batch execution, optionally with the output it
+// must produce
+type TestCase struct {
+	Stdin          string  `json:"stdin"`
+	ExpectedOutput *string `json:"expected_output,omitempty"`
+}
+
+// BatchExecuteRequest runs one program against several test cases
+type BatchExecuteRequest struct {
+	LanguageID int        `json:"language_id" binding:"required"`
+	Code       string     `json:"code" binding:"required"`
+	TestCases  []TestCase `json:"test_cases" binding:"required"`
+	// Priority is "interactive" or "batch" (the default)
+	Priority string `json:"priority,omitempty"`
+}
+
+// TestCaseResult is the outcome of one test case. Passed is set when the
+// test case has an expected output.
+type TestCaseResult struct {
+	ExecuteResponse
+	Passed *bool `json:"passed,omitempty"`
+}
+
+// BatchExecuteResponse holds the results in test case order. Total counts
+// the test cases with an expected output and Passed those that matched.
+type BatchExecuteResponse struct {
+	Success bool             `json:"success"`
+	Results []TestCaseResult `json:"results"`
+	Passed  int              `json:"passed"`
+	Total   int              `json:"total"`
+}
+
+// Submission represents a tracked code execution
+type Submission struct {
+	ID            string           `json:"id"`
+	Status        string           `json:"status"`
+	LanguageID    int              `json:"language_id"`
+	Priority      string           `json:"priority,omitempty"`
+	QueuePosition int              `json:"queue_position,omitempty"`
+	CallbackURL   string           `json:"callback_url,omitempty"`
+	Result        *ExecuteResponse `json:"result,omitempty"`
+	CreatedAt     time.Time        `json:"created_at"`
+	FinishedAt    *time.Time       `json:"finished_at,omitempty"`
+}
+
+// SubmissionResponse represents an asynchronous submission response
+type SubmissionResponse struct {
+	Success       bool   `json:"success"`
+	SubmissionID  string `json:"submission_id"`
+	Status        string `json:"status"`
+	QueuePosition int    `json:"queue_position,omitempty"`
+}
+
 // Judge0Submission represents Judge0 submission request
 type Judge0Submission struct {
-	SourceCode string `json:"source_code"`
-	LanguageID int    `json:"language_id"`
-	Stdin      string `json:"stdin,omitempty"`
+	SourceCode           string  `json:"source_code"`
+	LanguageID           int     `json:"language_id"`
+	Stdin                string  `json:"stdin,omitempty"`
+	CompilerOptions      string  `json:"compiler_options,omitempty"`
+	CommandLineArguments string  `json:"command_line_arguments,omitempty"`
+	CPUTimeLimit         float64 `json:"cpu_time_limit,omitempty"`
+	MemoryLimit          int     `json:"memory_limit,omitempty"`
+	// ExpectedOutput makes Judge0 judge stdout as Accepted or Wrong Answer
+	ExpectedOutput string `json:"expected_output,omitempty"`
+}
+
+// Judge0BatchSubmission represents a Judge0 batch submission request
+type Judge0BatchSubmission struct {
+	Submissions []Judge0Submission `json:"submissions"`
+}
+
+// Judge0BatchResult represents a Judge0 batch result response
+type Judge0BatchResult struct {
+	Submissions []Judge0Result `json:"submissions"`
 }
 
 // Judge0Response represents Judge0 submission response
@@ -33,6 +121,7 @@ type Judge0Response struct {
 
 // Judge0Result represents Judge0 result
 type Judge0Result struct {
+	Token         string  `json:"token,omitempty"`
 	Stdout        *string `json:"stdout"`
 	Stderr        *string `json:"stderr"`
 	CompileOutput *string `json:"compile_output"`
@@ -52,17 +141,297 @@ type Status struct {
 type Snippet struct {
 	ID        string    `gorm:"primaryKey" json:"id"`
 	Language  string    `gorm:"not null" json:"language"`
-	Code      string    `gorm:"type:text;not null" json:"code"`
+	Code      string    `gorm:"-" json:"code"`
 	Title     string    `json:"title"`
 	CreatedAt time.Time `json:"created_at"`
-	Views     int       `gorm:"default:0" json:"views"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Files holds every file of multi-file snippets, the entry point
+	// first; Language and Code mirror the entry point
+	Files SnippetFiles `gorm:"-" json:"files,omitempty"`
+	// CodeHash and FileBlobs refer to the blobs storing the code of
+	// single-file snippets and the files of multi-file ones
+	CodeHash  string    `json:"-"`
+	FileBlobs BlobFiles `gorm:"type:text" json:"-"`
+	// Tags label the snippet for listings; they are not versioned
+	Tags StringList `gorm:"type:text" json:"tags,omitempty"`
+	// LanguageID is the Judge0 language ID used to run the snippet, 0 if
+	// the language is unknown
+	LanguageID int `gorm:"default:0" json:"language_id,omitempty"`
+	// Stdin and Args are the defaults for runs of the snippet
+	Stdin string     `gorm:"type:text" json:"stdin,omitempty"`
+	Args  StringList `gorm:"type:text" json:"args,omitempty"`
+	// LastRun is the latest run with the default stdin and args, cleared
+	// by updates
+	LastRun *SnippetRun `gorm:"type:text" json:"last_run,omitempty"`
+	Views   int         `gorm:"default:0" json:"views"`
+	// Revision is the number of the snippet's latest revision
+	Revision int `gorm:"default:0" json:"revision"`
+	// Owner is the user who created the snippet, empty for anonymous ones
+	Owner string `gorm:"index" json:"owner,omitempty"`
+	// EditTokenHash is the SHA-256 of the edit token returned at creation
+	EditTokenHash string `json:"-"`
+	// ParentID is the snippet this one was forked from, kept even if the
+	// parent is deleted, and ParentRevision the revision that was copied
+	ParentID       *string `gorm:"index" json:"parent_id,omitempty"`
+	ParentRevision int     `json:"parent_revision,omitempty"`
+	// ForkCount is the number of existing direct forks
+	ForkCount int `gorm:"default:0" json:"fork_count"`
+	// Visibility is public, unlisted, private or password
+	Visibility string `gorm:"default:public;index" json:"visibility"`
+	// PasswordHash is the bcrypt hash for password-protected snippets
+	PasswordHash string `json:"-"`
+	// ExpiresAt is when the snippet is purged, nil to keep it forever
+	ExpiresAt *time.Time `gorm:"index" json:"expires_at,omitempty"`
+	// MaxViews burns the snippet after that many reads, 0 for no limit
+	MaxViews int `gorm:"default:0" json:"max_views,omitempty"`
+	// SearchKey is the snippet's row in the full-text index. The implicit
+	// rowid of a table with a text primary key may change on VACUUM.
+	SearchKey int64 `gorm:"uniqueIndex" json:"-"`
+}
+
+// SnippetSummary describes a snippet without its code
+type SnippetSummary struct {
+	ID             string     `json:"id"`
+	Language       string     `json:"language"`
+	Title          string     `json:"title"`
+	Tags           StringList `gorm:"type:text" json:"tags,omitempty"`
+	Owner          string     `json:"owner,omitempty"`
+	ParentID       *string    `json:"parent_id,omitempty"`
+	ParentRevision int        `json:"parent_revision,omitempty"`
+	Revision       int        `json:"revision"`
+	ForkCount      int        `json:"fork_count"`
+	Views          int        `json:"views"`
+	Visibility     string     `json:"visibility"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// SnippetListResponse holds one page of a snippet listing. NextCursor is
+// set when there are more results.
+type SnippetListResponse struct {
+	Snippets   []SnippetSummary `json:"snippets"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+// SnippetDailyStats counts a snippet's views and runs on one UTC day
+type SnippetDailyStats struct {
+	SnippetID string `gorm:"primaryKey" json:"-"`
+	Day       string `gorm:"primaryKey" json:"day"`
+	Views     int    `json:"views"`
+	Runs      int    `json:"runs"`
+}
+
+// SnippetStats holds a snippet's total views and runs and its daily counts,
+// oldest first
+type SnippetStats struct {
+	SnippetID string              `json:"snippet_id"`
+	Views     int                 `json:"views"`
+	Runs      int                 `json:"runs"`
+	Daily     []SnippetDailyStats `json:"daily"`
+}
+
+// OEmbedResponse describes an embeddable snippet in the oEmbed rich format
+type OEmbedResponse struct {
+	Version      string `json:"version"`
+	Type         string `json:"type"`
+	Title        string `json:"title,omitempty"`
+	AuthorName   string `json:"author_name,omitempty"`
+	ProviderName string `json:"provider_name"`
+	ProviderURL  string `json:"provider_url"`
+	HTML         string `json:"html"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+}
+
+// ForkSnippetRequest optionally picks the revision to fork and a new title.
+// Without options the fork keeps the parent's visibility and password.
+type ForkSnippetRequest struct {
+	Revision int    `json:"revision,omitempty"`
+	Title    string `json:"title,omitempty"`
+	SnippetOptions
+}
+
+// SnippetForksResponse lists a snippet's direct forks
+type SnippetForksResponse struct {
+	SnippetID string           `json:"snippet_id"`
+	ForkCount int              `json:"fork_count"`
+	Forks     []SnippetSummary `json:"forks"`
+}
+
+// SnippetRevision is an immutable copy of a snippet's contents, saved on
+// creation and on every update
+type SnippetRevision struct {
+	ID        uint         `gorm:"primaryKey" json:"-"`
+	SnippetID string       `gorm:"uniqueIndex:idx_snippet_revision;not null" json:"snippet_id"`
+	Revision  int          `gorm:"uniqueIndex:idx_snippet_revision;not null" json:"revision"`
+	Language  string       `gorm:"not null" json:"language"`
+	Code      string       `gorm:"-" json:"code,omitempty"`
+	Files     SnippetFiles `gorm:"-" json:"files,omitempty"`
+	CodeHash  string       `json:"-"`
+	FileBlobs BlobFiles    `gorm:"type:text" json:"-"`
+	Title     string       `json:"title"`
+	Author    string       `json:"author,omitempty"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// SnippetRevisionsResponse lists a snippet's revisions, oldest first,
+// without their code
+type SnippetRevisionsResponse struct {
+	SnippetID string            `json:"snippet_id"`
+	Revisions []SnippetRevision `json:"revisions"`
+}
+
+// SnippetDiffResponse holds a unified diff between two revisions
+type SnippetDiffResponse struct {
+	SnippetID string `json:"snippet_id"`
+	From      int    `json:"from"`
+	To        int    `json:"to"`
+	Diff      string `json:"diff"`
+}
+
+// SnippetOptions controls who can read a snippet and for how long. They
+// are applied when a snippet is created or forked.
+type SnippetOptions struct {
+	// Visibility is public (the default), unlisted, private or password
+	Visibility string `json:"visibility,omitempty"`
+	// Password is required to read password snippets
+	Password string `json:"password,omitempty"`
+	// ExpiresAt or ExpiresIn (seconds) purge the snippet after that time
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	ExpiresIn int        `json:"expires_in,omitempty"`
+	// MaxViews deletes the snippet after that many reads
+	MaxViews int `json:"max_views,omitempty"`
+}
+
+// SnippetFile is one named file of a multi-file snippet or project
+type SnippetFile struct {
+	Name string `json:"name"`
+	// Language defaults to the snippet's language
+	Language string `json:"language,omitempty"`
+	Content  string `json:"content"`
+}
+
+// SnippetFiles are the files of a multi-file snippet
+type SnippetFiles []SnippetFile
+
+// Blob is a code body stored once however many snippets and revisions use
+// it. Hash is the hex SHA-256 of the content, Size its length and Refs the
+// number of references; unreferenced blobs are garbage collected.
+type Blob struct {
+	Hash       string `gorm:"primaryKey"`
+	Size       int    `gorm:"not null"`
+	Compressed bool   `gorm:"not null;default:false"`
+	Data       []byte `gorm:"not null"`
+	Refs       int    `gorm:"not null;default:0;index"`
+	CreatedAt  time.Time
+}
+
+// BlobFile refers to the blob holding the content of a snippet file
+type BlobFile struct {
+	Name     string `json:"name"`
+	Language string `json:"language,omitempty"`
+	Hash     string `json:"hash"`
 }
 
-// SnippetRequest represents a snippet creation request
+// BlobFiles is stored as a JSON column
+type BlobFiles []BlobFile
+
+// Value implements driver.Valuer
+func (f BlobFiles) Value() (driver.Value, error) {
+	if len(f) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(f)
+	return string(data), err
+}
+
+// Scan implements sql.Scanner
+func (f *BlobFiles) Scan(value interface{}) error {
+	return scanJSON(value, f)
+}
+
+// StringList is a list of strings, such as tags or program arguments,
+// stored as a JSON column
+type StringList []string
+
+// Value implements driver.Valuer
+func (l StringList) Value() (driver.Value, error) {
+	if len(l) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(l)
+	return string(data), err
+}
+
+// Scan implements sql.Scanner
+func (l *StringList) Scan(value interface{}) error {
+	return scanJSON(value, l)
+}
+
+// SnippetRun is the result of the latest run of a snippet with its saved
+// stdin and args, stored as a JSON column
+type SnippetRun struct {
+	// Revision is the revision that was run
+	Revision int             `json:"revision"`
+	Result   ExecuteResponse `json:"result"`
+	RanAt    time.Time       `json:"ran_at"`
+}
+
+// Value implements driver.Valuer
+func (r SnippetRun) Value() (driver.Value, error) {
+	data, err := json.Marshal(r)
+	return string(data), err
+}
+
+// Scan implements sql.Scanner
+func (r *SnippetRun) Scan(value interface{}) error {
+	return scanJSON(value, r)
+}
+
+// scanJSON decodes a JSON column into dest, which is reset for NULL or
+// empty values
+func scanJSON(value interface{}, dest interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return fmt.Errorf("cannot scan %T into %T", value, dest)
+	}
+	if len(data) == 0 {
+		data = []byte("null")
+	}
+	return json.Unmarshal(data, dest)
+}
+
+// SnippetRequest represents a snippet creation request. A snippet is either
+// a single language and code or a list of files, the entry point first.
+// Updates replace the contents and title, replace the tags, stdin and args
+// if given (an empty list clears them) and ignore the options.
 type SnippetRequest struct {
-	Language string `json:"language" binding:"required"`
-	Code     string `json:"code" binding:"required"`
-	Title    string `json:"title"`
+	Language string `json:"language,omitempty"`
+	// LanguageID overrides the language ID looked up from Language
+	LanguageID int           `json:"language_id,omitempty"`
+	Code       string        `json:"code,omitempty"`
+	Files      []SnippetFile `json:"files,omitempty"`
+	Title      string        `json:"title"`
+	Tags       []string      `json:"tags"`
+	// Stdin and Args are the defaults for runs of the snippet
+	Stdin *string  `json:"stdin,omitempty"`
+	Args  []string `json:"args"`
+	SnippetOptions
+}
+
+// SnippetRunRequest overrides a snippet's saved stdin or args for one run
+type SnippetRunRequest struct {
+	Stdin *string  `json:"stdin,omitempty"`
+	Args  []string `json:"args"`
+	// Priority is "interactive" or "batch"; see the queueing docs
+	Priority string `json:"priority,omitempty"`
 }
 
 // SnippetResponse represents a snippet response
@@ -70,14 +439,46 @@ type SnippetResponse struct {
 	Success   bool   `json:"success"`
 	SnippetID string `json:"snippet_id,omitempty"`
 	ShareURL  string `json:"share_url,omitempty"`
-	Error     string `json:"error,omitempty"`
+	// EditToken authorizes updates and deletes; it is only returned once
+	EditToken string `json:"edit_token,omitempty"`
+	// ParentID is set for forks
+	ParentID string `json:"parent_id,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// WebhookDelivery records one attempt to deliver a submission result to its
+// callback URL
+type WebhookDelivery struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	DeliveryID   string    `gorm:"index;not null" json:"delivery_id"`
+	SubmissionID string    `gorm:"index;not null" json:"submission_id"`
+	URL          string    `gorm:"not null" json:"url"`
+	Attempt      int       `json:"attempt"`
+	StatusCode   int       `json:"status_code,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	DurationMS   int64     `json:"duration_ms"`
+	Delivered    bool      `json:"delivered"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// WebhookDeliveriesResponse lists the delivery attempts of a submission
+type WebhookDeliveriesResponse struct {
+	Success    bool              `json:"success"`
+	Deliveries []WebhookDelivery `json:"deliveries"`
+}
+
+// WebhookPayload is the body POSTed to callback URLs
+type WebhookPayload struct {
+	Event      string     `json:"event"`
+	Submission Submission `json:"submission"`
 }
 
 // ErrorResponse represents an error response
 type ErrorResponse struct {
-	Success bool   `json:"success"`
-	Error   string `json:"error"`
-	Code    string `json:"code,omitempty"`
+	Success   bool   `json:"success"`
+	Error     string `json:"error"`
+	Code      string `json:"code,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
 }
 
 // HealthResponse represents health check response
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"context"
+	"strings"
+
+	"github.com/online-compiler/backend/internal/models"
+)
+
+// BatchExecutor is implemented by executors that can run several test cases
+// of one program more efficiently than one execution at a time
+type BatchExecutor interface {
+	ExecuteBatch(ctx context.Context, languageID int, code string, testCases []models.TestCase) ([]*models.ExecuteResponse, error)
+}
+
+// ExecuteTestCases runs code against every test case, in order. Executors
+// without batch support run the test cases one after another.
+func ExecuteTestCases(ctx context.Context, executor Executor, languageID int, code string, testCases []models.TestCase) ([]*models.ExecuteResponse, error) {
+	if batch, ok := executor.(BatchExecutor); ok {
+		return batch.ExecuteBatch(ctx, languageID, code, testCases)
+	}
+
+	results := make([]*models.ExecuteResponse, len(testCases))
+	for i, testCase := range testCases {
+		if ctx.Err() != nil {
+			results[i] = cancelledResponse()
+			continue
+		}
+		result, err := executor.ExecuteCode(ctx, languageID, code, testCase.Stdin)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = result
+	}
+	return results, nil
+}
+
+// passingStatuses are the statuses of executions that ran to completion
+var passingStatuses = map[string]bool{"": true, "Accepted": true, "Completed": true}
+
+// JudgeTestCase reports whether a result satisfies the test case's expected
+// output. Trailing whitespace on each line and trailing blank lines are
+// ignored. Test cases without an expected output are not judged.
+func JudgeTestCase(testCase models.TestCase, result *models.ExecuteResponse) *bool {
+	if testCase.ExpectedOutput == nil {
+		return nil
+	}
+	passed := result.Success && passingStatuses[result.Status] &&
+		normalizeOutput(result.Output) == normalizeOutput(*testCase.ExpectedOutput)
+	return &passed
+}
+
+// normalizeOutput strips trailing whitespace from every line and the output
+func normalizeOutput(output string) string {
+	lines := strings.Split(strings.ReplaceAll(output, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n")
+}
+
+// cancelledResponses returns n cancelled responses
+func cancelledResponses(n int) []*models.ExecuteResponse {
+	responses := make([]*models.ExecuteResponse, n)
+	for i := range responses {
+		responses[i] = cancelledResponse()
+	}
+	return responses
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+
+	"github.com/online-compiler/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// DefaultBlobCompressMinSize is the size from which blobs are compressed
+// unless configured otherwise
+const DefaultBlobCompressMinSize = 1024
+
+// BlobStore stores code bodies once, keyed by the SHA-256 of their
+// content, and counts their references. Blobs of at least CompressMinSize
+// bytes are gzipped when that makes them smaller; 0 disables compression.
+// Methods take the *gorm.DB to run on so they can join the caller's
+// transaction.
+type BlobStore struct {
+	CompressMinSize int
+}
+
+// NewBlobStore creates a blob store compressing blobs of at least
+// compressMinSize bytes
+func NewBlobStore(compressMinSize int) *BlobStore {
+	return &BlobStore{CompressMinSize: compressMinSize}
+}
+
+// BlobHash returns the key of a blob's content
+func BlobHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// Put adds a reference to the blob holding content, storing it if it is
+// new, and returns its hash
+func (b *BlobStore) Put(db *gorm.DB, content string) (string, error) {
+	hash := BlobHash(content)
+	result := db.Model(&models.Blob{}).Where("hash = ?", hash).UpdateColumn("refs", gorm.Expr("refs + 1"))
+	if result.Error != nil {
+		return "", result.Error
+	}
+	if result.RowsAffected > 0 {
+		return hash, nil
+	}
+
+	blob := &models.Blob{Hash: hash, Size: len(content), Data: []byte(content), Refs: 1}
+	if b.CompressMinSize > 0 && len(content) >= b.CompressMinSize {
+		compressed, err := gzipBytes(blob.Data)
+		if err != nil {
+			return "", err
+		}
+		if len(compressed) < len(blob.Data) {
+			blob.Data, blob.Compressed = compressed, true
+		}
+	}
+	return hash, db.Create(blob).Error
+}
+
+// Release drops one reference to each of hashes, which may repeat. Blobs
+// left without references are removed by Collect.
+func (b *BlobStore) Release(db *gorm.DB, hashes ...string) error {
+	counts := make(map[string]int)
+	for _, hash := range hashes {
+		counts[hash]++
+	}
+	for _, hash := range slices.Sorted(maps.Keys(counts)) {
+		err := db.Model(&models.Blob{}).Where("hash = ?", hash).
+			UpdateColumn("refs", gorm.Expr("refs - ?", counts[hash])).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get returns the contents of the blobs with the given hashes
+func (b *BlobStore) Get(db *gorm.DB, hashes ...string) (map[string]string, error) {
+	var blobs []models.Blob
+	if err := db.Where("hash IN ?", hashes).Find(&blobs).Error; err != nil {
+		return nil, err
+	}
+
+	contents := make(map[string]string, len(blobs))
+	for _, blob := range blobs {
+		data := blob.Data
+		if blob.Compressed {
+			var err error
+			if data, err = gunzipBytes(data); err != nil {
+				return nil, fmt.Errorf("blob %s: %w", blob.Hash, err)
+			}
+		}
+		contents[blob.Hash] = string(data)
+	}
+	for _, hash := range hashes {
+		if _, exists := contents[hash]; !exists {
+			return nil, fmt.Errorf("blob %s is missing", hash)
+		}
+	}
+	return contents, nil
+}
+
+// Collect deletes the blobs that are no longer referenced and returns how
+// many were removed
+func (b *BlobStore) Collect(ctx context.Context, db *gorm.DB) (int64, error) {
+	result := db.WithContext(ctx).Where("refs <= 0").Delete(&models.Blob{})
+	return result.RowsAffected, result.Error
+}
+
+// gzipBytes compresses data
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// gunzipBytes decompresses data written by gzipBytes
+func gunzipBytes(data []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
@@ -3,93 +3,152 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/online-compiler/backend/configs"
+	"github.com/online-compiler/backend/internal/metrics"
+	"github.com/online-compiler/backend/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-var RedisClient *redis.Client
-var ctx = context.Background()
+// ErrCacheMiss is returned when a result is not cached
+var ErrCacheMiss = errors.New("cache miss")
 
-// InitRedis initializes Redis connection
-func InitRedis() error {
-	RedisClient = redis.NewClient(&redis.Options{
-		Addr:     configs.AppConfig.RedisURL,
-		Password: configs.AppConfig.RedisPassword,
-		DB:       configs.AppConfig.RedisDB,
+// ResultCache stores execution results by code hash
+type ResultCache interface {
+	GetCachedResult(ctx context.Context, codeHash string) ([]byte, error)
+	CacheResult(ctx context.Context, codeHash string, result interface{}) error
+}
+
+// RateLimiter decides whether a client may perform another request
+type RateLimiter interface {
+	CheckRateLimit(ctx context.Context, ip string) (bool, error)
+}
+
+// RateLimitSettings provides the current rate limit
+type RateLimitSettings interface {
+	RateLimit() (requests, window int)
+}
+
+// NewRedisClient connects to Redis
+func NewRedisClient(ctx context.Context, cfg *configs.Config) (*redis.Client, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.RedisURL,
+		Password: cfg.RedisPassword,
+		DB:       cfg.RedisDB,
 	})
 
 	// Test connection
-	_, err := RedisClient.Ping(ctx).Result()
+	_, err := client.Ping(ctx).Result()
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to Redis: %v", err)
+	}
+
+	return client, nil
+}
+
+// RedisCache caches execution results in Redis. A nil client disables caching.
+type RedisCache struct {
+	Client *redis.Client
+}
+
+// NewRedisCache creates a result cache backed by client (may be nil)
+func NewRedisCache(client *redis.Client) *RedisCache {
+	return &RedisCache{Client: client}
+}
+
+// CacheResult caches execution result
+func (r *RedisCache) CacheResult(ctx context.Context, codeHash string, result interface{}) error {
+	// If Redis is not connected, skip caching
+	if r.Client == nil {
+		return nil
+	}
+
+	key := fmt.Sprintf("cache:result:%s", codeHash)
+	data, err := json.Marshal(result)
 	if err != nil {
-		return fmt.Errorf("failed to connect to Redis: %v", err)
+		return err
 	}
 
-	return nil
+	return r.Client.Set(ctx, key, data, time.Hour).Err()
+}
+
+// GetCachedResult retrieves cached execution result
+func (r *RedisCache) GetCachedResult(ctx context.Context, codeHash string) ([]byte, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "cache.lookup")
+	defer span.End()
+
+	// If Redis is not connected, return cache miss
+	if r.Client == nil {
+		span.SetAttributes(attribute.Bool("cache.hit", false))
+		return nil, ErrCacheMiss
+	}
+
+	key := fmt.Sprintf("cache:result:%s", codeHash)
+	data, err := r.Client.Get(ctx, key).Bytes()
+	metrics.ObserveCacheLookup(err == nil)
+	span.SetAttributes(attribute.Bool("cache.hit", err == nil))
+	if err == redis.Nil {
+		return nil, ErrCacheMiss
+	}
+	if err != nil {
+		tracing.RecordError(span, err)
+	}
+	return data, err
+}
+
+// RedisRateLimiter limits requests per client IP using Redis counters. A nil
+// client allows every request.
+type RedisRateLimiter struct {
+	Client   *redis.Client
+	Settings RateLimitSettings
+}
+
+// NewRedisRateLimiter creates a rate limiter backed by client (may be nil)
+func NewRedisRateLimiter(client *redis.Client, settings RateLimitSettings) *RedisRateLimiter {
+	return &RedisRateLimiter{Client: client, Settings: settings}
 }
 
 // CheckRateLimit checks if the IP has exceeded rate limit
-func CheckRateLimit(ip string) (bool, error) {
+func (r *RedisRateLimiter) CheckRateLimit(ctx context.Context, ip string) (allowed bool, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "ratelimit.check")
+	defer func() {
+		span.SetAttributes(attribute.Bool("ratelimit.allowed", allowed))
+		span.End()
+	}()
+
 	// If Redis is not connected, allow all requests
-	if RedisClient == nil {
+	if r.Client == nil {
 		return true, nil
 	}
 
 	key := fmt.Sprintf("rate:execute:%s", ip)
 
-	count, err := RedisClient.Get(ctx, key).Int()
+	limit, window := r.Settings.RateLimit()
+
+	count, err := r.Client.Get(ctx, key).Int()
 	if err == redis.Nil {
 		// First request
-		err = RedisClient.Set(ctx, key, 1, time.Duration(configs.AppConfig.RateLimitWindow)*time.Second).Err()
+		err = r.Client.Set(ctx, key, 1, time.Duration(window)*time.Second).Err()
 		return true, err
 	} else if err != nil {
 		// If Redis error, allow request (fail open)
 		return true, nil
 	}
 
-	if count >= configs.AppConfig.RateLimitRequests {
+	if count >= limit {
 		return false, nil
 	}
 
 	// Increment counter
-	err = RedisClient.Incr(ctx, key).Err()
+	err = r.Client.Incr(ctx, key).Err()
 	if err != nil {
 		// If Redis error, allow request (fail open)
 		return true, nil
 	}
 	return true, nil
 }
-
-// CacheResult caches execution result
-func CacheResult(codeHash string, result interface{}) error {
-	// If Redis is not connected, skip caching
-	if RedisClient == nil {
-		return nil
-	}
-
-	key := fmt.Sprintf("cache:result:%s", codeHash)
-	data, err := json.Marshal(result)
-	if err != nil {
-		return err
-	}
-
-	return RedisClient.Set(ctx, key, data, time.Hour).Err()
-}
-
-// GetCachedResult retrieves cached execution result
-func GetCachedResult(codeHash string) ([]byte, error) {
-	// If Redis is not connected, return cache miss
-	if RedisClient == nil {
-		return nil, redis.Nil
-	}
-
-	key := fmt.Sprintf("cache:result:%s", codeHash)
-	return RedisClient.Get(ctx, key).Bytes()
-}
-
-// GetRedisClient returns the Redis client instance
-func GetRedisClient() *redis.Client {
-	return RedisClient
-}
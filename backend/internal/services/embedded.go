@@ -0,0 +1,157 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/online-compiler/backend/internal/models"
+	"github.com/online-compiler/backend/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Defaults for embedded interpreters without explicit limits
+const (
+	embeddedDefaultTimeLimit = 5 * time.Second
+	embeddedDefaultMaxSteps  = 100_000_000
+	embeddedMaxOutputBytes   = 1 << 20
+)
+
+// embeddedInterpreters maps Judge0 language IDs to interpreters
+var embeddedInterpreters = map[int]string{
+	63: "javascript",
+	64: "lua",
+}
+
+// scriptIO is the in-memory stdio of an embedded execution
+type scriptIO struct {
+	stdin  *bufio.Reader
+	stdout *limitedBuffer
+	stderr *limitedBuffer
+}
+
+// readLine returns the next stdin line without its newline
+func (s *scriptIO) readLine() (string, bool) {
+	line, err := s.stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return "", false
+	}
+	return strings.TrimRight(line, "\r\n"), true
+}
+
+// compileError marks errors raised before the script started running
+type compileError struct {
+	err error
+}
+
+func (e *compileError) Error() string { return e.err.Error() }
+
+// interpreterFunc runs code until it finishes or ctx is done
+type interpreterFunc func(ctx context.Context, code string, stdio *scriptIO, maxSteps uint64) error
+
+// EmbeddedService runs scripts with pure-Go interpreters inside the server
+// process: goja for JavaScript, gopher-lua for Lua and starlark-go for
+// Starlark. Scripts only see in-memory stdio and a restricted standard
+// library.
+type EmbeddedService struct {
+	Languages LanguageSettings
+
+	interpreters map[string]interpreterFunc
+}
+
+// NewEmbeddedService creates an embedded-interpreter executor
+func NewEmbeddedService(languages LanguageSettings) *EmbeddedService {
+	return &EmbeddedService{
+		Languages: languages,
+		interpreters: map[string]interpreterFunc{
+			"javascript": runJavaScript,
+			"lua":        runLua,
+			"starlark":   runStarlark,
+		},
+	}
+}
+
+// ExecuteCode runs code with the interpreter for the language
+func (e *EmbeddedService) ExecuteCode(ctx context.Context, languageID int, code, stdin string) (*models.ExecuteResponse, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "embedded.execute")
+	span.SetAttributes(attribute.Int("language_id", languageID))
+	defer span.End()
+
+	langConfig := e.Languages.Language(languageID)
+	name := langConfig.Interpreter
+	if name == "" {
+		name = embeddedInterpreters[languageID]
+	}
+	run, exists := e.interpreters[name]
+	if !exists {
+		return &models.ExecuteResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Language ID %d has no embedded interpreter", languageID),
+		}, nil
+	}
+	span.SetAttributes(attribute.String("embedded.interpreter", name))
+
+	timeLimit := embeddedDefaultTimeLimit
+	if langConfig.TimeLimit > 0 {
+		timeLimit = time.Duration(langConfig.TimeLimit * float64(time.Second))
+	}
+	maxSteps := uint64(embeddedDefaultMaxSteps)
+	if langConfig.MaxSteps > 0 {
+		maxSteps = langConfig.MaxSteps
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, timeLimit)
+	defer cancel()
+
+	stdio := &scriptIO{
+		stdin:  bufio.NewReader(strings.NewReader(stdin)),
+		stdout: &limitedBuffer{limit: embeddedMaxOutputBytes},
+		stderr: &limitedBuffer{limit: embeddedMaxOutputBytes},
+	}
+
+	start := time.Now()
+	err := run(runCtx, code, stdio, maxSteps)
+	elapsed := time.Since(start)
+
+	if ctx.Err() != nil {
+		return cancelledResponse(), nil
+	}
+
+	response := &models.ExecuteResponse{
+		Success:       true,
+		Status:        "Completed",
+		Output:        stdio.stdout.String(),
+		Error:         stdio.stderr.String(),
+		ExecutionTime: float64(elapsed.Microseconds()) / 1000,
+	}
+
+	var compileErr *compileError
+	switch {
+	case runCtx.Err() == context.DeadlineExceeded:
+		response.Status = "Time Limit Exceeded"
+		response.Error = appendLine(response.Error, fmt.Sprintf("Execution exceeded the time limit of %s", timeLimit))
+	case errors.Is(err, errStepBudgetExceeded):
+		response.Status = "Time Limit Exceeded"
+		response.Error = appendLine(response.Error, fmt.Sprintf("Execution exceeded the budget of %d steps", maxSteps))
+	case errors.As(err, &compileErr):
+		response.Status = "Compilation Error"
+		response.Error = appendLine(response.Error, compileErr.Error())
+	case err != nil:
+		response.Status = "Runtime Error"
+		response.Error = appendLine(response.Error, err.Error())
+	}
+
+	span.SetAttributes(attribute.String("embedded.status", response.Status))
+	return response, nil
+}
+
+// appendLine joins stderr output and an error message
+func appendLine(text, line string) string {
+	if text == "" {
+		return line
+	}
+	return strings.TrimRight(text, "\n") + "\n" + line
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/dop251/goja"
+)
+
+// jsMaxCallStackSize bounds recursion in JavaScript programs
+const jsMaxCallStackSize = 10000
+
+// runJavaScript runs code with goja. console.log/info write to stdout,
+// console.error/warn to stderr, and readline() returns the next stdin line
+// or undefined at end of input.
+func runJavaScript(ctx context.Context, code string, stdio *scriptIO, _ uint64) error {
+	program, err := goja.Compile("main.js", code, false)
+	if err != nil {
+		return &compileError{err}
+	}
+
+	vm := goja.New()
+	vm.SetMaxCallStackSize(jsMaxCallStackSize)
+
+	console := vm.NewObject()
+	console.Set("log", jsPrinter(stdio.stdout))
+	console.Set("info", jsPrinter(stdio.stdout))
+	console.Set("error", jsPrinter(stdio.stderr))
+	console.Set("warn", jsPrinter(stdio.stderr))
+	vm.Set("console", console)
+	vm.Set("readline", func() goja.Value {
+		line, ok := stdio.readLine()
+		if !ok {
+			return goja.Undefined()
+		}
+		return vm.ToValue(line)
+	})
+
+	// Interrupt the VM on timeout or cancellation
+	stop := context.AfterFunc(ctx, func() { vm.Interrupt(ctx.Err()) })
+	defer stop()
+
+	_, err = vm.RunProgram(program)
+	return err
+}
+
+// jsPrinter writes its arguments separated by spaces, formatting objects as
+// JSON like Node.js does for plain data
+func jsPrinter(w io.Writer) func(call goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		parts := make([]string, len(call.Arguments))
+		for i, arg := range call.Arguments {
+			parts[i] = arg.String()
+			if _, isObject := arg.(*goja.Object); isObject {
+				if _, isFunc := goja.AssertFunction(arg); !isFunc {
+					if data, err := json.Marshal(arg.Export()); err == nil {
+						parts[i] = string(data)
+					}
+				}
+			}
+		}
+		fmt.Fprintln(w, strings.Join(parts, " "))
+		return goja.Undefined()
+	}
+}
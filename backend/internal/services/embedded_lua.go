@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// Lua VM limits
+const (
+	luaCallStackSize   = 1024
+	luaRegistryMaxSize = 1 << 20
+)
+
+// runLua runs code with gopher-lua. Only the base, table, string and math
+// libraries are loaded; io.write and io.read use the in-memory stdio.
+func runLua(ctx context.Context, code string, stdio *scriptIO, _ uint64) error {
+	L := lua.NewState(lua.Options{
+		SkipOpenLibs:    true,
+		CallStackSize:   luaCallStackSize,
+		RegistryMaxSize: luaRegistryMaxSize,
+	})
+	defer L.Close()
+
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		L.Push(L.NewFunction(lib.open))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+
+	// Remove base functions that reach the host filesystem
+	L.SetGlobal("dofile", lua.LNil)
+	L.SetGlobal("loadfile", lua.LNil)
+
+	L.SetGlobal("print", L.NewFunction(func(L *lua.LState) int {
+		fmt.Fprintln(stdio.stdout, strings.Join(luaArgs(L), "\t"))
+		return 0
+	}))
+
+	ioTable := L.NewTable()
+	L.SetField(ioTable, "write", L.NewFunction(func(L *lua.LState) int {
+		io.WriteString(stdio.stdout, strings.Join(luaArgs(L), ""))
+		return 0
+	}))
+	L.SetField(ioTable, "read", L.NewFunction(func(L *lua.LState) int {
+		L.Push(luaRead(L, stdio))
+		return 1
+	}))
+	L.SetGlobal("io", ioTable)
+
+	fn, err := L.LoadString(code)
+	if err != nil {
+		return &compileError{err}
+	}
+
+	L.SetContext(ctx)
+	L.Push(fn)
+	return L.PCall(0, lua.MultRet, nil)
+}
+
+// luaArgs converts the function arguments to strings like tostring
+func luaArgs(L *lua.LState) []string {
+	args := make([]string, L.GetTop())
+	for i := range args {
+		args[i] = L.ToStringMeta(L.Get(i + 1)).String()
+	}
+	return args
+}
+
+// luaRead implements io.read for the "l", "n" and "a" formats
+func luaRead(L *lua.LState, stdio *scriptIO) lua.LValue {
+	format := strings.TrimPrefix(L.OptString(1, "l"), "*")
+
+	switch {
+	case strings.HasPrefix(format, "a"):
+		rest, _ := io.ReadAll(stdio.stdin)
+		return lua.LString(rest)
+	case strings.HasPrefix(format, "n"):
+		var n float64
+		if _, err := fmt.Fscan(stdio.stdin, &n); err != nil {
+			return lua.LNil
+		}
+		return lua.LNumber(n)
+	default:
+		line, ok := stdio.readLine()
+		if !ok {
+			return lua.LNil
+		}
+		return lua.LString(line)
+	}
+}
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.starlark.net/lib/json"
+	"go.starlark.net/lib/math"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// errStepBudgetExceeded is returned when a script runs out of steps
+var errStepBudgetExceeded = errors.New("step budget exceeded")
+
+// starlarkOptions enables the dialect features snippets commonly use
+var starlarkOptions = &syntax.FileOptions{
+	Set:             true,
+	While:           true,
+	TopLevelControl: true,
+	GlobalReassign:  true,
+	Recursion:       true,
+}
+
+// runStarlark runs code with starlark-go, stopping after maxSteps
+// computation steps. input() returns the next stdin line or None at end of
+// input; the json and math modules are predeclared.
+func runStarlark(ctx context.Context, code string, stdio *scriptIO, maxSteps uint64) error {
+	predeclared := starlark.StringDict{
+		"json": json.Module,
+		"math": math.Module,
+		"input": starlark.NewBuiltin("input", func(_ *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			if err := starlark.UnpackArgs(fn.Name(), args, kwargs); err != nil {
+				return nil, err
+			}
+			line, ok := stdio.readLine()
+			if !ok {
+				return starlark.None, nil
+			}
+			return starlark.String(line), nil
+		}),
+	}
+
+	_, program, err := starlark.SourceProgramOptions(starlarkOptions, "main.star", code, predeclared.Has)
+	if err != nil {
+		return &compileError{err}
+	}
+
+	thread := &starlark.Thread{
+		Name:  "main",
+		Print: func(_ *starlark.Thread, msg string) { fmt.Fprintln(stdio.stdout, msg) },
+	}
+	thread.SetMaxExecutionSteps(maxSteps)
+
+	// Cancel the thread on timeout or cancellation
+	stop := context.AfterFunc(ctx, func() { thread.Cancel(ctx.Err().Error()) })
+	defer stop()
+
+	_, err = program.Init(thread, predeclared)
+	if err != nil && thread.ExecutionSteps() >= maxSteps {
+		return errStepBudgetExceeded
+	}
+
+	var evalErr *starlark.EvalError
+	if errors.As(err, &evalErr) {
+		return errors.New(evalErr.Backtrace())
+	}
+	return err
+}
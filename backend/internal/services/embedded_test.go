@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/online-compiler/backend/configs"
+)
+
+const (
+	javascriptLanguage = 63
+	luaLanguage        = 64
+)
+
+func TestEmbeddedService(t *testing.T) {
+	tests := []struct {
+		name       string
+		languageID int
+		lang       configs.LanguageConfig
+		code       string
+		stdin      string
+		wantStatus string
+		wantOutput string
+		wantError  string
+	}{
+		{"javascript", javascriptLanguage, configs.LanguageConfig{}, `console.log("hi", readline(), {a: 1}); console.error("warn")`, "there\n", "Completed", "hi there {\"a\":1}\n", "warn"},
+		{"javascript syntax error", javascriptLanguage, configs.LanguageConfig{}, `console.log(`, "", "Compilation Error", "", "SyntaxError"},
+		{"javascript exception", javascriptLanguage, configs.LanguageConfig{}, `throw new Error("boom")`, "", "Runtime Error", "", "boom"},
+		{"javascript time limit", javascriptLanguage, configs.LanguageConfig{TimeLimit: 0.1}, `while (true) {}`, "", "Time Limit Exceeded", "", "time limit"},
+
+		{"lua", luaLanguage, configs.LanguageConfig{}, `local n = io.read("n"); print("double", n * 2); io.write("x", 1, "\n")`, "21\n", "Completed", "double\t42\nx1\n", ""},
+		{"lua syntax error", luaLanguage, configs.LanguageConfig{}, `print(`, "", "Compilation Error", "", "EOF"},
+		{"lua no filesystem", luaLanguage, configs.LanguageConfig{}, `dofile("/etc/passwd")`, "", "Runtime Error", "", "non-function"},
+		{"lua time limit", luaLanguage, configs.LanguageConfig{TimeLimit: 0.1}, `while true do end`, "", "Time Limit Exceeded", "", "time limit"},
+
+		{"starlark", 99, configs.LanguageConfig{Interpreter: "starlark"}, "name = input()\nprint('hello', name, math.sqrt(16))", "ada\n", "Completed", "hello ada 4.0\n", ""},
+		{"starlark syntax error", 99, configs.LanguageConfig{Interpreter: "starlark"}, "def f(:", "", "Compilation Error", "", "got"},
+		{"starlark step budget", 99, configs.LanguageConfig{Interpreter: "starlark", MaxSteps: 1000}, "while True:\n    pass", "", "Time Limit Exceeded", "", "1000 steps"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := NewEmbeddedService(staticLanguages(tt.lang))
+
+			response, err := service.ExecuteCode(context.Background(), tt.languageID, tt.code, tt.stdin)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if response.Status != tt.wantStatus || response.Output != tt.wantOutput || !strings.Contains(response.Error, tt.wantError) {
+				t.Errorf("response = %+v, want status %q output %q error containing %q", response, tt.wantStatus, tt.wantOutput, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestEmbeddedServiceCancel(t *testing.T) {
+	service := NewEmbeddedService(staticLanguages(configs.LanguageConfig{}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	response, err := service.ExecuteCode(ctx, javascriptLanguage, "while (true) {}", "")
+	if err != nil || response.Status != "Cancelled" {
+		t.Errorf("response = %+v, err = %v, want cancelled", response, err)
+	}
+}
+
+func TestEmbeddedServiceUnknownLanguage(t *testing.T) {
+	service := NewEmbeddedService(staticLanguages(configs.LanguageConfig{}))
+
+	response, err := service.ExecuteCode(context.Background(), 71, "print(1)", "")
+	if err != nil || response.Success {
+		t.Errorf("response = %+v, err = %v, want unsuccessful response", response, err)
+	}
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/online-compiler/backend/configs"
+	"github.com/online-compiler/backend/internal/models"
+)
+
+// Executor runs code on an execution backend
+type Executor interface {
+	ExecuteCode(ctx context.Context, languageID int, code, stdin string) (*models.ExecuteResponse, error)
+}
+
+type argsKey struct{}
+
+// WithArgs attaches command line arguments that executors pass to the
+// program after the language's configured arguments
+func WithArgs(ctx context.Context, args []string) context.Context {
+	return context.WithValue(ctx, argsKey{}, args)
+}
+
+// ArgsFrom returns the program arguments attached to ctx
+func ArgsFrom(ctx context.Context) []string {
+	args, _ := ctx.Value(argsKey{}).([]string)
+	return args
+}
+
+// LanguageSettings provides per-language execution settings
+type LanguageSettings interface {
+	Language(languageID int) configs.LanguageConfig
+	BackendFor(languageID int) string
+}
+
+// ExecutorRegistry maps backend names to executors
+type ExecutorRegistry struct {
+	settings  LanguageSettings
+	executors map[string]Executor
+}
+
+// NewExecutorRegistry creates an empty registry that routes languages using
+// settings
+func NewExecutorRegistry(settings LanguageSettings) *ExecutorRegistry {
+	return &ExecutorRegistry{
+		settings:  settings,
+		executors: make(map[string]Executor),
+	}
+}
+
+// Register adds or replaces the executor for a backend name
+func (r *ExecutorRegistry) Register(backend string, executor Executor) {
+	r.executors[backend] = executor
+}
+
+// Get returns the executor registered for a backend name
+func (r *ExecutorRegistry) Get(backend string) (Executor, bool) {
+	executor, exists := r.executors[backend]
+	return executor, exists
+}
+
+// ExecutorFor returns the executor configured for a language along with the
+// backend name used in metrics
+func (r *ExecutorRegistry) ExecutorFor(languageID int) (Executor, string, error) {
+	backend := r.settings.BackendFor(languageID)
+	executor, exists := r.executors[backend]
+	if !exists {
+		return nil, backend, fmt.Errorf("execution backend %q is not available", backend)
+	}
+	return executor, backend, nil
+}
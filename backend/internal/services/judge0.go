@@ -2,48 +2,90 @@ package services
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
 
-	"github.com/online-compiler/backend/configs"
+	"github.com/online-compiler/backend/internal/metrics"
 	"github.com/online-compiler/backend/internal/models"
+	"github.com/online-compiler/backend/internal/tracing"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // Judge0Service handles Judge0 API interactions
 type Judge0Service struct {
-	BaseURL string
-	Client  *http.Client
+	BaseURL      string
+	Client       *http.Client
+	Languages    LanguageSettings
+	PollInterval time.Duration
+	MaxPolls     int
 }
 
 // NewJudge0Service creates a new Judge0 service
-func NewJudge0Service() *Judge0Service {
+func NewJudge0Service(baseURL string, timeout time.Duration, languages LanguageSettings) *Judge0Service {
 	return &Judge0Service{
-		BaseURL: configs.AppConfig.Judge0URL,
+		BaseURL: baseURL,
 		Client: &http.Client{
-			Timeout: time.Duration(configs.AppConfig.Judge0Timeout) * time.Second,
+			Timeout:   timeout,
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
 		},
+		Languages:    languages,
+		PollInterval: time.Second,
+		MaxPolls:     10,
 	}
 }
 
-// SubmitCode submits code to Judge0 for execution
-func (j *Judge0Service) SubmitCode(languageID int, code, stdin string) (string, error) {
-	submission := models.Judge0Submission{
-		SourceCode: code,
-		LanguageID: languageID,
-		Stdin:      stdin,
+// Ping checks that Judge0 accepts submissions
+func (j *Judge0Service) Ping(ctx context.Context) error {
+	_, err := j.SubmitCode(ctx, 71, "print('health')", "")
+	return err
+}
+
+// newSubmission builds a submission with the language's configured limits
+// and arguments followed by those attached to ctx
+func (j *Judge0Service) newSubmission(ctx context.Context, languageID int, code, stdin string) models.Judge0Submission {
+	langConfig := j.Languages.Language(languageID)
+	return models.Judge0Submission{
+		SourceCode:           code,
+		LanguageID:           languageID,
+		Stdin:                stdin,
+		CompilerOptions:      langConfig.CompilerFlags,
+		CommandLineArguments: strings.Join(slices.Concat(langConfig.Args, ArgsFrom(ctx)), " "),
+		CPUTimeLimit:         langConfig.TimeLimit,
+		MemoryLimit:          langConfig.MemoryLimitKB,
 	}
+}
 
-	jsonData, err := json.Marshal(submission)
+// SubmitCode submits code to Judge0 for execution
+func (j *Judge0Service) SubmitCode(ctx context.Context, languageID int, code, stdin string) (token string, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "judge0.submit")
+	span.SetAttributes(attribute.Int("language_id", languageID))
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
+	jsonData, err := json.Marshal(j.newSubmission(ctx, languageID, code, stdin))
 	if err != nil {
 		return "", err
 	}
 
 	url := fmt.Sprintf("%s/submissions?base64_encoded=false&wait=false", j.BaseURL)
-	fmt.Printf("DEBUG: Submitting to URL: %s\n", url)
-	resp, err := j.Client.Post(url, "application/json", bytes.NewBuffer(jsonData))
+	slog.DebugContext(ctx, "submitting to Judge0", "url", url, "language_id", languageID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := j.Client.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("failed to submit to Judge0: %v", err)
 	}
@@ -59,49 +101,86 @@ func (j *Judge0Service) SubmitCode(languageID int, code, stdin string) (string,
 		return "", err
 	}
 
+	span.SetAttributes(attribute.String("judge0.token", result.Token))
 	return result.Token, nil
 }
 
 // GetSubmissionResult polls Judge0 for submission result
-func (j *Judge0Service) GetSubmissionResult(token string) (*models.Judge0Result, error) {
-	maxPolls := 10
-	pollInterval := time.Second
+func (j *Judge0Service) GetSubmissionResult(ctx context.Context, token string) (*models.Judge0Result, error) {
 
-	for i := 0; i < maxPolls; i++ {
-		url := fmt.Sprintf("%s/submissions/%s?base64_encoded=false", j.BaseURL, token)
-		resp, err := j.Client.Get(url)
-		if err != nil {
-			return nil, err
-		}
-
-		if resp.StatusCode != http.StatusOK {
-			resp.Body.Close()
-			return nil, fmt.Errorf("failed to get submission result: status %d", resp.StatusCode)
-		}
+	// Track submissions that are still waiting on Judge0
+	metrics.Judge0QueueDepth.Inc()
+	defer metrics.Judge0QueueDepth.Dec()
 
-		var result models.Judge0Result
-		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-			resp.Body.Close()
+	for i := 0; i < j.MaxPolls; i++ {
+		result, err := j.pollSubmission(ctx, token, i+1)
+		if err != nil {
 			return nil, err
 		}
-		resp.Body.Close()
 
 		// Status ID: 1=In Queue, 2=Processing
 		if result.Status.ID > 2 {
-			return &result, nil
+			return result, nil
 		}
 
-		time.Sleep(pollInterval)
+		// Stop polling as soon as the caller goes away
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(j.PollInterval):
+		}
 	}
 
 	return nil, fmt.Errorf("execution timeout: max polls reached")
 }
 
+// pollSubmission fetches the current state of a submission once
+func (j *Judge0Service) pollSubmission(ctx context.Context, token string, attempt int) (result *models.Judge0Result, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "judge0.poll")
+	span.SetAttributes(
+		attribute.String("judge0.token", token),
+		attribute.Int("judge0.poll_attempt", attempt),
+	)
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
+	metrics.Judge0Polls.Inc()
+	url := fmt.Sprintf("%s/submissions/%s?base64_encoded=false", j.BaseURL, token)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := j.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get submission result: status %d", resp.StatusCode)
+	}
+
+	result = &models.Judge0Result{}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.String("judge0.status", result.Status.Description))
+	return result, nil
+}
+
 // ExecuteCode submits code and waits for result
-func (j *Judge0Service) ExecuteCode(languageID int, code, stdin string) (*models.ExecuteResponse, error) {
+func (j *Judge0Service) ExecuteCode(ctx context.Context, languageID int, code, stdin string) (*models.ExecuteResponse, error) {
 	// Submit code
-	token, err := j.SubmitCode(languageID, code, stdin)
+	token, err := j.SubmitCode(ctx, languageID, code, stdin)
+	if ctx.Err() != nil {
+		return cancelledResponse(), nil
+	}
 	if err != nil {
+		slog.WarnContext(ctx, "Judge0 submission failed", "language_id", languageID, "error", err)
 		return &models.ExecuteResponse{
 			Success: false,
 			Error:   err.Error(),
@@ -109,15 +188,24 @@ func (j *Judge0Service) ExecuteCode(languageID int, code, stdin string) (*models
 	}
 
 	// Get result
-	result, err := j.GetSubmissionResult(token)
+	result, err := j.GetSubmissionResult(ctx, token)
+	if ctx.Err() != nil {
+		slog.InfoContext(ctx, "Judge0 polling cancelled", "token", token)
+		return cancelledResponse(), nil
+	}
 	if err != nil {
+		slog.WarnContext(ctx, "Judge0 result polling failed", "token", token, "error", err)
 		return &models.ExecuteResponse{
 			Success: false,
 			Error:   err.Error(),
 		}, nil
 	}
 
-	// Parse response
+	return judge0Response(result), nil
+}
+
+// judge0Response converts a finished Judge0 result
+func judge0Response(result *models.Judge0Result) *models.ExecuteResponse {
 	response := &models.ExecuteResponse{
 		Success: true,
 		Status:  result.Status.Description,
@@ -156,5 +244,5 @@ func (j *Judge0Service) ExecuteCode(languageID int, code, stdin string) (*models
 		response.Error = result.Status.Description
 	}
 
-	return response, nil
+	return response
 }
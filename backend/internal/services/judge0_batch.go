@@ -0,0 +1,214 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/online-compiler/backend/internal/metrics"
+	"github.com/online-compiler/backend/internal/models"
+	"github.com/online-compiler/backend/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// judge0BatchSize is Judge0's default MAX_SUBMISSION_BATCH_SIZE
+const judge0BatchSize = 20
+
+// SubmitBatch submits up to 20 submissions in one request and returns their
+// tokens in order
+func (j *Judge0Service) SubmitBatch(ctx context.Context, submissions []models.Judge0Submission) (tokens []string, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "judge0.submit_batch")
+	span.SetAttributes(attribute.Int("judge0.batch_size", len(submissions)))
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
+	jsonData, err := json.Marshal(models.Judge0BatchSubmission{Submissions: submissions})
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("%s/submissions/batch?base64_encoded=false", j.BaseURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := j.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to submit batch to Judge0: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("Judge0 batch submission failed: %s", string(body))
+	}
+
+	// Rejected entries come back without a token, e.g. {"language_id": [...]}
+	var created []models.Judge0Response
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return nil, err
+	}
+	if len(created) != len(submissions) {
+		return nil, fmt.Errorf("Judge0 returned %d tokens for %d submissions", len(created), len(submissions))
+	}
+
+	tokens = make([]string, len(created))
+	for i, entry := range created {
+		if entry.Token == "" {
+			return nil, fmt.Errorf("Judge0 rejected batch submission %d", i)
+		}
+		tokens[i] = entry.Token
+	}
+	return tokens, nil
+}
+
+// GetBatchResults polls the given tokens together until all of them finish.
+// Each poll is one request for the tokens still pending.
+func (j *Judge0Service) GetBatchResults(ctx context.Context, tokens []string) ([]*models.Judge0Result, error) {
+	// Track submissions that are still waiting on Judge0
+	metrics.Judge0QueueDepth.Add(float64(len(tokens)))
+	defer metrics.Judge0QueueDepth.Sub(float64(len(tokens)))
+
+	results := make([]*models.Judge0Result, len(tokens))
+	pending := make(map[string]int, len(tokens))
+	for i, token := range tokens {
+		pending[token] = i
+	}
+
+	for i := 0; i < j.MaxPolls; i++ {
+		waiting := make([]string, 0, len(pending))
+		for _, token := range tokens {
+			if _, ok := pending[token]; ok {
+				waiting = append(waiting, token)
+			}
+		}
+
+		polled, err := j.pollBatch(ctx, waiting, i+1)
+		if err != nil {
+			return nil, err
+		}
+		for _, result := range polled {
+			index, ok := pending[result.Token]
+			// Status ID: 1=In Queue, 2=Processing
+			if ok && result.Status.ID > 2 {
+				results[index] = result
+				delete(pending, result.Token)
+			}
+		}
+		if len(pending) == 0 {
+			return results, nil
+		}
+
+		// Stop polling as soon as the caller goes away
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(j.PollInterval):
+		}
+	}
+
+	return nil, fmt.Errorf("execution timeout: max polls reached")
+}
+
+// pollBatch fetches the current state of several submissions at once
+func (j *Judge0Service) pollBatch(ctx context.Context, tokens []string, attempt int) (results []*models.Judge0Result, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "judge0.poll_batch")
+	span.SetAttributes(
+		attribute.Int("judge0.batch_size", len(tokens)),
+		attribute.Int("judge0.poll_attempt", attempt),
+	)
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
+	metrics.Judge0Polls.Inc()
+	url := fmt.Sprintf("%s/submissions/batch?base64_encoded=false&tokens=%s", j.BaseURL, url.QueryEscape(strings.Join(tokens, ",")))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := j.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get batch results: status %d", resp.StatusCode)
+	}
+
+	var batch models.Judge0BatchResult
+	if err := json.NewDecoder(resp.Body).Decode(&batch); err != nil {
+		return nil, err
+	}
+
+	results = make([]*models.Judge0Result, len(batch.Submissions))
+	for i := range batch.Submissions {
+		results[i] = &batch.Submissions[i]
+	}
+	return results, nil
+}
+
+// ExecuteBatch runs code against every test case using batch submissions
+// and batch polling. Expected outputs are passed to Judge0, which reports
+// mismatches as Wrong Answer.
+func (j *Judge0Service) ExecuteBatch(ctx context.Context, languageID int, code string, testCases []models.TestCase) ([]*models.ExecuteResponse, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "judge0.execute_batch")
+	span.SetAttributes(
+		attribute.Int("language_id", languageID),
+		attribute.Int("judge0.test_cases", len(testCases)),
+	)
+	defer span.End()
+
+	// Submit in chunks Judge0 accepts, then poll everything together
+	tokens := make([]string, 0, len(testCases))
+	for start := 0; start < len(testCases); start += judge0BatchSize {
+		chunk := testCases[start:min(start+judge0BatchSize, len(testCases))]
+		submissions := make([]models.Judge0Submission, len(chunk))
+		for i, testCase := range chunk {
+			submissions[i] = j.newSubmission(ctx, languageID, code, testCase.Stdin)
+			if testCase.ExpectedOutput != nil {
+				submissions[i].ExpectedOutput = *testCase.ExpectedOutput
+			}
+		}
+
+		chunkTokens, err := j.SubmitBatch(ctx, submissions)
+		if ctx.Err() != nil {
+			return cancelledResponses(len(testCases)), nil
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "Judge0 batch submission failed", "language_id", languageID, "error", err)
+			return nil, err
+		}
+		tokens = append(tokens, chunkTokens...)
+	}
+
+	results, err := j.GetBatchResults(ctx, tokens)
+	if ctx.Err() != nil {
+		slog.InfoContext(ctx, "Judge0 batch polling cancelled", "submissions", len(tokens))
+		return cancelledResponses(len(testCases)), nil
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "Judge0 batch polling failed", "submissions", len(tokens), "error", err)
+		return nil, err
+	}
+
+	responses := make([]*models.ExecuteResponse, len(results))
+	for i, result := range results {
+		responses[i] = judge0Response(result)
+	}
+	return responses, nil
+}
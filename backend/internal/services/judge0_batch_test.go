@@ -0,0 +1,115 @@
+package services_test
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/online-compiler/backend/configs"
+	"github.com/online-compiler/backend/internal/models"
+	"github.com/online-compiler/backend/internal/services"
+	"github.com/online-compiler/backend/internal/testutil"
+)
+
+// newBatchJudge0 returns a Judge0 service polling a fake Judge0 quickly
+func newBatchJudge0(t *testing.T) (*services.Judge0Service, *testutil.FakeJudge0) {
+	t.Helper()
+	fake := testutil.NewFakeJudge0(t)
+	judge0 := services.NewJudge0Service(fake.URL(), 5*time.Second, &configs.Config{})
+	judge0.PollInterval = 5 * time.Millisecond
+	return judge0, fake
+}
+
+// batchSubmissions returns n submissions echoing their index
+func batchSubmissions(n int) []models.Judge0Submission {
+	submissions := make([]models.Judge0Submission, n)
+	for i := range submissions {
+		submissions[i] = models.Judge0Submission{SourceCode: "print(input())", LanguageID: 71, Stdin: fmt.Sprint(i)}
+	}
+	return submissions
+}
+
+func TestExecuteBatchChunks(t *testing.T) {
+	judge0, fake := newBatchJudge0(t)
+
+	testCases := make([]models.TestCase, 45)
+	for i := range testCases {
+		testCases[i].Stdin = fmt.Sprint(i)
+	}
+	wrong := "not 7"
+	testCases[7].ExpectedOutput = &wrong
+
+	responses, err := judge0.ExecuteBatch(context.Background(), 71, "print(input())", testCases)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Submissions are split into Judge0's batch size and polled together
+	if sizes := fake.BatchSizes(); !slices.Equal(sizes, []int{20, 20, 5}) {
+		t.Errorf("batch sizes = %v, want [20 20 5]", sizes)
+	}
+	if polls := fake.PollCount(); polls != 3 {
+		t.Errorf("polls = %d, want 3", polls)
+	}
+	if len(responses) != len(testCases) {
+		t.Fatalf("got %d responses, want %d", len(responses), len(testCases))
+	}
+	for i, response := range responses {
+		wantStatus := "Accepted"
+		if i == 7 {
+			wantStatus = "Wrong Answer"
+		}
+		if response.Output != fmt.Sprint(i) || response.Status != wantStatus {
+			t.Errorf("response %d = %+v, want output %d and status %s", i, response, i, wantStatus)
+		}
+	}
+}
+
+func TestSubmitBatchTokenMismatch(t *testing.T) {
+	judge0, fake := newBatchJudge0(t)
+	fake.SetBatchLimit(2)
+
+	_, err := judge0.SubmitBatch(context.Background(), batchSubmissions(3))
+	if err == nil || !strings.Contains(err.Error(), "returned 2 tokens for 3 submissions") {
+		t.Errorf("err = %v, want a token count mismatch", err)
+	}
+}
+
+func TestSubmitBatchRejectedEntry(t *testing.T) {
+	judge0, fake := newBatchJudge0(t)
+	fake.SetRejectBatch(func(sub models.Judge0Submission) bool { return sub.Stdin == "1" })
+
+	tokens, err := judge0.SubmitBatch(context.Background(), batchSubmissions(3))
+	if err == nil || !strings.Contains(err.Error(), "rejected batch submission 1") || tokens != nil {
+		t.Errorf("tokens = %v, err = %v; want entry 1 rejected", tokens, err)
+	}
+}
+
+func TestExecuteBatchCancelDuringPolling(t *testing.T) {
+	judge0, fake := newBatchJudge0(t)
+	// Submissions stay queued, so only cancellation ends the polling
+	fake.SetPolls(1000, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		for fake.PollCount() < 3 {
+			time.Sleep(time.Millisecond)
+		}
+		cancel()
+	}()
+
+	start := time.Now()
+	responses, err := judge0.ExecuteBatch(ctx, 71, "print(input())", make([]models.TestCase, 25))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("cancellation took %v", time.Since(start))
+	}
+	if len(responses) != 25 || responses[0].Status != "Cancelled" || responses[24].Status != "Cancelled" {
+		t.Errorf("responses = %d, first %+v; want 25 cancelled", len(responses), responses[0])
+	}
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/online-compiler/backend/internal/models"
+)
+
+// ErrInjectedFailure is returned by the mock executor when failure injection
+// triggers
+var ErrInjectedFailure = errors.New("mock executor: injected failure")
+
+// Fixture is a recorded backend response for one execution request
+type Fixture struct {
+	LanguageID int                     `json:"language_id"`
+	Code       string                  `json:"code"`
+	Stdin      string                  `json:"stdin,omitempty"`
+	Args       []string                `json:"args,omitempty"`
+	Response   *models.ExecuteResponse `json:"response"`
+	RecordedAt time.Time               `json:"recorded_at"`
+}
+
+// FixtureKey identifies an execution request. Program arguments are only
+// part of the key when there are any, so recordings without them keep
+// their names.
+func FixtureKey(languageID int, code, stdin string, args []string) string {
+	key := fmt.Sprintf("%d:%s:%s", languageID, code, stdin)
+	if len(args) > 0 {
+		encoded, _ := json.Marshal(args)
+		key += ":" + string(encoded)
+	}
+	hash := sha256.Sum256([]byte(key))
+	return fmt.Sprintf("%x", hash)
+}
+
+// FixtureStore keeps fixtures as JSON files named by request hash
+type FixtureStore struct {
+	Dir string
+}
+
+// NewFixtureStore creates a fixture store rooted at dir
+func NewFixtureStore(dir string) *FixtureStore {
+	return &FixtureStore{Dir: dir}
+}
+
+// Load returns the fixture recorded for key, or os.ErrNotExist
+func (s *FixtureStore) Load(key string) (*Fixture, error) {
+	data, err := os.ReadFile(s.path(key))
+	if err != nil {
+		return nil, err
+	}
+
+	var fixture Fixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("invalid fixture %s: %v", key, err)
+	}
+	return &fixture, nil
+}
+
+// Save writes a fixture, replacing any earlier recording of the same request
+func (s *FixtureStore) Save(fixture *Fixture) error {
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so readers never see partial fixtures
+	key := FixtureKey(fixture.LanguageID, fixture.Code, fixture.Stdin, fixture.Args)
+	tmp, err := os.CreateTemp(s.Dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(key))
+}
+
+func (s *FixtureStore) path(key string) string {
+	return filepath.Join(s.Dir, key+".json")
+}
+
+// MockExecutor records real backend responses as fixtures, or replays them
+// without contacting any backend. With an Upstream executor it records;
+// without one it replays.
+type MockExecutor struct {
+	Upstream    Executor
+	Fixtures    *FixtureStore
+	Latency     time.Duration
+	FailureRate float64
+
+	// Rand returns a number in [0, 1) used for failure injection
+	Rand func() float64
+}
+
+// NewMockRecorder creates an executor that forwards to upstream and records
+// every completed response
+func NewMockRecorder(upstream Executor, fixtures *FixtureStore) *MockExecutor {
+	return &MockExecutor{Upstream: upstream, Fixtures: fixtures, Rand: rand.Float64}
+}
+
+// NewMockReplayer creates an executor that replays recorded responses after
+// latency, failing a failureRate fraction of executions
+func NewMockReplayer(fixtures *FixtureStore, latency time.Duration, failureRate float64) *MockExecutor {
+	return &MockExecutor{Fixtures: fixtures, Latency: latency, FailureRate: failureRate, Rand: rand.Float64}
+}
+
+// ExecuteCode records or replays the response for the request
+func (m *MockExecutor) ExecuteCode(ctx context.Context, languageID int, code, stdin string) (*models.ExecuteResponse, error) {
+	if m.Upstream != nil {
+		return m.record(ctx, languageID, code, stdin)
+	}
+	return m.replay(ctx, languageID, code, stdin)
+}
+
+// record executes on the upstream backend and saves the response
+func (m *MockExecutor) record(ctx context.Context, languageID int, code, stdin string) (*models.ExecuteResponse, error) {
+	response, err := m.Upstream.ExecuteCode(ctx, languageID, code, stdin)
+	if err != nil || response == nil || ctx.Err() != nil {
+		// Cancelled or failed executions are not worth replaying
+		return response, err
+	}
+
+	fixture := &Fixture{
+		LanguageID: languageID,
+		Code:       code,
+		Stdin:      stdin,
+		Args:       ArgsFrom(ctx),
+		Response:   response,
+		RecordedAt: time.Now().UTC(),
+	}
+	if err := m.Fixtures.Save(fixture); err != nil {
+		slog.WarnContext(ctx, "failed to record fixture", "language_id", languageID, "error", err)
+	} else {
+		slog.DebugContext(ctx, "fixture recorded", "key", FixtureKey(languageID, code, stdin, fixture.Args))
+	}
+
+	return response, nil
+}
+
+// replay returns the recorded response after the configured latency
+func (m *MockExecutor) replay(ctx context.Context, languageID int, code, stdin string) (*models.ExecuteResponse, error) {
+	if m.Latency > 0 {
+		select {
+		case <-ctx.Done():
+			return cancelledResponse(), nil
+		case <-time.After(m.Latency):
+		}
+	}
+
+	if m.FailureRate > 0 && m.Rand() < m.FailureRate {
+		return nil, ErrInjectedFailure
+	}
+
+	key := FixtureKey(languageID, code, stdin, ArgsFrom(ctx))
+	fixture, err := m.Fixtures.Load(key)
+	if errors.Is(err, os.ErrNotExist) {
+		slog.InfoContext(ctx, "no fixture recorded for request", "key", key, "language_id", languageID)
+		return &models.ExecuteResponse{
+			Success: false,
+			Error:   "No recorded result for this code. Run it once with MOCK_MODE=record to capture one.",
+			Status:  "Not Recorded",
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	response := *fixture.Response
+	return &response, nil
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/online-compiler/backend/internal/models"
+)
+
+// countingExecutor returns a fixed output and counts calls
+type countingExecutor struct {
+	calls int
+}
+
+func (e *countingExecutor) ExecuteCode(ctx context.Context, languageID int, code, stdin string) (*models.ExecuteResponse, error) {
+	e.calls++
+	return &models.ExecuteResponse{Success: true, Output: "out:" + stdin, Status: "Completed", ExecutionTime: 12}, nil
+}
+
+func TestMockExecutorRecordAndReplay(t *testing.T) {
+	fixtures := NewFixtureStore(t.TempDir())
+	upstream := &countingExecutor{}
+	ctx := context.Background()
+
+	recorded, err := NewMockRecorder(upstream, fixtures).ExecuteCode(ctx, 71, "print(input())", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replayed, err := NewMockReplayer(fixtures, 0, 0).ExecuteCode(ctx, 71, "print(input())", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *replayed != *recorded || upstream.calls != 1 {
+		t.Errorf("replayed %+v after %d upstream calls, want %+v after 1", replayed, upstream.calls, recorded)
+	}
+
+	// Different stdin was never recorded
+	missing, err := NewMockReplayer(fixtures, 0, 0).ExecuteCode(ctx, 71, "print(input())", "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if missing.Success || missing.Status != "Not Recorded" {
+		t.Errorf("missing fixture response = %+v", missing)
+	}
+
+	// Nor were program arguments
+	withArgs, err := NewMockReplayer(fixtures, 0, 0).ExecuteCode(WithArgs(ctx, []string{"-v"}), 71, "print(input())", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if withArgs.Status != "Not Recorded" {
+		t.Errorf("response with args = %+v, want not recorded", withArgs)
+	}
+}
+
+func TestMockExecutorFailureInjection(t *testing.T) {
+	replayer := NewMockReplayer(NewFixtureStore(t.TempDir()), 0, 0.5)
+
+	replayer.Rand = func() float64 { return 0.4 }
+	if _, err := replayer.ExecuteCode(context.Background(), 71, "x", ""); !errors.Is(err, ErrInjectedFailure) {
+		t.Errorf("err = %v, want injected failure", err)
+	}
+
+	replayer.Rand = func() float64 { return 0.6 }
+	if _, err := replayer.ExecuteCode(context.Background(), 71, "x", ""); err != nil {
+		t.Errorf("err = %v, want no failure", err)
+	}
+}
+
+func TestMockExecutorLatencyCancel(t *testing.T) {
+	replayer := NewMockReplayer(NewFixtureStore(t.TempDir()), time.Minute, 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	response, err := replayer.ExecuteCode(ctx, 71, "x", "")
+	if err != nil || response.Status != "Cancelled" {
+		t.Errorf("response = %+v, err = %v, want cancelled", response, err)
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("cancellation did not interrupt the simulated latency")
+	}
+}
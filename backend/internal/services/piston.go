@@ -2,39 +2,50 @@ package services
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
 
-	"github.com/online-compiler/backend/configs"
 	"github.com/online-compiler/backend/internal/models"
+	"github.com/online-compiler/backend/internal/tracing"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // PistonService handles Piston API interactions
 type PistonService struct {
-	BaseURL string
-	Client  *http.Client
+	BaseURL   string
+	Client    *http.Client
+	Languages LanguageSettings
 }
 
 // NewPistonService creates a new Piston service
-func NewPistonService() *PistonService {
+func NewPistonService(baseURL string, timeout time.Duration, languages LanguageSettings) *PistonService {
 	return &PistonService{
-		BaseURL: configs.AppConfig.Judge0URL,
+		BaseURL: baseURL,
 		Client: &http.Client{
-			Timeout: time.Duration(configs.AppConfig.Judge0Timeout) * time.Second,
+			Timeout:   timeout,
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
 		},
+		Languages: languages,
 	}
 }
 
 // PistonRequest represents a Piston execution request
 type PistonRequest struct {
-	Language string   `json:"language"`
-	Version  string   `json:"version"`
-	Files    []File   `json:"files"`
-	Stdin    string   `json:"stdin,omitempty"`
-	Args     []string `json:"args,omitempty"`
+	Language       string   `json:"language"`
+	Version        string   `json:"version"`
+	Files          []File   `json:"files"`
+	Stdin          string   `json:"stdin,omitempty"`
+	Args           []string `json:"args,omitempty"`
+	RunTimeout     int      `json:"run_timeout,omitempty"`
+	RunMemoryLimit int      `json:"run_memory_limit,omitempty"`
 }
 
 // File represents a code file
@@ -85,8 +96,74 @@ var languageMap = map[int]struct {
 	74: {"typescript", "5.0.3", "main.ts"},   // TypeScript
 }
 
+// LanguageName returns the language name for a Judge0 language ID
+func LanguageName(languageID int) string {
+	if langInfo, exists := languageMap[languageID]; exists {
+		return langInfo.Language
+	}
+	return "unknown"
+}
+
+// SourceFileName returns the conventional source file name for a Judge0
+// language ID
+func SourceFileName(languageID int) string {
+	if langInfo, exists := languageMap[languageID]; exists {
+		return langInfo.FileName
+	}
+	return "main"
+}
+
+// languageAliases maps alternative language names to Judge0 IDs
+var languageAliases = map[string]int{
+	"python3": 71, "py": 71,
+	"js": 63, "node": 63, "nodejs": 63,
+	"cpp": 54, "c++17": 54,
+	"csharp": 51, "cs": 51,
+	"golang": 60,
+	"rb":     72,
+	"rs":     73,
+	"kt":     78,
+	"ts":     74,
+	"sqlite": 82,
+}
+
+// LanguageID returns the Judge0 language ID for a language name such as
+// "python" or "cpp", ignoring case
+func LanguageID(language string) (int, bool) {
+	language = strings.ToLower(strings.TrimSpace(language))
+	if id, exists := languageAliases[language]; exists {
+		return id, true
+	}
+	for id, langInfo := range languageMap {
+		if langInfo.Language == language {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// LanguageFileName returns the conventional source file name for a
+// language name or alias such as "python" or "py", or "main.txt" for
+// unknown languages
+func LanguageFileName(language string) string {
+	if id, exists := LanguageID(language); exists {
+		return SourceFileName(id)
+	}
+	return "main.txt"
+}
+
 // ExecuteCode executes code using Piston
-func (p *PistonService) ExecuteCode(languageID int, code, stdin string) (*models.ExecuteResponse, error) {
+func (p *PistonService) ExecuteCode(ctx context.Context, languageID int, code, stdin string) (*models.ExecuteResponse, error) {
+	return p.ExecuteProject(ctx, languageID, []models.SnippetFile{{Name: SourceFileName(languageID), Content: code}}, stdin)
+}
+
+// ExecuteProject executes a multi-file program using Piston, which runs the
+// first file
+func (p *PistonService) ExecuteProject(ctx context.Context, languageID int, files []models.SnippetFile, stdin string) (*models.ExecuteResponse, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "piston.execute")
+	span.SetAttributes(attribute.Int("language_id", languageID), attribute.Int("files", len(files)))
+	defer span.End()
+
 	// Get language info
 	langInfo, exists := languageMap[languageID]
 	if !exists {
@@ -97,16 +174,19 @@ func (p *PistonService) ExecuteCode(languageID int, code, stdin string) (*models
 	}
 
 	// Create request
+	langConfig := p.Languages.Language(languageID)
 	pistonReq := PistonRequest{
 		Language: langInfo.Language,
 		Version:  langInfo.Version,
-		Files: []File{
-			{
-				Name:    langInfo.FileName,
-				Content: code,
-			},
-		},
-		Stdin: stdin,
+		Files:    make([]File, len(files)),
+		Stdin:    stdin,
+		Args:     slices.Concat(langConfig.Args, ArgsFrom(ctx)),
+		// Piston expects milliseconds and bytes
+		RunTimeout:     int(langConfig.TimeLimit * 1000),
+		RunMemoryLimit: langConfig.MemoryLimitKB * 1024,
+	}
+	for i, file := range files {
+		pistonReq.Files[i] = File{Name: file.Name, Content: file.Content}
 	}
 
 	jsonData, err := json.Marshal(pistonReq)
@@ -119,8 +199,25 @@ func (p *PistonService) ExecuteCode(languageID int, code, stdin string) (*models
 
 	// Execute code
 	url := fmt.Sprintf("%s/api/v2/execute", p.BaseURL)
-	resp, err := p.Client.Post(url, "application/json", bytes.NewBuffer(jsonData))
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(jsonData))
 	if err != nil {
+		return &models.ExecuteResponse{
+			Success: false,
+			Error:   err.Error(),
+		}, nil
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.Client.Do(httpReq)
+	if ctx.Err() != nil {
+		if err == nil {
+			resp.Body.Close()
+		}
+		return cancelledResponse(), nil
+	}
+	if err != nil {
+		tracing.RecordError(span, err)
+		slog.WarnContext(ctx, "Piston request failed", "language", langInfo.Language, "error", err)
 		return &models.ExecuteResponse{
 			Success: false,
 			Error:   fmt.Sprintf("Failed to connect to Piston: %v", err),
@@ -130,6 +227,8 @@ func (p *PistonService) ExecuteCode(languageID int, code, stdin string) (*models
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
+		tracing.RecordError(span, fmt.Errorf("piston returned status %d", resp.StatusCode))
+		slog.WarnContext(ctx, "Piston returned an error", "language", langInfo.Language, "status", resp.StatusCode)
 		return &models.ExecuteResponse{
 			Success: false,
 			Error:   fmt.Sprintf("Piston error: %s", string(body)),
@@ -144,6 +243,8 @@ func (p *PistonService) ExecuteCode(languageID int, code, stdin string) (*models
 		}, nil
 	}
 
+	span.SetAttributes(attribute.Int("piston.exit_code", pistonResp.Run.Code))
+
 	// Build response
 	response := &models.ExecuteResponse{
 		Success: true,
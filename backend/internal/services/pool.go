@@ -0,0 +1,402 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/online-compiler/backend/internal/metrics"
+	"github.com/online-compiler/backend/internal/models"
+)
+
+// Priority orders queued executions; interactive work is always picked
+// before batch work
+type Priority int
+
+// Execution priorities
+const (
+	PriorityInteractive Priority = iota
+	PriorityBatch
+)
+
+var priorityNames = [...]string{"interactive", "batch"}
+
+// String returns the priority name used in requests and metrics
+func (p Priority) String() string {
+	return priorityNames[p]
+}
+
+// ParsePriority parses a request priority; empty returns fallback
+func ParsePriority(value string, fallback Priority) (Priority, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	if i := slices.Index(priorityNames[:], value); i >= 0 {
+		return Priority(i), nil
+	}
+	return fallback, fmt.Errorf("unknown priority %q", value)
+}
+
+type priorityKey struct{}
+
+// WithPriority attaches the queue priority used by pooled executors
+func WithPriority(ctx context.Context, priority Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+// PriorityFrom returns the priority attached to ctx, or interactive
+func PriorityFrom(ctx context.Context) Priority {
+	if priority, ok := ctx.Value(priorityKey{}).(Priority); ok {
+		return priority
+	}
+	return PriorityInteractive
+}
+
+var (
+	// ErrQueueFull is matched by QueueFullError
+	ErrQueueFull = errors.New("execution queue is full")
+	// ErrPoolClosed is returned when enqueueing on a closed pool
+	ErrPoolClosed = errors.New("worker pool is closed")
+)
+
+// QueueFullError is returned when a backend's queue has no room left
+type QueueFullError struct {
+	Backend    string
+	RetryAfter time.Duration
+}
+
+func (e *QueueFullError) Error() string {
+	return fmt.Sprintf("%s execution queue is full, retry after %s", e.Backend, e.RetryAfter)
+}
+
+// Is makes errors.Is(err, ErrQueueFull) match
+func (e *QueueFullError) Is(target error) bool {
+	return target == ErrQueueFull
+}
+
+// poolTask is a unit of work in a WorkerPool
+type poolTask struct {
+	ctx      context.Context
+	priority Priority
+	run      func(ctx context.Context)
+	enqueued time.Time
+	started  chan struct{}
+	done     chan struct{}
+}
+
+// WorkerPool runs tasks on a fixed number of workers. Tasks wait in a
+// bounded queue ordered by priority and then arrival; idle workers accept
+// tasks even when the queue depth is zero.
+type WorkerPool struct {
+	Backend string
+
+	workers    int
+	queueDepth int
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queues  [len(priorityNames)][]*poolTask
+	busy    int
+	closed  bool
+	avgRun  time.Duration
+	running sync.WaitGroup
+}
+
+// NewWorkerPool starts workers goroutines serving a queue of queueDepth
+func NewWorkerPool(backend string, workers, queueDepth int) *WorkerPool {
+	p := &WorkerPool{
+		Backend:    backend,
+		workers:    workers,
+		queueDepth: queueDepth,
+		avgRun:     time.Second,
+	}
+	p.cond = sync.NewCond(&p.mu)
+
+	p.running.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+// Ticket tracks a task submitted to a WorkerPool
+type Ticket struct {
+	pool *WorkerPool
+	task *poolTask
+}
+
+// Enqueue queues run at priority. run receives ctx once a worker picks the
+// task up; it is never called if ctx is done before then.
+func (p *WorkerPool) Enqueue(ctx context.Context, priority Priority, run func(ctx context.Context)) (*Ticket, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil, ErrPoolClosed
+	}
+	if p.queuedLocked() >= p.queueDepth+p.workers-p.busy {
+		metrics.QueueRejections.WithLabelValues(p.Backend).Inc()
+		return nil, &QueueFullError{Backend: p.Backend, RetryAfter: p.retryAfterLocked()}
+	}
+
+	task := &poolTask{
+		ctx:      ctx,
+		priority: priority,
+		run:      run,
+		enqueued: time.Now(),
+		started:  make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	p.queues[priority] = append(p.queues[priority], task)
+	metrics.QueueDepth.WithLabelValues(p.Backend, priority.String()).Inc()
+	p.cond.Signal()
+
+	return &Ticket{pool: p, task: task}, nil
+}
+
+// Close stops the workers once running tasks finish. Queued tasks are
+// dropped without running.
+func (p *WorkerPool) Close() {
+	p.mu.Lock()
+	p.closed = true
+	for priority, queue := range p.queues {
+		for _, task := range queue {
+			close(task.done)
+		}
+		metrics.QueueDepth.WithLabelValues(p.Backend, Priority(priority).String()).Sub(float64(len(queue)))
+		p.queues[priority] = nil
+	}
+	p.cond.Broadcast()
+	p.mu.Unlock()
+
+	p.running.Wait()
+}
+
+// Started is closed when a worker picks the task up
+func (t *Ticket) Started() <-chan struct{} {
+	return t.task.started
+}
+
+// Done is closed when the task finishes or is dropped
+func (t *Ticket) Done() <-chan struct{} {
+	return t.task.done
+}
+
+// Position returns the number of tasks ahead of this one plus one, or zero
+// once the task has left the queue
+func (t *Ticket) Position() int {
+	p := t.pool
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ahead := 0
+	for priority := range t.task.priority {
+		ahead += len(p.queues[priority])
+	}
+	if i := slices.Index(p.queues[t.task.priority], t.task); i >= 0 {
+		return ahead + i + 1
+	}
+	return 0
+}
+
+// Wait blocks until the task finishes. If ctx is done while the task is
+// still queued, the task is removed and ctx's error is returned; a running
+// task is waited for, as it observes the same cancellation.
+func (t *Ticket) Wait(ctx context.Context) error {
+	select {
+	case <-t.task.done:
+		return nil
+	case <-ctx.Done():
+		if t.pool.remove(t.task) {
+			return ctx.Err()
+		}
+		<-t.task.done
+		return nil
+	}
+}
+
+// remove drops a task that has not started yet
+func (p *WorkerPool) remove(task *poolTask) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	queue := p.queues[task.priority]
+	i := slices.Index(queue, task)
+	if i < 0 {
+		return false
+	}
+	p.queues[task.priority] = slices.Delete(queue, i, i+1)
+	metrics.QueueDepth.WithLabelValues(p.Backend, task.priority.String()).Dec()
+	close(task.done)
+	return true
+}
+
+// work runs queued tasks until the pool is closed
+func (p *WorkerPool) work() {
+	defer p.running.Done()
+
+	for {
+		p.mu.Lock()
+		for p.queuedLocked() == 0 && !p.closed {
+			p.cond.Wait()
+		}
+		if p.closed {
+			p.mu.Unlock()
+			return
+		}
+		task := p.popLocked()
+		p.busy++
+		p.mu.Unlock()
+
+		p.runTask(task)
+	}
+}
+
+// runTask runs one task and updates the pool statistics
+func (p *WorkerPool) runTask(task *poolTask) {
+	metrics.QueueWait.WithLabelValues(p.Backend, task.priority.String()).Observe(time.Since(task.enqueued).Seconds())
+	metrics.WorkersBusy.WithLabelValues(p.Backend).Inc()
+	defer metrics.WorkersBusy.WithLabelValues(p.Backend).Dec()
+
+	close(task.started)
+	start := time.Now()
+	if task.ctx.Err() == nil {
+		task.run(task.ctx)
+	}
+	elapsed := time.Since(start)
+	close(task.done)
+
+	// Exponentially weighted average run time for Retry-After estimates
+	p.mu.Lock()
+	p.avgRun = (p.avgRun*4 + elapsed) / 5
+	p.busy--
+	p.mu.Unlock()
+}
+
+// popLocked removes the next task by priority. Callers must hold p.mu.
+func (p *WorkerPool) popLocked() *poolTask {
+	for priority, queue := range p.queues {
+		if len(queue) > 0 {
+			task := queue[0]
+			p.queues[priority] = queue[1:]
+			metrics.QueueDepth.WithLabelValues(p.Backend, task.priority.String()).Dec()
+			return task
+		}
+	}
+	return nil
+}
+
+// queuedLocked returns the number of waiting tasks. Callers must hold p.mu.
+func (p *WorkerPool) queuedLocked() int {
+	total := 0
+	for _, queue := range p.queues {
+		total += len(queue)
+	}
+	return total
+}
+
+// retryAfterLocked estimates when the queue will have room again, rounded
+// up to whole seconds. Callers must hold p.mu.
+func (p *WorkerPool) retryAfterLocked() time.Duration {
+	wait := p.avgRun * time.Duration(p.queuedLocked()+1) / time.Duration(p.workers)
+	return time.Duration(math.Ceil(max(wait.Seconds(), 1))) * time.Second
+}
+
+// PooledExecutor runs another executor's executions on a WorkerPool
+type PooledExecutor struct {
+	Executor Executor
+	Pool     *WorkerPool
+}
+
+// NewPooledExecutor wraps executor with pool
+func NewPooledExecutor(executor Executor, pool *WorkerPool) *PooledExecutor {
+	return &PooledExecutor{Executor: executor, Pool: pool}
+}
+
+// QueuedExecution is an execution waiting for or running on a worker
+type QueuedExecution struct {
+	*Ticket
+	response *models.ExecuteResponse
+	err      error
+}
+
+// Result waits for the execution and returns its response. If ctx is done
+// before a worker picks it up, the cancelled response is returned.
+func (q *QueuedExecution) Result(ctx context.Context) (*models.ExecuteResponse, error) {
+	if err := q.Wait(ctx); err != nil {
+		return cancelledResponse(), nil
+	}
+	if q.response == nil && q.err == nil {
+		// Dropped by a closing pool or cancelled before it started
+		return cancelledResponse(), nil
+	}
+	return q.response, q.err
+}
+
+// Enqueue queues an execution without waiting for it
+func (e *PooledExecutor) Enqueue(ctx context.Context, priority Priority, languageID int, code, stdin string) (*QueuedExecution, error) {
+	execution := &QueuedExecution{}
+	ticket, err := e.Pool.Enqueue(ctx, priority, func(ctx context.Context) {
+		execution.response, execution.err = e.Executor.ExecuteCode(ctx, languageID, code, stdin)
+	})
+	if err != nil {
+		return nil, err
+	}
+	execution.Ticket = ticket
+	return execution, nil
+}
+
+// ExecuteCode queues the execution at the context's priority and waits for
+// it. A full queue returns a *QueueFullError.
+func (e *PooledExecutor) ExecuteCode(ctx context.Context, languageID int, code, stdin string) (*models.ExecuteResponse, error) {
+	execution, err := e.Enqueue(ctx, PriorityFrom(ctx), languageID, code, stdin)
+	if err != nil {
+		return nil, err
+	}
+	return execution.Result(ctx)
+}
+
+// ExecuteBatch runs all test cases as a single task at the context's
+// priority, so a batch occupies one worker rather than one per test case
+func (e *PooledExecutor) ExecuteBatch(ctx context.Context, languageID int, code string, testCases []models.TestCase) ([]*models.ExecuteResponse, error) {
+	var responses []*models.ExecuteResponse
+	var runErr error
+	ticket, err := e.Pool.Enqueue(ctx, PriorityFrom(ctx), func(ctx context.Context) {
+		responses, runErr = ExecuteTestCases(ctx, e.Executor, languageID, code, testCases)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ticket.Wait(ctx); err != nil || (responses == nil && runErr == nil) {
+		return cancelledResponses(len(testCases)), nil
+	}
+	return responses, runErr
+}
+
+// ExecuteProject runs a multi-file program as a single task at the
+// context's priority. Projects the wrapped executor cannot run are rejected
+// without queueing.
+func (e *PooledExecutor) ExecuteProject(ctx context.Context, languageID int, files []models.SnippetFile, stdin string) (*models.ExecuteResponse, error) {
+	if _, ok := e.Executor.(ProjectExecutor); !ok && len(files) != 1 {
+		return nil, ErrProjectUnsupported
+	}
+
+	var response *models.ExecuteResponse
+	var runErr error
+	ticket, err := e.Pool.Enqueue(ctx, PriorityFrom(ctx), func(ctx context.Context) {
+		response, runErr = ExecuteProject(ctx, e.Executor, languageID, files, stdin)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ticket.Wait(ctx); err != nil || (response == nil && runErr == nil) {
+		return cancelledResponse(), nil
+	}
+	return response, runErr
+}
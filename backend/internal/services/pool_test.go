@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockWorker occupies the pool's only worker until release is closed
+func blockWorker(t *testing.T, pool *WorkerPool) chan struct{} {
+	t.Helper()
+	release := make(chan struct{})
+	ticket, err := pool.Enqueue(context.Background(), PriorityInteractive, func(context.Context) { <-release })
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-ticket.Started()
+	return release
+}
+
+func TestWorkerPoolPriority(t *testing.T) {
+	pool := NewWorkerPool("test", 1, 10)
+	defer pool.Close()
+	release := blockWorker(t, pool)
+
+	var mu sync.Mutex
+	var order []string
+	record := func(name string) func(context.Context) {
+		return func(context.Context) {
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+		}
+	}
+
+	batch, _ := pool.Enqueue(context.Background(), PriorityBatch, record("batch"))
+	interactive, _ := pool.Enqueue(context.Background(), PriorityInteractive, record("interactive"))
+
+	if position := batch.Position(); position != 2 {
+		t.Errorf("batch position = %d, want 2 behind the interactive task", position)
+	}
+	if position := interactive.Position(); position != 1 {
+		t.Errorf("interactive position = %d, want 1", position)
+	}
+
+	close(release)
+	<-batch.Done()
+	if len(order) != 2 || order[0] != "interactive" {
+		t.Errorf("order = %v, want interactive first", order)
+	}
+	if position := batch.Position(); position != 0 {
+		t.Errorf("position after run = %d, want 0", position)
+	}
+}
+
+func TestWorkerPoolQueueFull(t *testing.T) {
+	pool := NewWorkerPool("test", 1, 1)
+	defer pool.Close()
+	release := blockWorker(t, pool)
+	defer close(release)
+
+	if _, err := pool.Enqueue(context.Background(), PriorityBatch, func(context.Context) {}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := pool.Enqueue(context.Background(), PriorityInteractive, func(context.Context) {})
+	var queueFull *QueueFullError
+	if !errors.As(err, &queueFull) || !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("err = %v, want queue full", err)
+	}
+	if queueFull.RetryAfter < time.Second {
+		t.Errorf("RetryAfter = %s, want at least a second", queueFull.RetryAfter)
+	}
+}
+
+func TestWorkerPoolCancelQueued(t *testing.T) {
+	pool := NewWorkerPool("test", 1, 1)
+	defer pool.Close()
+	release := blockWorker(t, pool)
+	defer close(release)
+
+	ran := false
+	ctx, cancel := context.WithCancel(context.Background())
+	ticket, err := pool.Enqueue(ctx, PriorityBatch, func(context.Context) { ran = true })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+	if err := ticket.Wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Wait = %v, want cancelled", err)
+	}
+	if ran || ticket.Position() != 0 {
+		t.Errorf("cancelled task ran = %v, position = %d", ran, ticket.Position())
+	}
+
+	// The slot is free again
+	if _, err := pool.Enqueue(context.Background(), PriorityBatch, func(context.Context) {}); err != nil {
+		t.Errorf("enqueue after cancel: %v", err)
+	}
+}
+
+func TestParsePriority(t *testing.T) {
+	if p, err := ParsePriority("", PriorityBatch); err != nil || p != PriorityBatch {
+		t.Errorf("empty = %v, %v", p, err)
+	}
+	if p, err := ParsePriority("interactive", PriorityBatch); err != nil || p != PriorityInteractive {
+		t.Errorf("interactive = %v, %v", p, err)
+	}
+	if _, err := ParsePriority("urgent", PriorityBatch); err == nil {
+		t.Error("expected an error for an unknown priority")
+	}
+}
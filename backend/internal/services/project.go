@@ -0,0 +1,30 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/online-compiler/backend/internal/models"
+)
+
+// ErrProjectUnsupported is returned when a multi-file project is run on a
+// backend that only accepts a single source file
+var ErrProjectUnsupported = errors.New("execution backend does not support multi-file projects")
+
+// ProjectExecutor is implemented by executors that can run a program made
+// of several files. The first file is the entry point.
+type ProjectExecutor interface {
+	ExecuteProject(ctx context.Context, languageID int, files []models.SnippetFile, stdin string) (*models.ExecuteResponse, error)
+}
+
+// ExecuteProject runs a multi-file program. Single-file programs run on any
+// executor; larger ones need a ProjectExecutor.
+func ExecuteProject(ctx context.Context, executor Executor, languageID int, files []models.SnippetFile, stdin string) (*models.ExecuteResponse, error) {
+	if project, ok := executor.(ProjectExecutor); ok {
+		return project.ExecuteProject(ctx, languageID, files, stdin)
+	}
+	if len(files) == 1 {
+		return executor.ExecuteCode(ctx, languageID, files[0].Content, stdin)
+	}
+	return nil, ErrProjectUnsupported
+}
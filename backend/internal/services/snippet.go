@@ -1,38 +1,501 @@
 package services
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
 	"github.com/google/uuid"
 	"github.com/online-compiler/backend/internal/database"
 	"github.com/online-compiler/backend/internal/models"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// Snippet visibility levels
+const (
+	VisibilityPublic   = "public"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPrivate  = "private"
+	VisibilityPassword = "password"
 )
 
-// CreateSnippet creates a new code snippet
-func CreateSnippet(req *models.SnippetRequest) (*models.Snippet, error) {
-	snippet := &models.Snippet{
-		ID:       uuid.New().String(),
-		Language: req.Language,
-		Code:     req.Code,
-		Title:    req.Title,
-		Views:    0,
+var (
+	// ErrSnippetNotFound is returned for unknown, expired, burned and, to
+	// anyone but their editors, private snippets
+	ErrSnippetNotFound = errors.New("snippet not found")
+	// ErrSnippetForbidden is returned when the caller is neither the owner
+	// nor holds the snippet's edit token
+	ErrSnippetForbidden = errors.New("not allowed to modify snippet")
+	// ErrRevisionNotFound is returned for unknown revision numbers
+	ErrRevisionNotFound = errors.New("snippet revision not found")
+	// ErrSnippetConflict is returned when another update saved a revision
+	// first
+	ErrSnippetConflict = errors.New("snippet was modified concurrently")
+	// ErrSnippetPasswordRequired is returned when a password snippet is read
+	// without a password
+	ErrSnippetPasswordRequired = errors.New("snippet password required")
+	// ErrSnippetPasswordInvalid is returned for a wrong snippet password
+	ErrSnippetPasswordInvalid = errors.New("invalid snippet password")
+)
+
+type userKey struct{}
+
+// WithUser attaches the authenticated user ID
+func WithUser(ctx context.Context, user string) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// UserFrom returns the authenticated user ID, or "" for anonymous requests
+func UserFrom(ctx context.Context) string {
+	user, _ := ctx.Value(userKey{}).(string)
+	return user
+}
+
+// SnippetAccess holds the credentials presented for a snippet. The owner
+// (the user attached to the context) and edit token holders are editors:
+// they may modify the snippet and read it regardless of its visibility.
+type SnippetAccess struct {
+	EditToken string
+	Password  string
+	// Visitor identifies an anonymous reader, such as by their address, so
+	// their repeated views count once
+	Visitor string
+}
+
+// SnippetStore persists code snippets. Snippets are owned by the user
+// attached to the creating context, if any, and can be modified by that
+// user or by anyone holding the edit token returned at creation. Creation
+// and every update save an immutable revision.
+type SnippetStore interface {
+	CreateSnippet(ctx context.Context, req *models.SnippetRequest) (snippet *models.Snippet, editToken string, err error)
+	GetSnippet(ctx context.Context, id string, access SnippetAccess) (*models.Snippet, error)
+	GetSnippetSummary(ctx context.Context, id string, access SnippetAccess) (*models.SnippetSummary, error)
+	UpdateSnippet(ctx context.Context, id string, req *models.SnippetRequest, access SnippetAccess) (*models.Snippet, error)
+	DeleteSnippet(ctx context.Context, id string, access SnippetAccess) error
+	ListRevisions(ctx context.Context, id string, access SnippetAccess) ([]models.SnippetRevision, error)
+	GetRevision(ctx context.Context, id string, revision int, access SnippetAccess) (*models.SnippetRevision, error)
+	DiffRevisions(ctx context.Context, id string, from, to int, access SnippetAccess) (string, error)
+	ForkSnippet(ctx context.Context, id string, req *models.ForkSnippetRequest, access SnippetAccess) (fork *models.Snippet, editToken string, err error)
+	ListForks(ctx context.Context, id string, access SnippetAccess) ([]models.SnippetSummary, error)
+	ListSnippets(ctx context.Context, query SnippetQuery) (snippets []models.SnippetSummary, nextCursor string, err error)
+	SaveRun(ctx context.Context, snippet *models.Snippet, result *models.ExecuteResponse) error
+	RecordRun(ctx context.Context, id string) error
+	SnippetStats(ctx context.Context, id string, days int, access SnippetAccess) (*models.SnippetStats, error)
+}
+
+// SnippetService stores snippets with GORM
+type SnippetService struct {
+	DB *gorm.DB
+	// Views counts views and runs; it writes them immediately unless
+	// replaced by a started counter
+	Views *ViewCounter
+	// Blobs stores the code of snippets and revisions
+	Blobs *BlobStore
+	// fullText is set when the FTS5 search index exists
+	fullText bool
+}
+
+// NewSnippetService creates a snippet store backed by db
+func NewSnippetService(db *gorm.DB) *SnippetService {
+	return &SnippetService{
+		DB:       db,
+		Views:    NewViewCounter(db),
+		Blobs:    NewBlobStore(DefaultBlobCompressMinSize),
+		fullText: db.Migrator().HasTable(database.SnippetSearchTable),
+	}
+}
+
+// CreateSnippet creates a new code snippet and returns it with its edit
+// token. Only a hash of the token is stored.
+func (s *SnippetService) CreateSnippet(ctx context.Context, req *models.SnippetRequest) (*models.Snippet, string, error) {
+	tags, err := normalizeTags(req.Tags)
+	if err != nil {
+		return nil, "", err
+	}
+	snippet := &models.Snippet{Title: req.Title, Tags: tags}
+	if err := applySnippetContents(snippet, req); err != nil {
+		return nil, "", err
+	}
+	if err := applyRunDefaults(snippet, req); err != nil {
+		return nil, "", err
+	}
+	if err := applySnippetOptions(snippet, req.SnippetOptions); err != nil {
+		return nil, "", err
+	}
+
+	editToken, err := s.create(ctx, snippet, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	return snippet, editToken, nil
+}
+
+// create stores a new snippet with its first revision and a fresh edit
+// token. also, if set, runs in the same transaction.
+func (s *SnippetService) create(ctx context.Context, snippet *models.Snippet, also func(tx *gorm.DB) error) (string, error) {
+	editToken, err := newEditToken()
+	if err != nil {
+		return "", err
 	}
 
-	if err := database.DB.Create(snippet).Error; err != nil {
+	snippet.ID = uuid.New().String()
+	snippet.Owner = UserFrom(ctx)
+	snippet.EditTokenHash = hashEditToken(editToken)
+	snippet.Revision = 1
+
+	err = s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := s.saveSnippet(tx, snippet); err != nil {
+			return err
+		}
+		if err := s.saveRevision(tx, newRevision(ctx, snippet)); err != nil {
+			return err
+		}
+		if also != nil {
+			return also(tx)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return editToken, nil
+}
+
+// GetSnippet retrieves a snippet by ID. Reads by anyone but an editor count
+// as a view, and the read that reaches max_views deletes the snippet.
+func (s *SnippetService) GetSnippet(ctx context.Context, id string, access SnippetAccess) (*models.Snippet, error) {
+	return s.read(ctx, id, access, nil)
+}
+
+// GetSnippetSummary describes a readable snippet without its code. It does
+// not count as a view.
+func (s *SnippetService) GetSnippetSummary(ctx context.Context, id string, access SnippetAccess) (*models.SnippetSummary, error) {
+	snippet, _, err := s.readable(ctx, id, access)
+	if err != nil {
+		return nil, err
+	}
+	return &models.SnippetSummary{
+		ID:             snippet.ID,
+		Language:       snippet.Language,
+		Title:          snippet.Title,
+		Tags:           snippet.Tags,
+		Owner:          snippet.Owner,
+		ParentID:       snippet.ParentID,
+		ParentRevision: snippet.ParentRevision,
+		Revision:       snippet.Revision,
+		ForkCount:      snippet.ForkCount,
+		Views:          snippet.Views,
+		Visibility:     snippet.Visibility,
+		ExpiresAt:      snippet.ExpiresAt,
+		CreatedAt:      snippet.CreatedAt,
+		UpdatedAt:      snippet.UpdatedAt,
+	}, nil
+}
+
+// UpdateSnippet replaces a snippet's contents and title and saves them as
+// the next revision
+func (s *SnippetService) UpdateSnippet(ctx context.Context, id string, req *models.SnippetRequest, access SnippetAccess) (*models.Snippet, error) {
+	var contents models.Snippet
+	if err := applySnippetContents(&contents, req); err != nil {
+		return nil, err
+	}
+	if err := applyRunDefaults(&contents, req); err != nil {
+		return nil, err
+	}
+	tags, err := normalizeTags(req.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	snippet, err := s.authorize(ctx, id, access)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Snippets created before revisions existed keep their original
+		// contents as revision 1
+		if snippet.Revision == 0 {
+			snippet.Revision = 1
+			original := newRevision(ctx, snippet)
+			original.Author = snippet.Owner
+			original.CreatedAt = snippet.CreatedAt
+			if err := s.saveRevision(tx, original); err != nil {
+				return err
+			}
+		}
+
+		codeHash, fileBlobs, err := s.storeContents(tx, contents.Code, contents.Files)
+		if err != nil {
+			return err
+		}
+
+		// Only move to the next revision if nobody else did first
+		next := snippet.Revision + 1
+		updates := map[string]interface{}{
+			"language":    contents.Language,
+			"language_id": contents.LanguageID,
+			"code_hash":   codeHash,
+			"file_blobs":  fileBlobs,
+			"title":       req.Title,
+			"revision":    next,
+			"last_run":    gorm.Expr("NULL"),
+		}
+		if req.Tags != nil {
+			updates["tags"] = tags
+		}
+		if req.Stdin != nil {
+			updates["stdin"] = contents.Stdin
+		}
+		if req.Args != nil {
+			updates["args"] = contents.Args
+		}
+		result := tx.Model(&models.Snippet{}).
+			Where("id = ? AND revision = ?", snippet.ID, snippet.Revision).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("%w: snippet %s", ErrSnippetConflict, snippet.ID)
+		}
+		if err := s.releaseContents(tx, snippet.CodeHash, snippet.FileBlobs); err != nil {
+			return err
+		}
+
+		snippet.Language = contents.Language
+		snippet.LanguageID = contents.LanguageID
+		snippet.Code, snippet.CodeHash = contents.Code, codeHash
+		snippet.Files, snippet.FileBlobs = contents.Files, fileBlobs
+		snippet.Title = req.Title
+		snippet.Revision = next
+		if err := s.index(tx, snippet); err != nil {
+			return err
+		}
+		return s.saveRevision(tx, newRevision(ctx, snippet))
+	})
+	if err != nil {
 		return nil, err
 	}
 
+	// Reload to pick up the stored updated_at
+	if err := s.DB.WithContext(ctx).First(snippet, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
 	return snippet, nil
 }
 
-// GetSnippet retrieves a snippet by ID
-func GetSnippet(id string) (*models.Snippet, error) {
+// DeleteSnippet removes a snippet and its revisions
+func (s *SnippetService) DeleteSnippet(ctx context.Context, id string, access SnippetAccess) error {
+	snippet, err := s.authorize(ctx, id, access)
+	if err != nil {
+		return err
+	}
+	return s.purge(ctx, snippet)
+}
+
+// purge deletes a snippet with its revisions and releases their blobs.
+// Forks keep their parent_id so lineage survives the parent. Purging a
+// snippet that is already gone does nothing.
+func (s *SnippetService) purge(ctx context.Context, snippet *models.Snippet) error {
+	return s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := s.unindex(tx, snippet.ID); err != nil {
+			return err
+		}
+		// Release the blobs referred to by the stored row, which a
+		// concurrent update may have changed
+		var stored models.Snippet
+		result := tx.Select("code_hash", "file_blobs").Where("id = ?", snippet.ID).Limit(1).Find(&stored)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		if err := tx.Delete(&models.Snippet{}, "id = ?", snippet.ID).Error; err != nil {
+			return err
+		}
+		if err := s.releaseContents(tx, stored.CodeHash, stored.FileBlobs); err != nil {
+			return err
+		}
+
+		var revisions []models.SnippetRevision
+		if err := tx.Select("code_hash", "file_blobs").Where("snippet_id = ?", snippet.ID).Find(&revisions).Error; err != nil {
+			return err
+		}
+		for _, rev := range revisions {
+			if err := s.releaseContents(tx, rev.CodeHash, rev.FileBlobs); err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("snippet_id = ?", snippet.ID).Delete(&models.SnippetRevision{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("snippet_id = ?", snippet.ID).Delete(&models.SnippetDailyStats{}).Error; err != nil {
+			return err
+		}
+		if snippet.ParentID != nil {
+			return tx.Model(&models.Snippet{}).Where("id = ? AND fork_count > 0", *snippet.ParentID).
+				UpdateColumn("fork_count", gorm.Expr("fork_count - 1")).Error
+		}
+		return nil
+	})
+}
+
+// load fetches a snippet that has not expired or burned
+func (s *SnippetService) load(ctx context.Context, id string) (*models.Snippet, error) {
 	var snippet models.Snippet
+	if err := s.DB.WithContext(ctx).First(&snippet, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSnippetNotFound
+		}
+		return nil, err
+	}
+	if snippetGone(&snippet, time.Now()) {
+		// The sweeper would remove it later; do it now
+		s.purge(ctx, &snippet)
+		return nil, ErrSnippetNotFound
+	}
 
-	if err := database.DB.First(&snippet, "id = ?", id).Error; err != nil {
+	var err error
+	if snippet.Code, snippet.Files, err = s.loadContents(s.DB.WithContext(ctx), snippet.CodeHash, snippet.FileBlobs); err != nil {
 		return nil, err
 	}
+	return &snippet, nil
+}
 
-	// Increment view count
-	database.DB.Model(&snippet).Update("views", snippet.Views+1)
+// readable loads a snippet the caller may read and reports whether the
+// caller is an editor
+func (s *SnippetService) readable(ctx context.Context, id string, access SnippetAccess) (*models.Snippet, bool, error) {
+	snippet, err := s.load(ctx, id)
+	if err != nil {
+		return nil, false, err
+	}
+	if canEdit(ctx, snippet, access) {
+		return snippet, true, nil
+	}
 
-	return &snippet, nil
+	switch snippet.Visibility {
+	case VisibilityPrivate:
+		return nil, false, ErrSnippetNotFound
+	case VisibilityPassword:
+		if access.Password == "" {
+			return nil, false, ErrSnippetPasswordRequired
+		}
+		if bcrypt.CompareHashAndPassword([]byte(snippet.PasswordHash), []byte(access.Password)) != nil {
+			return nil, false, ErrSnippetPasswordInvalid
+		}
+	}
+	return snippet, false, nil
+}
+
+// read loads a readable snippet and counts the view. then, if set, runs
+// before a snippet that reached max_views is deleted, so it can still read
+// the snippet's revisions.
+func (s *SnippetService) read(ctx context.Context, id string, access SnippetAccess, then func(snippet *models.Snippet) error) (*models.Snippet, error) {
+	snippet, editor, err := s.readable(ctx, id, access)
+	if err != nil {
+		return nil, err
+	}
+
+	if !editor {
+		if err := s.countView(ctx, snippet, access); err != nil {
+			return nil, err
+		}
+	}
+
+	if then != nil {
+		if err := then(snippet); err != nil {
+			return nil, err
+		}
+	}
+
+	if snippet.MaxViews > 0 && snippet.Views >= snippet.MaxViews {
+		if err := s.purge(ctx, snippet); err != nil {
+			return nil, err
+		}
+	}
+	return snippet, nil
+}
+
+// countView counts a view by a reader. Views of snippets with a view limit
+// are written at once and never de-duplicated, so every read uses one up;
+// other views go through the view counter.
+func (s *SnippetService) countView(ctx context.Context, snippet *models.Snippet, access SnippetAccess) error {
+	if snippet.MaxViews == 0 {
+		visitor := access.Visitor
+		if user := UserFrom(ctx); user != "" {
+			visitor = "user:" + user
+		}
+		// Include the views other readers made since the last flush
+		snippet.Views += s.Views.pendingViews(snippet.ID)
+		counted, err := s.Views.RecordView(ctx, snippet.ID, visitor)
+		if counted {
+			snippet.Views++
+		}
+		return err
+	}
+
+	// Count the view without touching updated_at, unless concurrent reads
+	// used up the last one
+	result := s.DB.WithContext(ctx).Model(&models.Snippet{}).
+		Where("id = ? AND views < max_views", snippet.ID).
+		UpdateColumn("views", gorm.Expr("views + 1"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrSnippetNotFound
+	}
+	snippet.Views++
+	if err := s.Views.logView(ctx, snippet.ID); err != nil {
+		slog.WarnContext(ctx, "failed to record snippet view", "snippet_id", snippet.ID, "error", err)
+	}
+	return nil
+}
+
+// authorize loads a snippet the caller may modify. Private snippets are
+// reported as missing to anyone else.
+func (s *SnippetService) authorize(ctx context.Context, id string, access SnippetAccess) (*models.Snippet, error) {
+	snippet, err := s.load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if canEdit(ctx, snippet, access) {
+		return snippet, nil
+	}
+	if snippet.Visibility == VisibilityPrivate {
+		return nil, ErrSnippetNotFound
+	}
+	return nil, ErrSnippetForbidden
+}
+
+// canEdit reports whether the caller owns the snippet or holds its edit
+// token
+func canEdit(ctx context.Context, snippet *models.Snippet, access SnippetAccess) bool {
+	if user := UserFrom(ctx); user != "" && user == snippet.Owner {
+		return true
+	}
+	return access.EditToken != "" && snippet.EditTokenHash != "" &&
+		subtle.ConstantTimeCompare([]byte(hashEditToken(access.EditToken)), []byte(snippet.EditTokenHash)) == 1
+}
+
+// newEditToken returns a random URL-safe token
+func newEditToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashEditToken returns the stored form of an edit token
+func hashEditToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
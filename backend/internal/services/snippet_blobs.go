@@ -0,0 +1,204 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/online-compiler/backend/internal/database"
+	"github.com/online-compiler/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// storeContents saves code, or files if there are any, as blobs and
+// returns the references to store instead
+func (s *SnippetService) storeContents(tx *gorm.DB, code string, files models.SnippetFiles) (string, models.BlobFiles, error) {
+	if len(files) == 0 {
+		hash, err := s.Blobs.Put(tx, code)
+		return hash, nil, err
+	}
+
+	blobs := make(models.BlobFiles, len(files))
+	for i, file := range files {
+		hash, err := s.Blobs.Put(tx, file.Content)
+		if err != nil {
+			return "", nil, err
+		}
+		blobs[i] = models.BlobFile{Name: file.Name, Language: file.Language, Hash: hash}
+	}
+	return "", blobs, nil
+}
+
+// releaseContents drops the references saved by storeContents
+func (s *SnippetService) releaseContents(tx *gorm.DB, codeHash string, files models.BlobFiles) error {
+	return s.Blobs.Release(tx, blobHashes(codeHash, files)...)
+}
+
+// loadContents returns the code and files that codeHash and files refer
+// to. The code of multi-file contents is their entry point.
+func (s *SnippetService) loadContents(db *gorm.DB, codeHash string, files models.BlobFiles) (string, models.SnippetFiles, error) {
+	contents, err := s.Blobs.Get(db, blobHashes(codeHash, files)...)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(files) == 0 {
+		return contents[codeHash], nil, nil
+	}
+
+	loaded := make(models.SnippetFiles, len(files))
+	for i, file := range files {
+		loaded[i] = models.SnippetFile{Name: file.Name, Language: file.Language, Content: contents[file.Hash]}
+	}
+	return loaded[0].Content, loaded, nil
+}
+
+// blobHashes lists the blobs referred to by single-file or multi-file
+// contents
+func blobHashes(codeHash string, files models.BlobFiles) []string {
+	if len(files) == 0 {
+		return []string{codeHash}
+	}
+	hashes := make([]string, len(files))
+	for i, file := range files {
+		hashes[i] = file.Hash
+	}
+	return hashes
+}
+
+// saveSnippet stores a new snippet with its contents in blobs
+func (s *SnippetService) saveSnippet(tx *gorm.DB, snippet *models.Snippet) (err error) {
+	if snippet.CodeHash, snippet.FileBlobs, err = s.storeContents(tx, snippet.Code, snippet.Files); err != nil {
+		return err
+	}
+	if err := tx.Model(&models.Snippet{}).Select("COALESCE(MAX(search_key), 0) + 1").Scan(&snippet.SearchKey).Error; err != nil {
+		return err
+	}
+	if err := tx.Create(snippet).Error; err != nil {
+		return err
+	}
+	return s.index(tx, snippet)
+}
+
+// saveRevision stores a new revision with its contents in blobs
+func (s *SnippetService) saveRevision(tx *gorm.DB, rev *models.SnippetRevision) (err error) {
+	if rev.CodeHash, rev.FileBlobs, err = s.storeContents(tx, rev.Code, rev.Files); err != nil {
+		return err
+	}
+	return tx.Create(rev).Error
+}
+
+// CollectBlobs deletes blobs no snippet or revision refers to any more and
+// returns how many were removed
+func (s *SnippetService) CollectBlobs(ctx context.Context) (int64, error) {
+	return s.Blobs.Collect(ctx, s.DB)
+}
+
+// MigrateContents moves code stored inline by earlier versions into blobs,
+// dropping the old columns, gives snippets from before search keys one,
+// and indexes every snippet for search if the index is empty. It runs once
+// at startup.
+func (s *SnippetService) MigrateContents(ctx context.Context) error {
+	for _, table := range []string{"snippets", "snippet_revisions"} {
+		if !s.DB.Migrator().HasColumn(table, "code") {
+			continue
+		}
+		if err := s.migrateInlineCode(ctx, table); err != nil {
+			return err
+		}
+	}
+
+	// Keys after the largest one, so they never collide
+	err := s.DB.WithContext(ctx).Exec("UPDATE snippets SET search_key = (SELECT COALESCE(MAX(search_key), 0) FROM snippets) + rowid WHERE search_key IS NULL").Error
+	if err != nil {
+		return err
+	}
+
+	if !s.fullText {
+		return nil
+	}
+	var indexed, stored bool
+	if err := s.DB.WithContext(ctx).Raw("SELECT EXISTS (SELECT 1 FROM " + database.SnippetSearchTable + ")").Scan(&indexed).Error; err != nil {
+		return err
+	}
+	if err := s.DB.WithContext(ctx).Raw("SELECT EXISTS (SELECT 1 FROM snippets)").Scan(&stored).Error; err != nil {
+		return err
+	}
+	if indexed || !stored {
+		return nil
+	}
+
+	var snippets []models.Snippet
+	return s.DB.WithContext(ctx).FindInBatches(&snippets, 100, func(tx *gorm.DB, _ int) error {
+		for i := range snippets {
+			var err error
+			if snippets[i].Code, snippets[i].Files, err = s.loadContents(tx, snippets[i].CodeHash, snippets[i].FileBlobs); err != nil {
+				return err
+			}
+			if err := s.index(tx, &snippets[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
+
+// migrateInlineCode moves the code and files columns of table into blobs.
+// Tables from before multi-file snippets have no files column.
+func (s *SnippetService) migrateInlineCode(ctx context.Context, table string) error {
+	columns, selectFiles := []string{"code"}, "NULL"
+	if s.DB.Migrator().HasColumn(table, "files") {
+		columns, selectFiles = append(columns, "files"), "files"
+	}
+
+	return s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		rows, err := tx.Raw("SELECT rowid, code, " + selectFiles + " FROM " + table).Rows()
+		if err != nil {
+			return err
+		}
+		type inline struct {
+			rowid int64
+			code  string
+			files models.SnippetFiles
+		}
+		var pending []inline
+		for rows.Next() {
+			var row inline
+			var files sql.NullString
+			if err := rows.Scan(&row.rowid, &row.code, &files); err != nil {
+				rows.Close()
+				return err
+			}
+			if files.Valid && files.String != "" && files.String != "null" {
+				if err := json.Unmarshal([]byte(files.String), &row.files); err != nil {
+					rows.Close()
+					return err
+				}
+			}
+			pending = append(pending, row)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, row := range pending {
+			codeHash, fileBlobs, err := s.storeContents(tx, row.code, row.files)
+			if err != nil {
+				return err
+			}
+			err = tx.Table(table).Where("rowid = ?", row.rowid).
+				UpdateColumns(map[string]interface{}{"code_hash": codeHash, "file_blobs": fileBlobs}).Error
+			if err != nil {
+				return err
+			}
+		}
+		for _, column := range columns {
+			if err := tx.Exec("ALTER TABLE " + table + " DROP COLUMN " + column).Error; err != nil {
+				return err
+			}
+		}
+		slog.Info("moved inline code into blobs", "table", table, "rows", len(pending))
+		return nil
+	})
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/online-compiler/backend/internal/metrics"
+	"github.com/online-compiler/backend/internal/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrInvalidSnippetOptions is wrapped by errors describing invalid
+// visibility, password, expiry or view limit settings
+var ErrInvalidSnippetOptions = errors.New("invalid snippet options")
+
+// Visibilities lists the accepted snippet visibility levels
+var Visibilities = []string{VisibilityPublic, VisibilityUnlisted, VisibilityPrivate, VisibilityPassword}
+
+// listedVisibilities are shown in listings to users other than the owner
+var listedVisibilities = []string{VisibilityPublic, VisibilityPassword}
+
+// minSnippetPasswordLength is the shortest accepted snippet password
+const minSnippetPasswordLength = 4
+
+// applySnippetOptions validates opts and applies them to a new snippet. An
+// empty visibility keeps the snippet's current one, or public.
+func applySnippetOptions(snippet *models.Snippet, opts models.SnippetOptions) error {
+	invalid := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: %s", ErrInvalidSnippetOptions, fmt.Sprintf(format, args...))
+	}
+
+	if opts.Visibility != "" {
+		if !slices.Contains(Visibilities, opts.Visibility) {
+			return invalid("visibility must be one of public, unlisted, private or password")
+		}
+		snippet.Visibility = opts.Visibility
+	}
+	if snippet.Visibility == "" {
+		snippet.Visibility = VisibilityPublic
+	}
+
+	switch {
+	case snippet.Visibility != VisibilityPassword && opts.Password != "":
+		return invalid("password requires password visibility")
+	case snippet.Visibility == VisibilityPassword && opts.Password != "":
+		if len(opts.Password) < minSnippetPasswordLength {
+			return invalid("password must be at least %d characters", minSnippetPasswordLength)
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(opts.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return invalid("password cannot be hashed: %v", err)
+		}
+		snippet.PasswordHash = string(hash)
+	case snippet.Visibility == VisibilityPassword && snippet.PasswordHash == "":
+		return invalid("password visibility requires a password")
+	}
+
+	switch {
+	case opts.ExpiresAt != nil && opts.ExpiresIn != 0:
+		return invalid("set only one of expires_at and expires_in")
+	case opts.ExpiresIn < 0:
+		return invalid("expires_in must be positive")
+	case opts.ExpiresIn > 0:
+		expiresAt := time.Now().Add(time.Duration(opts.ExpiresIn) * time.Second)
+		snippet.ExpiresAt = &expiresAt
+	case opts.ExpiresAt != nil:
+		if !opts.ExpiresAt.After(time.Now()) {
+			return invalid("expires_at must be in the future")
+		}
+		snippet.ExpiresAt = opts.ExpiresAt
+	}
+
+	if opts.MaxViews < 0 {
+		return invalid("max_views must not be negative")
+	}
+	snippet.MaxViews = opts.MaxViews
+	return nil
+}
+
+// snippetGone reports whether a snippet has expired or used up its views
+func snippetGone(snippet *models.Snippet, now time.Time) bool {
+	if snippet.ExpiresAt != nil && !snippet.ExpiresAt.After(now) {
+		return true
+	}
+	return snippet.MaxViews > 0 && snippet.Views >= snippet.MaxViews
+}
+
+// Sweep deletes every expired or burned snippet with its revisions and
+// returns how many were removed
+func (s *SnippetService) Sweep(ctx context.Context) (int, error) {
+	var gone []models.Snippet
+	err := s.DB.WithContext(ctx).
+		Where("expires_at <= ? OR (max_views > 0 AND views >= max_views)", time.Now()).
+		Find(&gone).Error
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for i := range gone {
+		if err := s.purge(ctx, &gone[i]); err != nil {
+			return removed, err
+		}
+		reason := "expired"
+		if gone[i].ExpiresAt == nil || gone[i].ExpiresAt.After(time.Now()) {
+			reason = "burned"
+		}
+		metrics.SnippetsPurged.WithLabelValues(reason).Inc()
+		removed++
+	}
+	return removed, nil
+}
+
+// SnippetSweeper periodically purges expired and burned snippets and
+// collects unreferenced blobs
+type SnippetSweeper struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// StartSnippetSweeper sweeps snippets and blobs every interval until closed
+func StartSnippetSweeper(snippets *SnippetService, interval time.Duration) *SnippetSweeper {
+	ctx, cancel := context.WithCancel(context.Background())
+	sweeper := &SnippetSweeper{cancel: cancel, done: make(chan struct{})}
+
+	go func() {
+		defer close(sweeper.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			removed, err := snippets.Sweep(ctx)
+			if err != nil && ctx.Err() == nil {
+				slog.Error("snippet sweep failed", "error", err)
+			}
+			if removed > 0 {
+				slog.Info("purged expired snippets", "count", removed)
+			}
+
+			collected, err := snippets.CollectBlobs(ctx)
+			if err != nil && ctx.Err() == nil {
+				slog.Error("blob collection failed", "error", err)
+			}
+			if collected > 0 {
+				slog.Info("collected unreferenced blobs", "count", collected)
+			}
+		}
+	}()
+
+	return sweeper
+}
+
+// Close stops the sweeper and waits for a running sweep to finish
+func (s *SnippetSweeper) Close() {
+	s.cancel()
+	<-s.done
+}
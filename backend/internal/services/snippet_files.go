@@ -0,0 +1,114 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/online-compiler/backend/internal/models"
+)
+
+// ErrInvalidSnippetFiles is wrapped by errors describing invalid snippet or
+// project contents
+var ErrInvalidSnippetFiles = errors.New("invalid snippet files")
+
+const (
+	// MaxSnippetFiles limits the number of files in a snippet or project
+	MaxSnippetFiles = 20
+	// maxFileNameLength limits the length of file names, including their
+	// directories
+	maxFileNameLength = 100
+)
+
+// ValidateFiles checks that files holds between 1 and MaxSnippetFiles
+// files with unique, relative names that stay inside the project
+func ValidateFiles(files []models.SnippetFile) error {
+	if len(files) == 0 || len(files) > MaxSnippetFiles {
+		return fmt.Errorf("%w: between 1 and %d files are required", ErrInvalidSnippetFiles, MaxSnippetFiles)
+	}
+
+	seen := make(map[string]bool, len(files))
+	for _, file := range files {
+		if !validFileName(file.Name) {
+			return fmt.Errorf("%w: invalid file name %q", ErrInvalidSnippetFiles, file.Name)
+		}
+		if seen[file.Name] {
+			return fmt.Errorf("%w: duplicate file name %q", ErrInvalidSnippetFiles, file.Name)
+		}
+		seen[file.Name] = true
+	}
+	return nil
+}
+
+// validFileName accepts clean relative slash-separated paths such as
+// "main.py" or "lib/util.py"
+func validFileName(name string) bool {
+	return name != "" && len(name) <= maxFileNameLength &&
+		!strings.ContainsAny(name, "\\\x00") &&
+		!path.IsAbs(name) && path.Clean(name) == name &&
+		name != "." && name != ".." && !strings.HasPrefix(name, "../")
+}
+
+// applySnippetContents validates the contents of req and copies them to
+// snippet. Files without a language take the snippet's language, and the
+// snippet's language and code mirror the entry point. The language ID is
+// looked up from the language unless req sets it.
+func applySnippetContents(snippet *models.Snippet, req *models.SnippetRequest) error {
+	invalid := func(msg string) error {
+		return fmt.Errorf("%w: %s", ErrInvalidSnippetFiles, msg)
+	}
+
+	if len(req.Files) == 0 {
+		if req.Language == "" || req.Code == "" {
+			return invalid("language and code, or files, are required")
+		}
+		snippet.Language, snippet.Code, snippet.Files = req.Language, req.Code, nil
+		return setLanguageID(snippet, req.LanguageID)
+	}
+
+	if req.Code != "" {
+		return invalid("set only one of code and files")
+	}
+	if err := ValidateFiles(req.Files); err != nil {
+		return err
+	}
+
+	files := make(models.SnippetFiles, len(req.Files))
+	copy(files, req.Files)
+	language := req.Language
+	if language == "" {
+		language = files[0].Language
+	}
+	if language == "" {
+		return invalid("language is required")
+	}
+	for i := range files {
+		if files[i].Language == "" {
+			files[i].Language = language
+		}
+	}
+
+	snippet.Language, snippet.Code, snippet.Files = files[0].Language, files[0].Content, files
+	return setLanguageID(snippet, req.LanguageID)
+}
+
+// setLanguageID sets the language ID used to run the snippet
+func setLanguageID(snippet *models.Snippet, languageID int) (err error) {
+	snippet.LanguageID, err = snippetLanguageID(snippet.Language, languageID)
+	return err
+}
+
+// SnippetFiles returns a snippet's files, presenting single-file snippets
+// as one file named after their language
+func SnippetFiles(snippet *models.Snippet) []models.SnippetFile {
+	return contentFiles(snippet.Language, snippet.Code, snippet.Files)
+}
+
+// contentFiles returns files, or code as a single file if there are none
+func contentFiles(language, code string, files models.SnippetFiles) []models.SnippetFile {
+	if len(files) > 0 {
+		return files
+	}
+	return []models.SnippetFile{{Name: LanguageFileName(language), Language: language, Content: code}}
+}
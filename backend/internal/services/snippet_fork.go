@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/online-compiler/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// ForkSnippet copies a snippet, or one of its revisions, under a new ID
+// owned by the user attached to ctx. The copy records its parent and the
+// parent's fork count is incremented. Forking counts as a view of the
+// parent.
+func (s *SnippetService) ForkSnippet(ctx context.Context, id string, req *models.ForkSnippetRequest, access SnippetAccess) (*models.Snippet, string, error) {
+	var fork *models.Snippet
+	var editToken string
+	_, err := s.read(ctx, id, access, func(parent *models.Snippet) (err error) {
+		fork = &models.Snippet{
+			Language:       parent.Language,
+			Code:           parent.Code,
+			Files:          parent.Files,
+			Tags:           parent.Tags,
+			LanguageID:     parent.LanguageID,
+			Stdin:          parent.Stdin,
+			Args:           parent.Args,
+			Title:          parent.Title,
+			ParentID:       &parent.ID,
+			ParentRevision: parent.Revision,
+			Visibility:     parent.Visibility,
+			PasswordHash:   parent.PasswordHash,
+		}
+		if req.Revision != 0 {
+			rev, err := s.revision(ctx, id, req.Revision)
+			if err != nil {
+				return err
+			}
+			if rev.Language != parent.Language {
+				fork.LanguageID, _ = LanguageID(rev.Language)
+			}
+			fork.Language, fork.Code, fork.Files, fork.Title = rev.Language, rev.Code, rev.Files, rev.Title
+			fork.ParentRevision = rev.Revision
+		}
+		if req.Title != "" {
+			fork.Title = req.Title
+		}
+		if req.Visibility != "" {
+			fork.PasswordHash = ""
+		}
+		if err := applySnippetOptions(fork, req.SnippetOptions); err != nil {
+			return err
+		}
+
+		editToken, err = s.create(ctx, fork, func(tx *gorm.DB) error {
+			return tx.Model(&models.Snippet{}).Where("id = ?", parent.ID).
+				UpdateColumn("fork_count", gorm.Expr("fork_count + 1")).Error
+		})
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return fork, editToken, nil
+}
+
+// ListForks returns the direct forks of a snippet, oldest first. Unlisted
+// and private forks are only included for their owner, and expired or
+// burned ones not at all.
+func (s *SnippetService) ListForks(ctx context.Context, id string, access SnippetAccess) ([]models.SnippetSummary, error) {
+	if _, _, err := s.readable(ctx, id, access); err != nil {
+		return nil, err
+	}
+
+	forks := []models.SnippetSummary{}
+	query := s.DB.WithContext(ctx).Model(&models.Snippet{}).
+		Where("parent_id = ?", id).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		Where("max_views = 0 OR views < max_views")
+	if user := UserFrom(ctx); user != "" {
+		query = query.Where("visibility IN ? OR owner = ?", listedVisibilities, user)
+	} else {
+		query = query.Where("visibility IN ?", listedVisibilities)
+	}
+	err := query.Order("created_at, id").Find(&forks).Error
+	return forks, err
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/online-compiler/backend/internal/models"
+	"github.com/pmezard/go-difflib/difflib"
+	"gorm.io/gorm"
+)
+
+// ListRevisions returns a snippet's revisions, oldest first, without code.
+// Listing does not count as a view.
+func (s *SnippetService) ListRevisions(ctx context.Context, id string, access SnippetAccess) ([]models.SnippetRevision, error) {
+	if _, _, err := s.readable(ctx, id, access); err != nil {
+		return nil, err
+	}
+
+	revisions := []models.SnippetRevision{}
+	err := s.DB.WithContext(ctx).
+		Select("snippet_id", "revision", "language", "title", "author", "created_at").
+		Where("snippet_id = ?", id).
+		Order("revision").
+		Find(&revisions).Error
+	return revisions, err
+}
+
+// GetRevision returns one revision of a snippet. Like GetSnippet it counts
+// as a view.
+func (s *SnippetService) GetRevision(ctx context.Context, id string, revision int, access SnippetAccess) (*models.SnippetRevision, error) {
+	var rev *models.SnippetRevision
+	_, err := s.read(ctx, id, access, func(*models.Snippet) (err error) {
+		rev, err = s.revision(ctx, id, revision)
+		return err
+	})
+	return rev, err
+}
+
+// DiffRevisions returns a unified diff of the code from one revision to
+// another. Multi-file revisions are diffed file by file, with added and
+// removed files diffed against nothing. Like GetSnippet it counts as a
+// view.
+func (s *SnippetService) DiffRevisions(ctx context.Context, id string, from, to int, access SnippetAccess) (string, error) {
+	var fromRev, toRev *models.SnippetRevision
+	_, err := s.read(ctx, id, access, func(*models.Snippet) (err error) {
+		if fromRev, err = s.revision(ctx, id, from); err != nil {
+			return err
+		}
+		toRev, err = s.revision(ctx, id, to)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if len(fromRev.Files) == 0 && len(toRev.Files) == 0 {
+		return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        diffLines(fromRev.Code),
+			B:        diffLines(toRev.Code),
+			FromFile: fmt.Sprintf("revision %d", from),
+			ToFile:   fmt.Sprintf("revision %d", to),
+			Context:  3,
+		})
+	}
+
+	fromFiles := contentFiles(fromRev.Language, fromRev.Code, fromRev.Files)
+	toFiles := contentFiles(toRev.Language, toRev.Code, toRev.Files)
+	contents := func(files []models.SnippetFile, name string) string {
+		for _, file := range files {
+			if file.Name == name {
+				return file.Content
+			}
+		}
+		return ""
+	}
+
+	// Diff files in the order they first appear
+	var names []string
+	seen := make(map[string]bool)
+	for _, file := range append(slices.Clone(fromFiles), toFiles...) {
+		if !seen[file.Name] {
+			seen[file.Name] = true
+			names = append(names, file.Name)
+		}
+	}
+
+	var diff strings.Builder
+	for _, name := range names {
+		fileDiff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        diffLines(contents(fromFiles, name)),
+			B:        diffLines(contents(toFiles, name)),
+			FromFile: fmt.Sprintf("revision %d/%s", from, name),
+			ToFile:   fmt.Sprintf("revision %d/%s", to, name),
+			Context:  3,
+		})
+		if err != nil {
+			return "", err
+		}
+		diff.WriteString(fileDiff)
+	}
+	return diff.String(), nil
+}
+
+// revision fetches one stored revision
+func (s *SnippetService) revision(ctx context.Context, id string, revision int) (*models.SnippetRevision, error) {
+	var rev models.SnippetRevision
+	err := s.DB.WithContext(ctx).First(&rev, "snippet_id = ? AND revision = ?", id, revision).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrRevisionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if rev.Code, rev.Files, err = s.loadContents(s.DB.WithContext(ctx), rev.CodeHash, rev.FileBlobs); err != nil {
+		return nil, err
+	}
+	return &rev, nil
+}
+
+// diffLines splits code into newline-terminated lines. A missing final
+// newline is added so the last line diffs like the others.
+func diffLines(code string) []string {
+	if code == "" {
+		return nil
+	}
+	if !strings.HasSuffix(code, "\n") {
+		code += "\n"
+	}
+	return strings.SplitAfter(code, "\n")[:strings.Count(code, "\n")]
+}
+
+// newRevision copies a snippet's current contents into a revision authored
+// by the user attached to ctx
+func newRevision(ctx context.Context, snippet *models.Snippet) *models.SnippetRevision {
+	return &models.SnippetRevision{
+		SnippetID: snippet.ID,
+		Revision:  snippet.Revision,
+		Language:  snippet.Language,
+		Code:      snippet.Code,
+		Files:     snippet.Files,
+		Title:     snippet.Title,
+		Author:    UserFrom(ctx),
+	}
+}
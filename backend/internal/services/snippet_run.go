@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/online-compiler/backend/internal/models"
+)
+
+const (
+	// maxRunArgs limits the number of program arguments
+	maxRunArgs = 32
+	// maxRunArgLength limits the length of each program argument
+	maxRunArgLength = 1024
+)
+
+// ValidateArgs checks the number and length of program arguments
+func ValidateArgs(args []string) error {
+	if len(args) > maxRunArgs {
+		return fmt.Errorf("%w: at most %d args are allowed", ErrInvalidSnippetOptions, maxRunArgs)
+	}
+	for _, arg := range args {
+		if len(arg) > maxRunArgLength {
+			return fmt.Errorf("%w: args must be at most %d bytes", ErrInvalidSnippetOptions, maxRunArgLength)
+		}
+	}
+	return nil
+}
+
+// snippetLanguageID returns the explicit language ID, or the one looked up
+// from the language name, or 0 if it is unknown
+func snippetLanguageID(language string, languageID int) (int, error) {
+	if languageID != 0 {
+		if languageID < 1 || languageID > 100 {
+			return 0, fmt.Errorf("%w: invalid language_id", ErrInvalidSnippetFiles)
+		}
+		return languageID, nil
+	}
+	id, _ := LanguageID(language)
+	return id, nil
+}
+
+// applyRunDefaults validates and copies the saved stdin and args of req.
+// Unset fields keep the snippet's current defaults.
+func applyRunDefaults(snippet *models.Snippet, req *models.SnippetRequest) error {
+	if req.Args != nil {
+		if err := ValidateArgs(req.Args); err != nil {
+			return err
+		}
+		snippet.Args = models.StringList(req.Args)
+	}
+	if req.Stdin != nil {
+		snippet.Stdin = *req.Stdin
+	}
+	return nil
+}
+
+// SaveRun stores result as the last run of the snippet's current revision.
+// Callers must have read the snippet with GetSnippet; nothing is saved if
+// the snippet has been updated or deleted since.
+func (s *SnippetService) SaveRun(ctx context.Context, snippet *models.Snippet, result *models.ExecuteResponse) error {
+	run := &models.SnippetRun{Revision: snippet.Revision, Result: *result, RanAt: time.Now()}
+	err := s.DB.WithContext(ctx).Model(&models.Snippet{}).
+		Where("id = ? AND revision = ?", snippet.ID, snippet.Revision).
+		UpdateColumn("last_run", run).Error
+	if err != nil {
+		return err
+	}
+	snippet.LastRun = run
+	return nil
+}
@@ -0,0 +1,236 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/online-compiler/backend/internal/database"
+	"github.com/online-compiler/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// Snippet listing orders
+const (
+	// SortRecent lists the newest snippets first
+	SortRecent = "recent"
+	// SortViews lists the most viewed snippets first
+	SortViews = "views"
+)
+
+const (
+	// DefaultSnippetPageSize is the page size of listings without a limit
+	DefaultSnippetPageSize = 20
+	// MaxSnippetPageSize is the largest accepted page size
+	MaxSnippetPageSize = 100
+	// maxSnippetTags limits the number of tags on one snippet
+	maxSnippetTags = 10
+)
+
+// ErrInvalidCursor is returned for malformed or mismatched page cursors
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// tagPattern accepts tags such as "ownership", "c++" or "week-3"
+var tagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9+#.\-]{0,31}$`)
+
+// SnippetQuery filters and orders a snippet listing. Empty fields do not
+// filter; every tag must be present.
+type SnippetQuery struct {
+	// Query is searched for in titles, code and files
+	Query    string
+	Language string
+	Tags     []string
+	Owner    string
+	// Sort is SortRecent (the default) or SortViews
+	Sort string
+	// Cursor is the NextCursor of the previous page
+	Cursor string
+	Limit  int
+}
+
+// snippetCursor marks the last snippet of a page
+type snippetCursor struct {
+	Sort      string    `json:"s"`
+	Views     int       `json:"v,omitempty"`
+	CreatedAt time.Time `json:"c"`
+	ID        string    `json:"i"`
+}
+
+// ListSnippets returns one page of snippets matching query and the cursor
+// of the next page, or "" on the last page. Listings show public and
+// password snippets and the caller's own; text search only matches public
+// snippets and the caller's own so protected code cannot be probed.
+// Expired and burned snippets are never listed.
+func (s *SnippetService) ListSnippets(ctx context.Context, query SnippetQuery) ([]models.SnippetSummary, string, error) {
+	if query.Sort == "" {
+		query.Sort = SortRecent
+	}
+	if query.Sort != SortRecent && query.Sort != SortViews {
+		return nil, "", fmt.Errorf("%w: sort must be recent or views", ErrInvalidSnippetOptions)
+	}
+	if query.Limit <= 0 {
+		query.Limit = DefaultSnippetPageSize
+	}
+	query.Limit = min(query.Limit, MaxSnippetPageSize)
+
+	db := s.DB.WithContext(ctx).Model(&models.Snippet{}).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		Where("max_views = 0 OR views < max_views")
+
+	user := UserFrom(ctx)
+	visible := listedVisibilities
+	if strings.TrimSpace(query.Query) != "" {
+		visible = []string{VisibilityPublic}
+	}
+	if user != "" {
+		db = db.Where("visibility IN ? OR owner = ?", visible, user)
+	} else {
+		db = db.Where("visibility IN ?", visible)
+	}
+
+	if query.Language != "" {
+		db = db.Where("LOWER(language) = ?", strings.ToLower(query.Language))
+	}
+	if query.Owner != "" {
+		db = db.Where("owner = ?", query.Owner)
+	}
+	for _, tag := range query.Tags {
+		db = db.Where("EXISTS (SELECT 1 FROM json_each(snippets.tags) WHERE json_each.value = ?)", strings.ToLower(tag))
+	}
+	if terms := strings.Fields(query.Query); len(terms) > 0 {
+		db = s.search(db, terms)
+	}
+
+	if query.Cursor != "" {
+		cursor, err := decodeSnippetCursor(query.Cursor, query.Sort)
+		if err != nil {
+			return nil, "", err
+		}
+		if query.Sort == SortViews {
+			db = db.Where("views < ? OR (views = ? AND (created_at < ? OR (created_at = ? AND id < ?)))",
+				cursor.Views, cursor.Views, cursor.CreatedAt, cursor.CreatedAt, cursor.ID)
+		} else {
+			db = db.Where("created_at < ? OR (created_at = ? AND id < ?)", cursor.CreatedAt, cursor.CreatedAt, cursor.ID)
+		}
+	}
+	if query.Sort == SortViews {
+		db = db.Order("views DESC")
+	}
+
+	// Fetch one extra row to learn whether there is a next page
+	snippets := []models.SnippetSummary{}
+	if err := db.Order("created_at DESC, id DESC").Limit(query.Limit + 1).Find(&snippets).Error; err != nil {
+		return nil, "", err
+	}
+	if len(snippets) <= query.Limit {
+		return snippets, "", nil
+	}
+
+	snippets = snippets[:query.Limit]
+	last := snippets[len(snippets)-1]
+	next, err := encodeSnippetCursor(snippetCursor{Sort: query.Sort, Views: last.Views, CreatedAt: last.CreatedAt, ID: last.ID})
+	if err != nil {
+		return nil, "", err
+	}
+	return snippets, next, nil
+}
+
+// search restricts db to snippets containing every term, as a prefix, in
+// their title, code or files. Terms are quoted so user input is never
+// parsed as FTS5 query syntax. Without FTS5 terms are matched anywhere,
+// and compressed code is decompressed by the gunzip SQL function.
+func (s *SnippetService) search(db *gorm.DB, terms []string) *gorm.DB {
+	if s.fullText {
+		quoted := make([]string, len(terms))
+		for i, term := range terms {
+			quoted[i] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"*`
+		}
+		return db.Where("snippets.search_key IN (SELECT rowid FROM "+database.SnippetSearchTable+" WHERE "+database.SnippetSearchTable+" MATCH ?)",
+			strings.Join(quoted, " "))
+	}
+
+	escape := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	for _, term := range terms {
+		pattern := "%" + escape.Replace(term) + "%"
+		db = db.Where(`title LIKE ? ESCAPE '\' OR EXISTS (SELECT 1 FROM blobs
+			WHERE (CASE WHEN blobs.compressed THEN gunzip(blobs.data) ELSE CAST(blobs.data AS TEXT) END) LIKE ? ESCAPE '\'
+			AND (blobs.hash = snippets.code_hash OR blobs.hash IN (SELECT json_extract(value, '$.hash') FROM json_each(snippets.file_blobs))))`, pattern, pattern)
+	}
+	return db
+}
+
+// index replaces a snippet's entry in the full-text index, if there is one
+func (s *SnippetService) index(tx *gorm.DB, snippet *models.Snippet) error {
+	if !s.fullText {
+		return nil
+	}
+	if err := s.unindex(tx, snippet.ID); err != nil {
+		return err
+	}
+
+	code, files := snippet.Code, ""
+	if len(snippet.Files) > 0 {
+		var text strings.Builder
+		for _, file := range snippet.Files {
+			text.WriteString(file.Name + "\n" + file.Content + "\n")
+		}
+		code, files = "", text.String()
+	}
+	return tx.Exec("INSERT INTO "+database.SnippetSearchTable+"(rowid, title, code, files) SELECT search_key, ?, ?, ? FROM snippets WHERE id = ?",
+		snippet.Title, code, files, snippet.ID).Error
+}
+
+// unindex removes a snippet from the full-text index, if there is one
+func (s *SnippetService) unindex(tx *gorm.DB, id string) error {
+	if !s.fullText {
+		return nil
+	}
+	return tx.Exec("DELETE FROM "+database.SnippetSearchTable+" WHERE rowid = (SELECT search_key FROM snippets WHERE id = ?)", id).Error
+}
+
+// normalizeTags lowercases and deduplicates tags, keeping their order
+func normalizeTags(tags []string) (models.StringList, error) {
+	if len(tags) > maxSnippetTags {
+		return nil, fmt.Errorf("%w: at most %d tags are allowed", ErrInvalidSnippetOptions, maxSnippetTags)
+	}
+
+	var normalized models.StringList
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if !tagPattern.MatchString(tag) {
+			return nil, fmt.Errorf("%w: invalid tag %q", ErrInvalidSnippetOptions, tag)
+		}
+		if !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized, nil
+}
+
+// encodeSnippetCursor returns the opaque form of a cursor
+func encodeSnippetCursor(cursor snippetCursor) (string, error) {
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeSnippetCursor parses a cursor issued for the same sort order
+func decodeSnippetCursor(value, sort string) (*snippetCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor snippetCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.Sort != sort || cursor.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
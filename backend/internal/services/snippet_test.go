@@ -0,0 +1,391 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/online-compiler/backend/internal/database"
+	"github.com/online-compiler/backend/internal/models"
+)
+
+// newSnippetTestService returns a snippet service on an in-memory database
+func newSnippetTestService(t *testing.T) *SnippetService {
+	t.Helper()
+	db, err := database.InitDatabase("file:" + t.Name() + "?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.CloseDatabase(db) })
+	return NewSnippetService(db)
+}
+
+func TestSnippetOptionsValidation(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	tests := []struct {
+		name string
+		opts models.SnippetOptions
+	}{
+		{"unknown visibility", models.SnippetOptions{Visibility: "secret"}},
+		{"password without visibility", models.SnippetOptions{Password: "hunter2"}},
+		{"missing password", models.SnippetOptions{Visibility: VisibilityPassword}},
+		{"short password", models.SnippetOptions{Visibility: VisibilityPassword, Password: "abc"}},
+		{"both expiries", models.SnippetOptions{ExpiresIn: 60, ExpiresAt: &past}},
+		{"expiry in the past", models.SnippetOptions{ExpiresAt: &past}},
+		{"negative max views", models.SnippetOptions{MaxViews: -1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := applySnippetOptions(&models.Snippet{}, tt.opts)
+			if !errors.Is(err, ErrInvalidSnippetOptions) {
+				t.Errorf("err = %v, want ErrInvalidSnippetOptions", err)
+			}
+		})
+	}
+}
+
+func TestSnippetSweep(t *testing.T) {
+	s := newSnippetTestService(t)
+	ctx := context.Background()
+
+	create := func(opts models.SnippetOptions) *models.Snippet {
+		t.Helper()
+		snippet, _, err := s.CreateSnippet(ctx, &models.SnippetRequest{Language: "python", Code: "print(1)", SnippetOptions: opts})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return snippet
+	}
+	expired := create(models.SnippetOptions{ExpiresIn: 60})
+	burned := create(models.SnippetOptions{MaxViews: 2})
+	kept := create(models.SnippetOptions{ExpiresIn: 3600, MaxViews: 2})
+
+	s.DB.Model(expired).UpdateColumn("expires_at", time.Now().Add(-time.Second))
+	s.DB.Model(burned).UpdateColumn("views", 2)
+
+	removed, err := s.Sweep(ctx)
+	if err != nil || removed != 2 {
+		t.Fatalf("Sweep() = %d, %v; want 2 removed", removed, err)
+	}
+
+	var revisions int64
+	s.DB.Model(&models.SnippetRevision{}).Where("snippet_id IN ?", []string{expired.ID, burned.ID}).Count(&revisions)
+	if revisions != 0 {
+		t.Errorf("%d revisions of swept snippets remain", revisions)
+	}
+	if _, err := s.GetSnippet(ctx, kept.ID, SnippetAccess{}); err != nil {
+		t.Errorf("unexpired snippet: %v", err)
+	}
+}
+
+func TestListSnippets(t *testing.T) {
+	s := newSnippetTestService(t)
+	owner := WithUser(context.Background(), "staff")
+	anonymous := context.Background()
+
+	create := func(ctx context.Context, req models.SnippetRequest) *models.Snippet {
+		t.Helper()
+		snippet, _, err := s.CreateSnippet(ctx, &req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return snippet
+	}
+	borrow := create(owner, models.SnippetRequest{Language: "rust", Title: "Borrowing", Code: "fn borrow(v: &Vec<i32>) {}", Tags: []string{"Ownership", "borrowing"}})
+	moves := create(owner, models.SnippetRequest{Language: "Rust", Title: "Moves", Code: "let b = a;", Tags: []string{"ownership"}})
+	create(owner, models.SnippetRequest{Language: "rust", Title: "Secret", Code: "fn borrow() {}", Tags: []string{"ownership"},
+		SnippetOptions: models.SnippetOptions{Visibility: VisibilityPrivate}})
+	create(anonymous, models.SnippetRequest{Language: "rust", Title: "Locked", Code: "fn borrow() {}",
+		SnippetOptions: models.SnippetOptions{Visibility: VisibilityPassword, Password: "hunter2"}})
+	create(anonymous, models.SnippetRequest{Language: "python", Title: "Lists", Code: "xs = [1]", Tags: []string{"ownership"}})
+	s.DB.Model(&models.Snippet{}).Where("id = ?", moves.ID).Update("views", 5)
+
+	list := func(ctx context.Context, query SnippetQuery) []string {
+		t.Helper()
+		snippets, _, err := s.ListSnippets(ctx, query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var titles []string
+		for _, snippet := range snippets {
+			titles = append(titles, snippet.Title)
+		}
+		return titles
+	}
+
+	tests := []struct {
+		name  string
+		ctx   context.Context
+		query SnippetQuery
+		want  []string
+	}{
+		{"public rust tagged ownership", anonymous, SnippetQuery{Language: "rust", Tags: []string{"ownership"}}, []string{"Moves", "Borrowing"}},
+		{"owner sees private", owner, SnippetQuery{Language: "rust", Tags: []string{"OWNERSHIP"}}, []string{"Secret", "Moves", "Borrowing"}},
+		{"all tags required", anonymous, SnippetQuery{Tags: []string{"ownership", "borrowing"}}, []string{"Borrowing"}},
+		{"password snippets are listed", anonymous, SnippetQuery{Owner: ""}, []string{"Lists", "Locked", "Moves", "Borrowing"}},
+		{"search skips protected code", anonymous, SnippetQuery{Query: "borrow"}, []string{"Borrowing"}},
+		{"search by prefix and title", owner, SnippetQuery{Query: "secr fn"}, []string{"Secret"}},
+		{"owner filter", anonymous, SnippetQuery{Owner: "staff"}, []string{"Moves", "Borrowing"}},
+		{"most viewed first", anonymous, SnippetQuery{Sort: SortViews, Language: "rust"}, []string{"Moves", "Locked", "Borrowing"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := list(tt.ctx, tt.query); !slices.Equal(got, tt.want) {
+				t.Errorf("titles = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Walk every page of two, in both orders
+	for _, sort := range []string{SortRecent, SortViews} {
+		var titles []string
+		query := SnippetQuery{Sort: sort, Limit: 2}
+		for page := 0; ; page++ {
+			snippets, next, err := s.ListSnippets(anonymous, query)
+			if err != nil || page > 3 {
+				t.Fatalf("page %d: err = %v", page, err)
+			}
+			for _, snippet := range snippets {
+				titles = append(titles, snippet.Title)
+			}
+			if next == "" {
+				break
+			}
+			query.Cursor = next
+		}
+		if len(titles) != 4 || titles[0] != map[string]string{SortRecent: "Lists", SortViews: "Moves"}[sort] {
+			t.Errorf("%s pages = %v", sort, titles)
+		}
+	}
+
+	// Updates are searchable and keep tags unless new ones are given
+	if _, err := s.UpdateSnippet(owner, moves.ID, &models.SnippetRequest{Language: "rust", Title: "Moved", Code: "let c = d;"}, SnippetAccess{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := list(anonymous, SnippetQuery{Query: "moved", Tags: []string{"ownership"}}); !slices.Equal(got, []string{"Moved"}) {
+		t.Errorf("titles after update = %v, want [Moved]", got)
+	}
+
+	if _, _, err := s.ListSnippets(anonymous, SnippetQuery{Cursor: "bogus"}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("bogus cursor err = %v", err)
+	}
+	if _, _, err := s.CreateSnippet(anonymous, &models.SnippetRequest{Language: "rust", Code: "x", Tags: []string{"no spaces"}}); !errors.Is(err, ErrInvalidSnippetOptions) {
+		t.Errorf("invalid tag err = %v", err)
+	}
+	if borrow.Tags[0] != "ownership" {
+		t.Errorf("tags = %v, want lowercase", borrow.Tags)
+	}
+}
+
+func TestViewCounter(t *testing.T) {
+	s := newSnippetTestService(t)
+	s.Views = StartViewCounter(s.DB, time.Hour, time.Hour)
+	t.Cleanup(s.Views.Close)
+	ctx := context.Background()
+
+	snippet, editToken, err := s.CreateSnippet(ctx, &models.SnippetRequest{Language: "python", Code: "print(1)"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every visitor reads twice, concurrently
+	var wg sync.WaitGroup
+	for i := range 40 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.GetSnippet(ctx, snippet.ID, SnippetAccess{Visitor: fmt.Sprint("visitor-", i%20)}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	for range 3 {
+		if err := s.RecordRun(ctx, snippet.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var stored models.Snippet
+	s.DB.First(&stored, "id = ?", snippet.ID)
+	if stored.Views != 0 {
+		t.Errorf("views = %d before flushing, want 0", stored.Views)
+	}
+
+	stats, err := s.SnippetStats(ctx, snippet.ID, 7, SnippetAccess{EditToken: editToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+	today := stats.Daily[len(stats.Daily)-1]
+	if stats.Views != 20 || stats.Runs != 3 || len(stats.Daily) != 7 || today.Day != time.Now().UTC().Format(time.DateOnly) || today.Views != 20 || today.Runs != 3 {
+		t.Errorf("stats = %+v, want 20 views and 3 runs today", stats)
+	}
+	if _, err := s.SnippetStats(ctx, snippet.ID, 7, SnippetAccess{}); !errors.Is(err, ErrSnippetForbidden) {
+		t.Errorf("stats without edit token: %v, want ErrSnippetForbidden", err)
+	}
+
+	// Stats include the buffered counts without writing them
+	s.DB.First(&stored, "id = ?", snippet.ID)
+	if stored.Views != 0 {
+		t.Errorf("views = %d after reading stats, want 0 until the next flush", stored.Views)
+	}
+
+	// Counts of deleted snippets are dropped
+	if _, err := s.GetSnippet(ctx, snippet.ID, SnippetAccess{Visitor: "late"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteSnippet(ctx, snippet.ID, SnippetAccess{EditToken: editToken}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Views.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var rows int64
+	s.DB.Model(&models.SnippetDailyStats{}).Count(&rows)
+	if rows != 0 {
+		t.Errorf("%d stats rows remain after delete", rows)
+	}
+}
+
+func TestSnippetBlobs(t *testing.T) {
+	s := newSnippetTestService(t)
+	ctx := context.Background()
+
+	refs := func(content string) int {
+		t.Helper()
+		var blob models.Blob
+		if err := s.DB.Where("hash = ?", BlobHash(content)).Limit(1).Find(&blob).Error; err != nil {
+			t.Fatal(err)
+		}
+		return blob.Refs
+	}
+
+	// Identical code is stored once, referenced by each snippet and revision
+	hello := `print("Hello, World!")`
+	first, firstToken, err := s.CreateSnippet(ctx, &models.SnippetRequest{Language: "python", Code: hello})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, secondToken, err := s.CreateSnippet(ctx, &models.SnippetRequest{Language: "python", Code: hello})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := refs(hello); got != 4 {
+		t.Errorf("hello refs = %d, want 4", got)
+	}
+
+	large := strings.Repeat("print('compress me')\n", 200)
+	if _, err := s.UpdateSnippet(ctx, first.ID, &models.SnippetRequest{Language: "python", Code: large}, SnippetAccess{EditToken: firstToken}); err != nil {
+		t.Fatal(err)
+	}
+	var blob models.Blob
+	s.DB.First(&blob, "hash = ?", BlobHash(large))
+	if !blob.Compressed || len(blob.Data) >= len(large) || blob.Size != len(large) || blob.Refs != 2 {
+		t.Errorf("large blob = compressed %v, %d of %d bytes, %d refs; want compressed with 2 refs", blob.Compressed, len(blob.Data), blob.Size, blob.Refs)
+	}
+	if found, _, err := s.ListSnippets(ctx, SnippetQuery{Query: "compress"}); err != nil || len(found) != 1 || found[0].ID != first.ID {
+		t.Errorf("search in compressed code = %+v, %v", found, err)
+	}
+	if got := refs(hello); got != 3 {
+		t.Errorf("hello refs after update = %d, want 3", got)
+	}
+	snippet, err := s.GetSnippet(ctx, first.ID, SnippetAccess{EditToken: firstToken})
+	if err != nil || snippet.Code != large {
+		t.Fatalf("GetSnippet() = %v, want the large code back", err)
+	}
+	rev, err := s.GetRevision(ctx, first.ID, 1, SnippetAccess{EditToken: firstToken})
+	if err != nil || rev.Code != hello {
+		t.Fatalf("GetRevision(1) = %v, want the original code", err)
+	}
+
+	// Deleting releases every reference and collection removes the blobs
+	for id, token := range map[string]string{first.ID: firstToken, second.ID: secondToken} {
+		if err := s.DeleteSnippet(ctx, id, SnippetAccess{EditToken: token}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if collected, err := s.CollectBlobs(ctx); err != nil || collected != 2 {
+		t.Errorf("CollectBlobs() = %d, %v; want 2", collected, err)
+	}
+}
+
+func TestMigrateContents(t *testing.T) {
+	s := newSnippetTestService(t)
+	ctx := context.Background()
+
+	// Earlier versions stored code inline
+	files := `[{"name":"main.py","language":"python","content":"import util"},{"name":"util.py","content":"X = 1"}]`
+	for _, statement := range []string{
+		"ALTER TABLE snippets ADD COLUMN code TEXT NOT NULL DEFAULT ''",
+		"ALTER TABLE snippets ADD COLUMN files TEXT",
+		"INSERT INTO snippets (id, language, code, title, views, revision) VALUES ('single', 'python', 'print(1)', 'Single', 0, 0)",
+		"INSERT INTO snippets (id, language, code, files, title, views, revision) VALUES ('multi', 'python', 'import util', '" + files + "', 'Multi', 0, 0)",
+	} {
+		if err := s.DB.Exec(statement).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := s.MigrateContents(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if s.DB.Migrator().HasColumn("snippets", "code") || s.DB.Migrator().HasColumn("snippets", "files") {
+		t.Error("inline code columns were not dropped")
+	}
+
+	single, err := s.GetSnippet(ctx, "single", SnippetAccess{})
+	if err != nil || single.Code != "print(1)" {
+		t.Errorf("single = %+v, %v", single, err)
+	}
+	multi, err := s.GetSnippet(ctx, "multi", SnippetAccess{})
+	if err != nil || multi.Code != "import util" || len(multi.Files) != 2 || multi.Files[1].Content != "X = 1" {
+		t.Errorf("multi = %+v, %v", multi, err)
+	}
+	if single.SearchKey == 0 || multi.SearchKey == 0 || single.SearchKey == multi.SearchKey {
+		t.Errorf("search keys = %d, %d; want distinct keys", single.SearchKey, multi.SearchKey)
+	}
+	if found, _, err := s.ListSnippets(ctx, SnippetQuery{Query: "util"}); err != nil || len(found) != 1 || found[0].ID != "multi" {
+		t.Errorf("search after migration = %+v, %v", found, err)
+	}
+}
+
+func TestSnippetSearchKeys(t *testing.T) {
+	s := newSnippetTestService(t)
+	ctx := context.Background()
+
+	create := func(code string) (*models.Snippet, string) {
+		t.Helper()
+		snippet, token, err := s.CreateSnippet(ctx, &models.SnippetRequest{Language: "python", Title: code, Code: code})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return snippet, token
+	}
+
+	alpha, token := create("alpha()")
+	beta, _ := create("beta()")
+	if err := s.DeleteSnippet(ctx, alpha.ID, SnippetAccess{EditToken: token}); err != nil {
+		t.Fatal(err)
+	}
+	gamma, _ := create("gamma()")
+	if alpha.SearchKey == 0 || beta.SearchKey <= alpha.SearchKey || gamma.SearchKey <= beta.SearchKey {
+		t.Errorf("search keys = %d, %d, %d; want increasing keys", alpha.SearchKey, beta.SearchKey, gamma.SearchKey)
+	}
+
+	for _, term := range []string{"alpha", "beta", "gamma"} {
+		found, _, err := s.ListSnippets(ctx, SnippetQuery{Query: term})
+		want := 1
+		if term == "alpha" {
+			want = 0
+		}
+		if err != nil || len(found) != want {
+			t.Errorf("search %s = %+v, %v; want %d results", term, found, err, want)
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/online-compiler/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// DefaultStatsDays is the number of days of snippet stats returned by
+	// default
+	DefaultStatsDays = 30
+	// MaxStatsDays is the longest period of snippet stats that can be
+	// requested
+	MaxStatsDays = 365
+	// maxTrackedViews bounds the memory used to de-duplicate views; views
+	// beyond it are counted without de-duplication
+	maxTrackedViews = 100_000
+)
+
+// viewBucket identifies a snippet's counts on one UTC day
+type viewBucket struct {
+	snippetID string
+	day       string
+}
+
+// viewCounts are counts waiting to be written. buffered is the part of
+// views not yet added to the snippet's total.
+type viewCounts struct {
+	views, buffered, runs int
+}
+
+// ViewCounter counts snippet views and runs per day. Repeated views by the
+// same visitor within the de-duplication window count once. Counts are
+// buffered in memory and written in one transaction per flush, so reads do
+// not contend for SQLite's write lock.
+type ViewCounter struct {
+	DB *gorm.DB
+
+	window   time.Duration
+	buffered bool
+
+	mu      sync.Mutex
+	seen    map[[sha256.Size]byte]time.Time
+	pending map[viewBucket]viewCounts
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewViewCounter creates a counter that writes every count immediately
+// and does not de-duplicate views
+func NewViewCounter(db *gorm.DB) *ViewCounter {
+	return &ViewCounter{
+		DB:      db,
+		seen:    make(map[[sha256.Size]byte]time.Time),
+		pending: make(map[viewBucket]viewCounts),
+	}
+}
+
+// StartViewCounter creates a counter that de-duplicates views within window
+// and writes counts every interval until closed
+func StartViewCounter(db *gorm.DB, window, interval time.Duration) *ViewCounter {
+	ctx, cancel := context.WithCancel(context.Background())
+	counter := NewViewCounter(db)
+	counter.window, counter.buffered = window, true
+	counter.cancel, counter.done = cancel, make(chan struct{})
+
+	go func() {
+		defer close(counter.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if err := counter.Flush(ctx); err != nil && ctx.Err() == nil {
+				slog.Error("snippet view flush failed", "error", err)
+			}
+		}
+	}()
+
+	return counter
+}
+
+// RecordView counts a view of the snippet unless visitor viewed it within
+// the de-duplication window, and reports whether it was counted. Views
+// without a visitor are always counted.
+func (v *ViewCounter) RecordView(ctx context.Context, snippetID, visitor string) (bool, error) {
+	if visitor != "" && v.window > 0 {
+		key := sha256.Sum256([]byte(snippetID + "\x00" + visitor))
+		now := time.Now()
+
+		v.mu.Lock()
+		if expires, seen := v.seen[key]; seen && now.Before(expires) {
+			v.mu.Unlock()
+			return false, nil
+		}
+		if len(v.seen) >= maxTrackedViews {
+			v.pruneLocked(now)
+		}
+		if len(v.seen) < maxTrackedViews {
+			v.seen[key] = now.Add(v.window)
+		}
+		v.mu.Unlock()
+	}
+	return true, v.record(ctx, snippetID, viewCounts{views: 1, buffered: 1})
+}
+
+// RecordRun counts a run of the snippet
+func (v *ViewCounter) RecordRun(ctx context.Context, snippetID string) error {
+	return v.record(ctx, snippetID, viewCounts{runs: 1})
+}
+
+// logView adds a view that was already added to the snippet's total to its
+// daily counts
+func (v *ViewCounter) logView(ctx context.Context, snippetID string) error {
+	return v.record(ctx, snippetID, viewCounts{views: 1})
+}
+
+// record adds counts for today, writing them at once unless buffered
+func (v *ViewCounter) record(ctx context.Context, snippetID string, counts viewCounts) error {
+	bucket := viewBucket{snippetID: snippetID, day: time.Now().UTC().Format(time.DateOnly)}
+	v.merge(map[viewBucket]viewCounts{bucket: counts})
+	if v.buffered {
+		return nil
+	}
+	return v.Flush(ctx)
+}
+
+// merge adds counts to the pending ones
+func (v *ViewCounter) merge(counts map[viewBucket]viewCounts) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for bucket, add := range counts {
+		pending := v.pending[bucket]
+		pending.views += add.views
+		pending.buffered += add.buffered
+		pending.runs += add.runs
+		v.pending[bucket] = pending
+	}
+}
+
+// pendingViews returns the views of a snippet that are not written yet
+func (v *ViewCounter) pendingViews(snippetID string) int {
+	views := 0
+	for _, counts := range v.pendingCounts(snippetID) {
+		views += counts.buffered
+	}
+	return views
+}
+
+// pendingCounts returns the counts of a snippet that are not written yet,
+// by day
+func (v *ViewCounter) pendingCounts(snippetID string) map[string]viewCounts {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	counts := make(map[string]viewCounts)
+	for bucket, pending := range v.pending {
+		if bucket.snippetID == snippetID {
+			counts[bucket.day] = pending
+		}
+	}
+	return counts
+}
+
+// pruneLocked forgets visitors whose window has passed. v.mu must be held.
+func (v *ViewCounter) pruneLocked(now time.Time) {
+	maps.DeleteFunc(v.seen, func(_ [sha256.Size]byte, expires time.Time) bool {
+		return !now.Before(expires)
+	})
+}
+
+// Flush writes the pending counts in one transaction. Counts of deleted
+// snippets are dropped; counts that fail to write are kept for the next
+// flush.
+func (v *ViewCounter) Flush(ctx context.Context) error {
+	v.mu.Lock()
+	pending := v.pending
+	v.pending = make(map[viewBucket]viewCounts)
+	v.pruneLocked(time.Now())
+	v.mu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	err := v.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		ids := make(map[string]bool)
+		for bucket := range pending {
+			ids[bucket.snippetID] = true
+		}
+		var live []string
+		err := tx.Model(&models.Snippet{}).Where("id IN ?", slices.Collect(maps.Keys(ids))).Pluck("id", &live).Error
+		if err != nil {
+			return err
+		}
+
+		for bucket, counts := range pending {
+			if !slices.Contains(live, bucket.snippetID) {
+				continue
+			}
+			if counts.buffered > 0 {
+				err := tx.Model(&models.Snippet{}).Where("id = ?", bucket.snippetID).
+					UpdateColumn("views", gorm.Expr("views + ?", counts.buffered)).Error
+				if err != nil {
+					return err
+				}
+			}
+			err := tx.Clauses(clause.OnConflict{
+				Columns: []clause.Column{{Name: "snippet_id"}, {Name: "day"}},
+				DoUpdates: clause.Assignments(map[string]interface{}{
+					"views": gorm.Expr("views + ?", counts.views),
+					"runs":  gorm.Expr("runs + ?", counts.runs),
+				}),
+			}).Create(&models.SnippetDailyStats{SnippetID: bucket.snippetID, Day: bucket.day, Views: counts.views, Runs: counts.runs}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		v.merge(pending)
+	}
+	return err
+}
+
+// Close stops periodic flushing and writes the pending counts
+func (v *ViewCounter) Close() {
+	if v.cancel != nil {
+		v.cancel()
+		<-v.done
+	}
+	if err := v.Flush(context.Background()); err != nil {
+		slog.Error("snippet view flush failed", "error", err)
+	}
+}
+
+// RecordRun counts a run of the snippet in its stats
+func (s *SnippetService) RecordRun(ctx context.Context, id string) error {
+	return s.Views.RecordRun(ctx, id)
+}
+
+// SnippetStats returns a snippet's total views and runs and its daily
+// counts over the last days days, oldest first. Only editors may see
+// them.
+func (s *SnippetService) SnippetStats(ctx context.Context, id string, days int, access SnippetAccess) (*models.SnippetStats, error) {
+	if days < 1 || days > MaxStatsDays {
+		return nil, fmt.Errorf("%w: days must be between 1 and %d", ErrInvalidSnippetOptions, MaxStatsDays)
+	}
+	snippet, err := s.authorize(ctx, id, access)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &models.SnippetStats{SnippetID: snippet.ID, Views: snippet.Views, Daily: make([]models.SnippetDailyStats, days)}
+	err = s.DB.WithContext(ctx).Model(&models.SnippetDailyStats{}).Where("snippet_id = ?", snippet.ID).
+		Select("COALESCE(SUM(runs), 0)").Scan(&stats.Runs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// Counts still buffered by the view counter are added without forcing
+	// a flush
+	pending := s.Views.pendingCounts(snippet.ID)
+	for _, counts := range pending {
+		stats.Views += counts.buffered
+		stats.Runs += counts.runs
+	}
+
+	today := time.Now().UTC()
+	first := today.AddDate(0, 0, 1-days).Format(time.DateOnly)
+	var rows []models.SnippetDailyStats
+	err = s.DB.WithContext(ctx).Where("snippet_id = ? AND day >= ?", snippet.ID, first).Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// Days without views or runs are listed with zero counts
+	byDay := make(map[string]models.SnippetDailyStats, len(rows))
+	for _, row := range rows {
+		byDay[row.Day] = row
+	}
+	for i := range stats.Daily {
+		day := today.AddDate(0, 0, i+1-days).Format(time.DateOnly)
+		stats.Daily[i] = models.SnippetDailyStats{
+			Day:   day,
+			Views: byDay[day].Views + pending[day].views,
+			Runs:  byDay[day].Runs + pending[day].runs,
+		}
+	}
+	return stats, nil
+}
@@ -0,0 +1,524 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/online-compiler/backend/configs"
+	"github.com/online-compiler/backend/internal/logging"
+	"github.com/online-compiler/backend/internal/metrics"
+	"github.com/online-compiler/backend/internal/models"
+	"github.com/online-compiler/backend/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// streamGroup is the consumer group shared by all workers
+	streamGroup = "executors"
+
+	// streamBlock bounds blocking Redis reads so cancellation is noticed
+	streamBlock = time.Second
+
+	// streamHeartbeat is the longest interval between visibility refreshes
+	streamHeartbeat = time.Second
+
+	// streamRetryAfter is suggested to clients when the stream is full
+	streamRetryAfter = 5 * time.Second
+)
+
+// StreamJob is an execution request passed from the API to the workers
+type StreamJob struct {
+	ID         string `json:"id"`
+	LanguageID int    `json:"language_id"`
+	Code       string `json:"code"`
+	// Files holds the files of multi-file projects, the entry point first;
+	// Code is empty when they are set
+	Files      []models.SnippetFile `json:"files,omitempty"`
+	Stdin      string               `json:"stdin"`
+	Args       []string             `json:"args,omitempty"`
+	Priority   string               `json:"priority"`
+	RequestID  string               `json:"request_id,omitempty"`
+	EnqueuedAt time.Time            `json:"enqueued_at"`
+}
+
+// streamResult is the stored outcome of a job
+type streamResult struct {
+	Response *models.ExecuteResponse `json:"response,omitempty"`
+	Error    string                  `json:"error,omitempty"`
+}
+
+// StreamQueue hands executions to worker processes through Redis Streams.
+// Each priority has its own stream read by the "executors" consumer group;
+// results are stored under the job ID and announced on a per-job list.
+type StreamQueue struct {
+	Client *redis.Client
+	Stream string
+
+	// VisibilityTimeout is how long a job may go without a heartbeat before
+	// another worker reclaims it
+	VisibilityTimeout time.Duration
+	// MaxDeliveries is how often a job is attempted before it is moved to
+	// the dead-letter stream
+	MaxDeliveries int
+	// ResultTTL is how long results and cancellations are kept
+	ResultTTL time.Duration
+	// MaxBacklog rejects new jobs once this many are outstanding; zero
+	// disables the limit
+	MaxBacklog int
+}
+
+// NewStreamQueue creates a queue on client using the queue settings in cfg
+func NewStreamQueue(client *redis.Client, cfg *configs.Config) *StreamQueue {
+	return &StreamQueue{
+		Client:            client,
+		Stream:            cfg.QueueStream,
+		VisibilityTimeout: time.Duration(cfg.QueueVisibility) * time.Second,
+		MaxDeliveries:     cfg.QueueMaxDeliver,
+		ResultTTL:         time.Duration(cfg.QueueResultTTL) * time.Second,
+		MaxBacklog:        cfg.QueueDepth,
+	}
+}
+
+func (q *StreamQueue) streamKey(priority Priority) string {
+	return q.Stream + ":" + priority.String()
+}
+
+// DeadLetterKey is the stream holding jobs that could not be completed
+func (q *StreamQueue) DeadLetterKey() string {
+	return q.Stream + ":dead"
+}
+
+func (q *StreamQueue) resultKey(jobID string) string {
+	return q.Stream + ":result:" + jobID
+}
+
+func (q *StreamQueue) notifyKey(jobID string) string {
+	return q.Stream + ":notify:" + jobID
+}
+
+func (q *StreamQueue) cancelKey(jobID string) string {
+	return q.Stream + ":cancel:" + jobID
+}
+
+// EnsureGroups creates the streams and consumer group if they do not exist
+func (q *StreamQueue) EnsureGroups(ctx context.Context) error {
+	for priority := range priorityNames {
+		err := q.Client.XGroupCreateMkStream(ctx, q.streamKey(Priority(priority)), streamGroup, "0").Err()
+		if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+			return fmt.Errorf("failed to create consumer group: %v", err)
+		}
+	}
+	return nil
+}
+
+// Submit adds a job to the stream for its priority. A full backlog returns
+// a *QueueFullError.
+func (q *StreamQueue) Submit(ctx context.Context, job *StreamJob) error {
+	priority, err := ParsePriority(job.Priority, PriorityInteractive)
+	if err != nil {
+		return err
+	}
+
+	// Acknowledged jobs are deleted, so the stream length is the backlog
+	if q.MaxBacklog > 0 {
+		backlog := int64(0)
+		for p := range priorityNames {
+			length, err := q.Client.XLen(ctx, q.streamKey(Priority(p))).Result()
+			if err != nil {
+				return err
+			}
+			backlog += length
+		}
+		if backlog >= int64(q.MaxBacklog) {
+			metrics.QueueRejections.WithLabelValues("redis").Inc()
+			return &QueueFullError{Backend: "redis", RetryAfter: streamRetryAfter}
+		}
+	}
+
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return q.Client.XAdd(ctx, &redis.XAddArgs{
+		Stream: q.streamKey(priority),
+		Values: map[string]interface{}{"job": data},
+	}).Err()
+}
+
+// Wait blocks until a worker stores the job's result or ctx is done
+func (q *StreamQueue) Wait(ctx context.Context, jobID string) (*models.ExecuteResponse, error) {
+	for {
+		err := q.Client.BLPop(ctx, streamBlock, q.notifyKey(jobID)).Err()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err == nil {
+			break
+		}
+		if err != redis.Nil {
+			return nil, err
+		}
+	}
+
+	data, err := q.Client.Get(ctx, q.resultKey(jobID)).Bytes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read job result: %v", err)
+	}
+	var result streamResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	// Errors lose their identity on the way; restore the ones callers check
+	if result.Error == ErrProjectUnsupported.Error() {
+		return nil, ErrProjectUnsupported
+	}
+	if result.Error != "" {
+		return nil, errors.New(result.Error)
+	}
+	return result.Response, nil
+}
+
+// Cancel asks the worker running a job to stop it
+func (q *StreamQueue) Cancel(ctx context.Context, jobID string) error {
+	return q.Client.Set(ctx, q.cancelKey(jobID), 1, q.ResultTTL).Err()
+}
+
+// ExecuteCode submits the execution at the context's priority and waits for
+// a worker to run it. Cancelling ctx cancels the remote execution.
+func (q *StreamQueue) ExecuteCode(ctx context.Context, languageID int, code, stdin string) (*models.ExecuteResponse, error) {
+	return q.execute(ctx, &StreamJob{LanguageID: languageID, Code: code, Stdin: stdin})
+}
+
+// ExecuteProject submits a multi-file program like ExecuteCode. Whether the
+// language's backend can run it is decided by the worker.
+func (q *StreamQueue) ExecuteProject(ctx context.Context, languageID int, files []models.SnippetFile, stdin string) (*models.ExecuteResponse, error) {
+	return q.execute(ctx, &StreamJob{LanguageID: languageID, Files: files, Stdin: stdin})
+}
+
+// execute fills in the job's ID and request context, submits it and waits
+// for its result
+func (q *StreamQueue) execute(ctx context.Context, job *StreamJob) (*models.ExecuteResponse, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "stream.execute")
+	span.SetAttributes(attribute.Int("language_id", job.LanguageID))
+	defer span.End()
+
+	job.ID = uuid.New().String()
+	job.Args = ArgsFrom(ctx)
+	job.Priority = PriorityFrom(ctx).String()
+	job.RequestID = logging.RequestID(ctx)
+	job.EnqueuedAt = time.Now()
+	span.SetAttributes(attribute.String("stream.job_id", job.ID))
+
+	if err := q.Submit(ctx, job); err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+
+	response, err := q.Wait(ctx, job.ID)
+	if ctx.Err() != nil {
+		if err := q.Cancel(context.WithoutCancel(ctx), job.ID); err != nil {
+			slog.WarnContext(ctx, "failed to cancel queued job", "job_id", job.ID, "error", err)
+		}
+		return cancelledResponse(), nil
+	}
+	if err != nil {
+		tracing.RecordError(span, err)
+	}
+	return response, err
+}
+
+// StreamWorker executes jobs from a StreamQueue with local executors
+type StreamWorker struct {
+	Queue     *StreamQueue
+	Executors *ExecutorRegistry
+	Consumer  string
+}
+
+// NewStreamWorker creates a worker; consumer names it within the group and
+// must be unique per process
+func NewStreamWorker(queue *StreamQueue, executors *ExecutorRegistry, consumer string) *StreamWorker {
+	return &StreamWorker{Queue: queue, Executors: executors, Consumer: consumer}
+}
+
+// Run consumes jobs on concurrency goroutines until ctx is done, then waits
+// for the jobs in progress to finish
+func (w *StreamWorker) Run(ctx context.Context, concurrency int) error {
+	if err := w.Queue.EnsureGroups(ctx); err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	for i := range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.consume(ctx, fmt.Sprintf("%s-%d", w.Consumer, i))
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+// consume handles one job at a time until ctx is done
+func (w *StreamWorker) consume(ctx context.Context, consumer string) {
+	for ctx.Err() == nil {
+		stream, message, err := w.next(ctx, consumer)
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.Warn("failed to read job queue", "consumer", consumer, "error", err)
+				select {
+				case <-ctx.Done():
+				case <-time.After(streamBlock):
+				}
+			}
+			continue
+		}
+		if message != nil {
+			w.handle(context.WithoutCancel(ctx), consumer, stream, *message)
+		}
+	}
+}
+
+// next returns a job abandoned past the visibility timeout, or else a new
+// job. Interactive jobs are taken before batch jobs, and the blocking read
+// waits on the interactive stream so idle workers pick those up first.
+func (w *StreamWorker) next(ctx context.Context, consumer string) (string, *redis.XMessage, error) {
+	q := w.Queue
+	interactive, batch := q.streamKey(PriorityInteractive), q.streamKey(PriorityBatch)
+
+	for _, stream := range []string{interactive, batch} {
+		message, err := w.reclaim(ctx, consumer, stream)
+		if err != nil || message != nil {
+			return stream, message, err
+		}
+	}
+
+	reads := []struct {
+		stream string
+		block  time.Duration
+	}{
+		{interactive, -1},
+		{batch, -1},
+		{interactive, streamBlock},
+	}
+	for _, read := range reads {
+		streams, err := q.Client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    streamGroup,
+			Consumer: consumer,
+			Streams:  []string{read.stream, ">"},
+			Count:    1,
+			Block:    read.block,
+		}).Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		if len(streams) > 0 && len(streams[0].Messages) > 0 {
+			return read.stream, &streams[0].Messages[0], nil
+		}
+	}
+	return "", nil, nil
+}
+
+// reclaim takes over the oldest job in stream whose consumer has not sent a
+// heartbeat within the visibility timeout. XPENDING and XCLAIM are used
+// rather than XAUTOCLAIM, whose Redis 7 reply go-redis v8 cannot parse.
+func (w *StreamWorker) reclaim(ctx context.Context, consumer, stream string) (*redis.XMessage, error) {
+	q := w.Queue
+	pending, err := q.Client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: stream,
+		Group:  streamGroup,
+		Idle:   q.VisibilityTimeout,
+		Start:  "-",
+		End:    "+",
+		Count:  1,
+	}).Result()
+	if err != nil || len(pending) == 0 {
+		return nil, err
+	}
+
+	// The idle check is repeated so only one worker wins the claim
+	messages, err := q.Client.XClaim(ctx, &redis.XClaimArgs{
+		Stream:   stream,
+		Group:    streamGroup,
+		Consumer: consumer,
+		MinIdle:  q.VisibilityTimeout,
+		Messages: []string{pending[0].ID},
+	}).Result()
+	if err != nil || len(messages) == 0 {
+		return nil, err
+	}
+
+	metrics.StreamJobs.WithLabelValues("reclaimed").Inc()
+	slog.Warn("reclaimed job after visibility timeout", "stream", stream, "message_id", messages[0].ID,
+		"previous_consumer", pending[0].Consumer)
+	return &messages[0], nil
+}
+
+// handle runs one job and stores its result. Jobs delivered more than
+// MaxDeliveries times, or that cannot be decoded, are dead-lettered.
+func (w *StreamWorker) handle(ctx context.Context, consumer, stream string, message redis.XMessage) {
+	q := w.Queue
+
+	pending, err := q.Client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: stream,
+		Group:  streamGroup,
+		Start:  message.ID,
+		End:    message.ID,
+		Count:  1,
+	}).Result()
+	if err == nil && len(pending) == 1 && pending[0].RetryCount > int64(q.MaxDeliveries) {
+		w.deadLetter(ctx, stream, message, fmt.Sprintf("not completed after %d deliveries", q.MaxDeliveries))
+		return
+	}
+
+	var job StreamJob
+	data, _ := message.Values["job"].(string)
+	if err := json.Unmarshal([]byte(data), &job); err != nil || job.ID == "" {
+		w.deadLetter(ctx, stream, message, "malformed job")
+		return
+	}
+
+	priority, _ := ParsePriority(job.Priority, PriorityInteractive)
+	runCtx, cancel := context.WithCancel(WithPriority(logging.WithRequestID(ctx, job.RequestID), priority))
+	defer cancel()
+
+	stop := w.heartbeat(ctx, cancel, consumer, stream, message.ID, job.ID)
+	result := streamResult{}
+	result.Response, err = w.execute(runCtx, &job)
+	stop()
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	outcome := "completed"
+	if runCtx.Err() != nil {
+		outcome = "cancelled"
+	}
+	metrics.StreamJobs.WithLabelValues(outcome).Inc()
+	w.finish(ctx, stream, message.ID, job.ID, result)
+}
+
+// execute runs a job with the executor configured for its language, as a
+// project if it has files. Jobs cancelled before they start are not run.
+func (w *StreamWorker) execute(ctx context.Context, job *StreamJob) (*models.ExecuteResponse, error) {
+	if cancelled, _ := w.Queue.Client.Exists(ctx, w.Queue.cancelKey(job.ID)).Result(); cancelled > 0 {
+		return cancelledResponse(), nil
+	}
+
+	executor, backend, err := w.Executors.ExecutorFor(job.LanguageID)
+	if err != nil {
+		return nil, err
+	}
+	slog.InfoContext(ctx, "running queued job", "job_id", job.ID, "backend", backend, "language_id", job.LanguageID,
+		"queued_for", time.Since(job.EnqueuedAt))
+	ctx = WithArgs(ctx, job.Args)
+	if len(job.Files) > 0 {
+		return ExecuteProject(ctx, executor, job.LanguageID, job.Files, job.Stdin)
+	}
+	return executor.ExecuteCode(ctx, job.LanguageID, job.Code, job.Stdin)
+}
+
+// heartbeat keeps a running job's idle time below the visibility timeout
+// and calls cancel when the submitter gives up. The returned function stops
+// it.
+func (w *StreamWorker) heartbeat(ctx context.Context, cancel context.CancelFunc, consumer, stream, messageID, jobID string) func() {
+	q := w.Queue
+	interval := min(streamHeartbeat, q.VisibilityTimeout/3)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			// Claiming the message again resets its idle time
+			q.Client.XClaimJustID(ctx, &redis.XClaimArgs{
+				Stream:   stream,
+				Group:    streamGroup,
+				Consumer: consumer,
+				Messages: []string{messageID},
+			})
+			if cancelled, _ := q.Client.Exists(ctx, q.cancelKey(jobID)).Result(); cancelled > 0 {
+				cancel()
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// finish stores the result, wakes the submitter and removes the job from the
+// stream. jobID may be empty for jobs that could not be decoded.
+func (w *StreamWorker) finish(ctx context.Context, stream, messageID, jobID string, result streamResult) {
+	q := w.Queue
+	data, err := json.Marshal(result)
+	if err != nil {
+		slog.Error("failed to encode job result", "job_id", jobID, "error", err)
+		return
+	}
+
+	_, err = q.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if jobID != "" {
+			pipe.Set(ctx, q.resultKey(jobID), data, q.ResultTTL)
+			pipe.RPush(ctx, q.notifyKey(jobID), 1)
+			pipe.Expire(ctx, q.notifyKey(jobID), q.ResultTTL)
+		}
+		pipe.XAck(ctx, stream, streamGroup, messageID)
+		pipe.XDel(ctx, stream, messageID)
+		return nil
+	})
+	if err != nil {
+		slog.Error("failed to store job result", "job_id", jobID, "error", err)
+	}
+}
+
+// deadLetter moves a job to the dead-letter stream and reports the failure
+// to its submitter
+func (w *StreamWorker) deadLetter(ctx context.Context, stream string, message redis.XMessage, reason string) {
+	q := w.Queue
+	metrics.StreamJobs.WithLabelValues("dead_lettered").Inc()
+	slog.Error("moving job to dead-letter stream", "stream", stream, "message_id", message.ID, "reason", reason)
+
+	err := q.Client.XAdd(ctx, &redis.XAddArgs{
+		Stream: q.DeadLetterKey(),
+		Values: map[string]interface{}{
+			"job":        message.Values["job"],
+			"stream":     stream,
+			"message_id": message.ID,
+			"reason":     reason,
+			"failed_at":  time.Now().UTC().Format(time.RFC3339),
+		},
+	}).Err()
+	if err != nil {
+		// Leave the job pending so it is retried rather than lost
+		slog.Error("failed to dead-letter job", "message_id", message.ID, "error", err)
+		return
+	}
+
+	var job StreamJob
+	data, _ := message.Values["job"].(string)
+	json.Unmarshal([]byte(data), &job)
+	w.finish(ctx, stream, message.ID, job.ID, streamResult{Error: "execution failed: " + reason})
+}
@@ -0,0 +1,219 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/online-compiler/backend/configs"
+	"github.com/online-compiler/backend/internal/models"
+)
+
+// newTestStreamQueue returns a queue on an in-memory Redis with short
+// timeouts
+func newTestStreamQueue(t *testing.T) *StreamQueue {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	return &StreamQueue{
+		Client:            client,
+		Stream:            "test:jobs",
+		VisibilityTimeout: 50 * time.Millisecond,
+		MaxDeliveries:     3,
+		ResultTTL:         time.Minute,
+	}
+}
+
+// startStreamWorker runs a worker with executor behind every language until
+// the test ends
+func startStreamWorker(t *testing.T, queue *StreamQueue, executor Executor) {
+	t.Helper()
+	executors := NewExecutorRegistry(staticLanguages(configs.LanguageConfig{}))
+	executors.Register("wasm", executor)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := NewStreamWorker(queue, executors, "test").Run(ctx, 1); err != nil {
+			t.Error(err)
+		}
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+// submitUnacked queues a job and delivers it to a consumer that never
+// acknowledges it, as if its worker crashed
+func submitUnacked(t *testing.T, queue *StreamQueue, job *StreamJob) {
+	t.Helper()
+	ctx := context.Background()
+	if err := queue.EnsureGroups(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := queue.Submit(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	err := queue.Client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    streamGroup,
+		Consumer: "crashed",
+		Streams:  []string{queue.streamKey(PriorityInteractive), ">"},
+		Count:    1,
+		Block:    -1,
+	}).Err()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStreamQueueExecute(t *testing.T) {
+	queue := newTestStreamQueue(t)
+	startStreamWorker(t, queue, &countingExecutor{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	response, err := queue.ExecuteCode(WithPriority(ctx, PriorityBatch), 71, "print(input())", "remote")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Output != "out:remote" {
+		t.Errorf("response = %+v", response)
+	}
+
+	// Acknowledged jobs are removed from the stream
+	if length := queue.Client.XLen(ctx, queue.streamKey(PriorityBatch)).Val(); length != 0 {
+		t.Errorf("stream length = %d, want 0", length)
+	}
+}
+
+// projectExecutor runs projects by listing their files
+type projectExecutor struct {
+	countingExecutor
+}
+
+func (e *projectExecutor) ExecuteProject(ctx context.Context, languageID int, files []models.SnippetFile, stdin string) (*models.ExecuteResponse, error) {
+	names := make([]string, len(files))
+	for i, file := range files {
+		names[i] = file.Name
+	}
+	return &models.ExecuteResponse{Success: true, Output: strings.Join(names, ",") + ":" + stdin, Status: "Completed"}, nil
+}
+
+func TestStreamQueueExecuteProject(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	files := []models.SnippetFile{{Name: "main.py", Content: "import util"}, {Name: "util.py", Content: "X = 1"}}
+
+	queue := newTestStreamQueue(t)
+	startStreamWorker(t, queue, &projectExecutor{})
+	response, err := queue.ExecuteProject(ctx, 71, files, "in")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Output != "main.py,util.py:in" {
+		t.Errorf("response = %+v", response)
+	}
+
+	// Workers whose backend only runs single files reject projects
+	queue = newTestStreamQueue(t)
+	startStreamWorker(t, queue, &countingExecutor{})
+	if _, err := queue.ExecuteProject(ctx, 71, files, ""); !errors.Is(err, ErrProjectUnsupported) {
+		t.Errorf("err = %v, want %v", err, ErrProjectUnsupported)
+	}
+}
+
+func TestStreamQueueBacklogFull(t *testing.T) {
+	queue := newTestStreamQueue(t)
+	queue.MaxBacklog = 1
+
+	ctx := context.Background()
+	if err := queue.Submit(ctx, &StreamJob{ID: "a", Priority: "batch"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := queue.Submit(ctx, &StreamJob{ID: "b", Priority: "interactive"}); err == nil || !strings.Contains(err.Error(), "queue is full") {
+		t.Errorf("err = %v, want queue full", err)
+	}
+}
+
+func TestStreamWorkerReclaimsAbandonedJob(t *testing.T) {
+	queue := newTestStreamQueue(t)
+	submitUnacked(t, queue, &StreamJob{ID: "job-1", LanguageID: 71, Stdin: "again"})
+	startStreamWorker(t, queue, &countingExecutor{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	response, err := queue.Wait(ctx, "job-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Output != "out:again" {
+		t.Errorf("response = %+v", response)
+	}
+}
+
+func TestStreamWorkerDeadLetters(t *testing.T) {
+	queue := newTestStreamQueue(t)
+	queue.MaxDeliveries = 1
+	submitUnacked(t, queue, &StreamJob{ID: "job-1", LanguageID: 71})
+	executor := &countingExecutor{}
+	startStreamWorker(t, queue, executor)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := queue.Wait(ctx, "job-1"); err == nil || !strings.Contains(err.Error(), "not completed after 1 deliveries") {
+		t.Errorf("err = %v, want dead-letter failure", err)
+	}
+
+	dead := queue.Client.XRange(ctx, queue.DeadLetterKey(), "-", "+").Val()
+	if len(dead) != 1 || dead[0].Values["message_id"] == "" {
+		t.Errorf("dead letters = %+v", dead)
+	}
+	if executor.calls != 0 {
+		t.Errorf("dead-lettered job ran %d times", executor.calls)
+	}
+}
+
+// blockingExecutor runs until its context is cancelled
+type blockingExecutor struct {
+	started   chan struct{}
+	cancelled chan struct{}
+}
+
+func (e *blockingExecutor) ExecuteCode(ctx context.Context, languageID int, code, stdin string) (*models.ExecuteResponse, error) {
+	close(e.started)
+	<-ctx.Done()
+	close(e.cancelled)
+	return cancelledResponse(), nil
+}
+
+func TestStreamQueueCancel(t *testing.T) {
+	queue := newTestStreamQueue(t)
+	executor := &blockingExecutor{started: make(chan struct{}), cancelled: make(chan struct{})}
+	startStreamWorker(t, queue, executor)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-executor.started
+		cancel()
+	}()
+
+	response, err := queue.ExecuteCode(ctx, 71, "while True: pass", "")
+	if err != nil || response.Status != "Cancelled" {
+		t.Errorf("response = %+v, err = %v, want cancelled", response, err)
+	}
+
+	select {
+	case <-executor.cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("worker did not cancel the running job")
+	}
+}
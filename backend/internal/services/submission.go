@@ -0,0 +1,309 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/online-compiler/backend/internal/models"
+)
+
+// Submission statuses
+const (
+	SubmissionQueued    = "queued"
+	SubmissionRunning   = "running"
+	SubmissionCompleted = "completed"
+	SubmissionCancelled = "cancelled"
+)
+
+// submissionRetention is how long finished submissions stay queryable
+const submissionRetention = time.Hour
+
+var (
+	// ErrSubmissionNotFound is returned for unknown or expired submission IDs
+	ErrSubmissionNotFound = errors.New("submission not found")
+	// ErrSubmissionFinished is returned when cancelling a finished submission
+	ErrSubmissionFinished = errors.New("submission already finished")
+	// ErrSubmissionForbidden is returned when cancelling a submission
+	// started by another caller
+	ErrSubmissionForbidden = errors.New("submission was started by another caller")
+	// ErrExecutionInUse is returned when a caller's running execution
+	// already uses the request ID
+	ErrExecutionInUse = errors.New("request ID is already used by a running execution")
+)
+
+// submissionEntry is a tracked execution and the function that aborts it
+type submissionEntry struct {
+	submission models.Submission
+	cancel     context.CancelFunc
+
+	// caller identifies who started the execution; only they may cancel it
+	caller string
+
+	// queued is set while the execution waits in a worker pool
+	queued *QueuedExecution
+}
+
+// executionKey identifies a synchronous execution. Request IDs are chosen
+// by clients, so they are only unique per caller.
+type executionKey struct {
+	caller    string
+	requestID string
+}
+
+// SubmissionManager tracks running executions so they can be queried,
+// cancelled and drained on shutdown
+type SubmissionManager struct {
+	// Notifier, if set, is told about finished submissions
+	Notifier SubmissionNotifier
+
+	mu          sync.Mutex
+	submissions map[string]*submissionEntry
+	executions  map[executionKey]*submissionEntry
+
+	// wg tracks running asynchronous submissions for draining
+	wg sync.WaitGroup
+}
+
+// NewSubmissionManager creates an empty submission manager
+func NewSubmissionManager() *SubmissionManager {
+	return &SubmissionManager{
+		submissions: make(map[string]*submissionEntry),
+		executions:  make(map[executionKey]*submissionEntry),
+	}
+}
+
+// TrackExecution registers a synchronous execution under caller's request
+// ID so that caller can cancel it through CancelSubmission. A request ID
+// the caller is still running returns ErrExecutionInUse. The returned
+// function must be called once the execution finishes.
+func (m *SubmissionManager) TrackExecution(ctx context.Context, caller, requestID string, languageID int) (context.Context, func(), error) {
+	key := executionKey{caller: caller, requestID: requestID}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.executions[key]; exists {
+		return nil, nil, ErrExecutionInUse
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	m.executions[key] = &submissionEntry{
+		submission: models.Submission{
+			ID:         requestID,
+			Status:     SubmissionRunning,
+			LanguageID: languageID,
+			CreatedAt:  time.Now(),
+		},
+		cancel: cancel,
+		caller: caller,
+	}
+
+	return ctx, func() {
+		cancel()
+		m.mu.Lock()
+		delete(m.executions, key)
+		m.mu.Unlock()
+	}, nil
+}
+
+// CreateSubmission starts an asynchronous execution for caller and returns
+// immediately. The execution keeps the request's values (request ID, trace)
+// but not its cancellation, so it outlives the HTTP request that created
+// it. Executors backed by a worker pool are queued at priority, and a full
+// queue is reported as an error instead of creating the submission.
+func (m *SubmissionManager) CreateSubmission(ctx context.Context, executor Executor, req *models.ExecuteRequest, priority Priority, caller string) (models.Submission, error) {
+	ctx, cancel := context.WithCancel(WithPriority(context.WithoutCancel(ctx), priority))
+	entry := &submissionEntry{
+		submission: models.Submission{
+			ID:          uuid.New().String(),
+			Status:      SubmissionRunning,
+			LanguageID:  req.LanguageID,
+			Priority:    priority.String(),
+			CallbackURL: req.CallbackURL,
+			CreatedAt:   time.Now(),
+		},
+		cancel: cancel,
+		caller: caller,
+	}
+
+	if pooled, ok := executor.(*PooledExecutor); ok {
+		queued, err := pooled.Enqueue(ctx, priority, req.LanguageID, req.Code, req.Stdin)
+		if err != nil {
+			cancel()
+			return models.Submission{}, err
+		}
+		entry.queued = queued
+		entry.submission.Status = SubmissionQueued
+		entry.submission.QueuePosition = queued.Position()
+	}
+
+	m.mu.Lock()
+	m.purgeSubmissions()
+	m.submissions[entry.submission.ID] = entry
+	snapshot := entry.submission
+	m.mu.Unlock()
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer cancel()
+
+		var result *models.ExecuteResponse
+		var err error
+		if entry.queued != nil {
+			m.markStarted(ctx, entry)
+			result, err = entry.queued.Result(ctx)
+		} else {
+			result, err = executor.ExecuteCode(ctx, req.LanguageID, req.Code, req.Stdin)
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "async submission failed", "submission_id", snapshot.ID, "error", err)
+			result = &models.ExecuteResponse{
+				Success: false,
+				Error:   err.Error(),
+			}
+		}
+
+		m.mu.Lock()
+		now := time.Now()
+		entry.submission.FinishedAt = &now
+		entry.submission.Result = result
+		entry.submission.QueuePosition = 0
+		entry.queued = nil
+		if entry.submission.Status != SubmissionCancelled {
+			entry.submission.Status = SubmissionCompleted
+		}
+		final := entry.submission
+		m.mu.Unlock()
+
+		if m.Notifier != nil {
+			m.Notifier.SubmissionFinished(final)
+		}
+	}()
+
+	return snapshot, nil
+}
+
+// markStarted waits for a queued submission to reach a worker and marks it
+// running
+func (m *SubmissionManager) markStarted(ctx context.Context, entry *submissionEntry) {
+	select {
+	case <-entry.queued.Started():
+	case <-entry.queued.Done():
+		return
+	case <-ctx.Done():
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if entry.submission.Status == SubmissionQueued {
+		entry.submission.Status = SubmissionRunning
+	}
+}
+
+// GetSubmission returns a snapshot of a tracked submission
+func (m *SubmissionManager) GetSubmission(id string) (*models.Submission, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, exists := m.submissions[id]
+	if !exists {
+		return nil, ErrSubmissionNotFound
+	}
+
+	snapshot := entry.submission
+	if entry.queued != nil && snapshot.Status == SubmissionQueued {
+		snapshot.QueuePosition = entry.queued.Position()
+	} else {
+		snapshot.QueuePosition = 0
+	}
+	return &snapshot, nil
+}
+
+// CancelSubmission aborts a running submission, or synchronous execution
+// by request ID, started by caller
+func (m *SubmissionManager) CancelSubmission(id, caller string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, exists := m.submissions[id]
+	if !exists {
+		if entry, exists = m.executions[executionKey{caller: caller, requestID: id}]; !exists {
+			return ErrSubmissionNotFound
+		}
+	}
+	if entry.caller != caller {
+		return ErrSubmissionForbidden
+	}
+	if entry.submission.Status != SubmissionRunning && entry.submission.Status != SubmissionQueued {
+		return ErrSubmissionFinished
+	}
+
+	entry.submission.Status = SubmissionCancelled
+	entry.cancel()
+	return nil
+}
+
+// WaitForSubmissions blocks until all asynchronous submissions finish or ctx
+// is done
+func (m *SubmissionManager) WaitForSubmissions(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// CancelAllSubmissions aborts every running execution
+func (m *SubmissionManager) CancelAllSubmissions() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cancelled := 0
+	for _, entry := range m.submissions {
+		if entry.submission.Status == SubmissionRunning || entry.submission.Status == SubmissionQueued {
+			entry.submission.Status = SubmissionCancelled
+			entry.cancel()
+			cancelled++
+		}
+	}
+	for _, entry := range m.executions {
+		if entry.submission.Status == SubmissionRunning {
+			entry.submission.Status = SubmissionCancelled
+			entry.cancel()
+			cancelled++
+		}
+	}
+	return cancelled
+}
+
+// purgeSubmissions drops finished submissions past the retention window.
+// Callers must hold m.mu.
+func (m *SubmissionManager) purgeSubmissions() {
+	cutoff := time.Now().Add(-submissionRetention)
+	for id, entry := range m.submissions {
+		if entry.submission.FinishedAt != nil && entry.submission.FinishedAt.Before(cutoff) {
+			delete(m.submissions, id)
+		}
+	}
+}
+
+// cancelledResponse is returned by executors when the context is cancelled
+func cancelledResponse() *models.ExecuteResponse {
+	return &models.ExecuteResponse{
+		Success: false,
+		Error:   "Execution cancelled",
+		Status:  "Cancelled",
+	}
+}
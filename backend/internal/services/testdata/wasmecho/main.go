@@ -0,0 +1,44 @@
+// Command wasmecho is a WASI test program for the wasm backend. It runs the
+// source file passed as its last argument as a tiny script:
+//
+//	loop     spins forever
+//	exit N   exits with status N
+//	alloc    allocates until the memory cap is hit
+//
+// Anything else is echoed to stdout followed by stdin.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+func main() {
+	source, err := os.ReadFile(os.Args[len(os.Args)-1])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	script := strings.TrimSpace(string(source))
+	switch {
+	case script == "loop":
+		for {
+		}
+	case strings.HasPrefix(script, "exit "):
+		var code int
+		fmt.Sscanf(script, "exit %d", &code)
+		fmt.Fprintln(os.Stderr, "exiting")
+		os.Exit(code)
+	case script == "alloc":
+		var chunks [][]byte
+		for {
+			chunks = append(chunks, make([]byte, 16<<20))
+		}
+	}
+
+	stdin, _ := io.ReadAll(os.Stdin)
+	fmt.Printf("%s|%s|%s", strings.Join(os.Args[1:len(os.Args)-1], ","), script, stdin)
+}
@@ -0,0 +1,217 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing/fstest"
+	"time"
+
+	"github.com/online-compiler/backend/internal/models"
+	"github.com/online-compiler/backend/internal/tracing"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Defaults for languages without explicit limits
+const (
+	wasmDefaultTimeLimit = 5 * time.Second
+	wasmDefaultMemoryKB  = 256 * 1024
+	wasmMaxOutputBytes   = 1 << 20
+
+	// wasmPageKB is the size of a WebAssembly memory page
+	wasmPageKB = 64
+
+	// wasmSandboxDir is where the submitted source file is mounted
+	wasmSandboxDir = "/sandbox"
+)
+
+// WasmService runs WASI modules in-process with wazero. Each execution gets
+// a fresh runtime with its own memory cap and wall-clock limit; compiled
+// modules are shared through a compilation cache. wazero has no fuel or
+// instruction metering, so CPU use is only bounded by the time limit.
+type WasmService struct {
+	Languages LanguageSettings
+
+	cache   wazero.CompilationCache
+	mu      sync.Mutex
+	modules map[string][]byte
+}
+
+// NewWasmService creates a WebAssembly executor
+func NewWasmService(languages LanguageSettings) *WasmService {
+	return &WasmService{
+		Languages: languages,
+		cache:     wazero.NewCompilationCache(),
+		modules:   make(map[string][]byte),
+	}
+}
+
+// ExecuteCode runs code with the WASI module configured for the language.
+// The source is mounted read-only at /sandbox and passed as the last
+// argument, stdin and stdout are in memory.
+func (w *WasmService) ExecuteCode(ctx context.Context, languageID int, code, stdin string) (*models.ExecuteResponse, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "wasm.execute")
+	span.SetAttributes(attribute.Int("language_id", languageID))
+	defer span.End()
+
+	langConfig := w.Languages.Language(languageID)
+	if langConfig.WasmModule == "" {
+		return &models.ExecuteResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Language ID %d has no WebAssembly module configured", languageID),
+		}, nil
+	}
+
+	module, err := w.loadModule(langConfig.WasmModule)
+	if err != nil {
+		tracing.RecordError(span, err)
+		slog.ErrorContext(ctx, "failed to load wasm module", "module", langConfig.WasmModule, "error", err)
+		return nil, err
+	}
+
+	timeLimit := wasmDefaultTimeLimit
+	if langConfig.TimeLimit > 0 {
+		timeLimit = time.Duration(langConfig.TimeLimit * float64(time.Second))
+	}
+	memoryKB := wasmDefaultMemoryKB
+	if langConfig.MemoryLimitKB > 0 {
+		memoryKB = langConfig.MemoryLimitKB
+	}
+
+	runtime := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithCompilationCache(w.cache).
+		WithMemoryLimitPages(uint32(max(memoryKB/wasmPageKB, 1))).
+		WithCloseOnContextDone(true))
+	defer runtime.Close(context.Background())
+
+	wasi_snapshot_preview1.MustInstantiate(ctx, runtime)
+
+	compiled, err := runtime.CompileModule(ctx, module)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return nil, fmt.Errorf("failed to compile wasm module %s: %v", langConfig.WasmModule, err)
+	}
+
+	// Mount the source and any configured host directories read-only
+	fileName := SourceFileName(languageID)
+	fsConfig := wazero.NewFSConfig().WithFSMount(fstest.MapFS{
+		fileName: &fstest.MapFile{Data: []byte(code), Mode: 0o444},
+	}, wasmSandboxDir)
+	for guestPath, hostDir := range langConfig.WasmMounts {
+		fsConfig = fsConfig.WithFSMount(os.DirFS(hostDir), guestPath)
+	}
+
+	args := append([]string{filepath.Base(langConfig.WasmModule)}, langConfig.Args...)
+	args = append(args, wasmSandboxDir+"/"+fileName)
+	args = append(args, ArgsFrom(ctx)...)
+
+	stdout := &limitedBuffer{limit: wasmMaxOutputBytes}
+	stderr := &limitedBuffer{limit: wasmMaxOutputBytes}
+	moduleConfig := wazero.NewModuleConfig().
+		WithName("").
+		WithArgs(args...).
+		WithStdin(strings.NewReader(stdin)).
+		WithStdout(stdout).
+		WithStderr(stderr).
+		WithFSConfig(fsConfig).
+		WithSysWalltime().
+		WithSysNanotime().
+		WithSysNanosleep()
+
+	// Only the program's run counts against its time limit, not compiling
+	runCtx, cancel := context.WithTimeout(ctx, timeLimit)
+	defer cancel()
+
+	start := time.Now()
+	instance, err := runtime.InstantiateModule(runCtx, compiled, moduleConfig)
+	elapsed := time.Since(start)
+
+	// The caller went away; the time limit is reported separately
+	if ctx.Err() != nil {
+		return cancelledResponse(), nil
+	}
+
+	response := &models.ExecuteResponse{
+		Success:       true,
+		Status:        "Completed",
+		Output:        stdout.String(),
+		Error:         stderr.String(),
+		ExecutionTime: float64(elapsed.Microseconds()) / 1000,
+	}
+	// Modules need not export a memory; Memory then returns a nil
+	// instance wrapped in the interface
+	if instance != nil && len(compiled.ExportedMemories()) > 0 {
+		response.MemoryKB = int(instance.Memory().Size() / 1024)
+	}
+
+	var exitErr *sys.ExitError
+	switch {
+	case runCtx.Err() == context.DeadlineExceeded:
+		response.Status = "Time Limit Exceeded"
+		if response.Error == "" {
+			response.Error = fmt.Sprintf("Execution exceeded the time limit of %s", timeLimit)
+		}
+	case errors.As(err, &exitErr):
+		if exitErr.ExitCode() != 0 && response.Error == "" {
+			response.Error = fmt.Sprintf("Process exited with code %d", exitErr.ExitCode())
+		}
+	case err != nil:
+		// Traps such as out-of-bounds access or exceeding the memory cap
+		response.Status = "Runtime Error"
+		if response.Error == "" {
+			response.Error = err.Error()
+		}
+	}
+
+	span.SetAttributes(attribute.String("wasm.status", response.Status))
+	return response, nil
+}
+
+// loadModule reads a module from disk once and keeps its bytes
+func (w *WasmService) loadModule(path string) ([]byte, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if module, exists := w.modules[path]; exists {
+		return module, nil
+	}
+
+	module, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	w.modules[path] = module
+	return module, nil
+}
+
+// limitedBuffer keeps at most limit bytes and silently drops the rest
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remaining := b.limit - b.buf.Len(); remaining < len(p) {
+		b.buf.Write(p[:max(remaining, 0)])
+		b.truncated = true
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *limitedBuffer) String() string {
+	if b.truncated {
+		return b.buf.String() + "\n[output truncated]"
+	}
+	return b.buf.String()
+}
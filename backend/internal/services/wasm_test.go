@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/online-compiler/backend/configs"
+)
+
+// staticLanguages returns the same settings for every language
+type staticLanguages configs.LanguageConfig
+
+func (s staticLanguages) Language(int) configs.LanguageConfig { return configs.LanguageConfig(s) }
+func (s staticLanguages) BackendFor(int) string               { return "wasm" }
+
+// buildWasmEcho compiles testdata/wasmecho to a WASI module
+func buildWasmEcho(t *testing.T) string {
+	t.Helper()
+	module := filepath.Join(t.TempDir(), "wasmecho.wasm")
+	cmd := exec.Command("go", "build", "-o", module, "./testdata/wasmecho")
+	cmd.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Skipf("cannot build WASI test module: %v\n%s", err, output)
+	}
+	return module
+}
+
+func TestWasmService(t *testing.T) {
+	module := buildWasmEcho(t)
+
+	tests := []struct {
+		name       string
+		lang       configs.LanguageConfig
+		code       string
+		wantStatus string
+		wantOutput string
+		wantError  string
+	}{
+		{"echo", configs.LanguageConfig{Args: []string{"-u"}}, "hello", "Completed", "-u|hello|input", ""},
+		{"exit code", configs.LanguageConfig{}, "exit 3", "Completed", "", "exiting"},
+		{"time limit", configs.LanguageConfig{TimeLimit: 0.2}, "loop", "Time Limit Exceeded", "", "time limit"},
+		{"memory limit", configs.LanguageConfig{MemoryLimitKB: 64 * 1024}, "alloc", "Completed", "", "out of memory"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.lang.WasmModule = module
+			service := NewWasmService(staticLanguages(tt.lang))
+
+			response, err := service.ExecuteCode(context.Background(), 71, tt.code, "input")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if response.Status != tt.wantStatus || response.Output != tt.wantOutput || !strings.Contains(response.Error, tt.wantError) {
+				t.Errorf("response = %+v, want status %q output %q error containing %q", response, tt.wantStatus, tt.wantOutput, tt.wantError)
+			}
+		})
+	}
+
+	t.Run("cancel", func(t *testing.T) {
+		service := NewWasmService(staticLanguages(configs.LanguageConfig{WasmModule: module}))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		response, err := service.ExecuteCode(ctx, 71, "loop", "")
+		if err != nil || response.Status != "Cancelled" {
+			t.Errorf("response = %+v, err = %v, want cancelled", response, err)
+		}
+	})
+}
+
+func TestWasmServiceMissingModule(t *testing.T) {
+	service := NewWasmService(staticLanguages(configs.LanguageConfig{}))
+
+	response, err := service.ExecuteCode(context.Background(), 71, "x", "")
+	if err != nil || response.Success {
+		t.Errorf("response = %+v, err = %v, want unsuccessful response", response, err)
+	}
+}
+
+func TestWasmServiceWithoutMemory(t *testing.T) {
+	// A module whose _start does nothing and that exports no memory
+	module := filepath.Join(t.TempDir(), "nomemory.wasm")
+	wasm := []byte{
+		0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00,
+		0x01, 0x04, 0x01, 0x60, 0x00, 0x00, // type: func() -> ()
+		0x03, 0x02, 0x01, 0x00, // function 0 has type 0
+		0x07, 0x0a, 0x01, 0x06, '_', 's', 't', 'a', 'r', 't', 0x00, 0x00, // export _start
+		0x0a, 0x04, 0x01, 0x02, 0x00, 0x0b, // empty body
+	}
+	if err := os.WriteFile(module, wasm, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	service := NewWasmService(staticLanguages(configs.LanguageConfig{WasmModule: module}))
+	response, err := service.ExecuteCode(context.Background(), 71, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Status != "Completed" || response.MemoryKB != 0 {
+		t.Errorf("response = %+v, want completed without memory", response)
+	}
+}
@@ -0,0 +1,275 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/online-compiler/backend/configs"
+	"github.com/online-compiler/backend/internal/models"
+	"github.com/online-compiler/backend/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"gorm.io/gorm"
+)
+
+// WebhookEvent is the event name sent with finished submissions
+const WebhookEvent = "submission.completed"
+
+// Signature headers sent with every webhook
+const (
+	WebhookSignatureHeader = "X-Webhook-Signature"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+)
+
+// webhookMaxBackoff caps the delay between delivery attempts
+const webhookMaxBackoff = 5 * time.Minute
+
+var (
+	// ErrInvalidCallbackURL is returned for callback URLs that are not
+	// absolute http(s) URLs
+	ErrInvalidCallbackURL = errors.New("callback_url must be an absolute http or https URL")
+	// ErrWebhooksDisabled is returned for callback URLs while no webhook
+	// secret is configured, as callbacks could not be signed
+	ErrWebhooksDisabled = errors.New("callback_url requires a webhook secret to be configured")
+	// errPrivateAddress is returned when a callback resolves to an internal
+	// address and private addresses are not allowed
+	errPrivateAddress = errors.New("callback address is not publicly routable")
+)
+
+// SubmissionNotifier is told about every finished asynchronous submission
+type SubmissionNotifier interface {
+	SubmissionFinished(submission models.Submission)
+}
+
+// WebhookNotifier POSTs finished submissions to their callback URL. Bodies
+// are signed with HMAC-SHA256 over "<timestamp>.<body>", failed deliveries
+// are retried with exponential backoff, and every attempt is logged to the
+// database.
+type WebhookNotifier struct {
+	DB          *gorm.DB
+	Client      *http.Client
+	Secret      []byte
+	MaxAttempts int
+	Backoff     time.Duration
+
+	// ctx is cancelled by Close to abandon pending retries
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewWebhookNotifier creates a notifier using the webhook settings in cfg.
+// Unless webhook_allow_private is set, callbacks to loopback, private and
+// link-local addresses are refused at connect time.
+func NewWebhookNotifier(db *gorm.DB, cfg *configs.Config) *WebhookNotifier {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	if !cfg.WebhookPrivate {
+		dialer.Control = rejectPrivateAddress
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.Proxy = nil
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &WebhookNotifier{
+		DB: db,
+		Client: &http.Client{
+			Timeout:   time.Duration(cfg.WebhookTimeout) * time.Second,
+			Transport: transport,
+			// A redirect could point at an address the dialer would allow
+			// but the receiver did not intend
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		Secret:      []byte(cfg.WebhookSecret),
+		MaxAttempts: cfg.WebhookAttempts,
+		Backoff:     time.Duration(cfg.WebhookBackoff) * time.Millisecond,
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+}
+
+// ValidateCallbackURL checks that a callback URL can be delivered to and
+// signed
+func (n *WebhookNotifier) ValidateCallbackURL(callbackURL string) error {
+	if len(n.Secret) == 0 {
+		return ErrWebhooksDisabled
+	}
+	u, err := url.Parse(callbackURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidCallbackURL
+	}
+	return nil
+}
+
+// rejectPrivateAddress is a dialer control function refusing non-public IPs
+func rejectPrivateAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+		return errPrivateAddress
+	}
+	return nil
+}
+
+// SubmissionFinished delivers the submission in the background if it has a
+// callback URL. Nothing is sent without a secret, as receivers could not
+// tell the callback from a forged one.
+func (n *WebhookNotifier) SubmissionFinished(submission models.Submission) {
+	if submission.CallbackURL == "" || len(n.Secret) == 0 {
+		return
+	}
+
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		n.Deliver(n.ctx, submission)
+	}()
+}
+
+// Deliver POSTs the submission to its callback URL until it is accepted,
+// the attempts run out or ctx is done. It reports whether it was accepted.
+func (n *WebhookNotifier) Deliver(ctx context.Context, submission models.Submission) bool {
+	ctx, span := tracing.Tracer().Start(ctx, "webhook.deliver")
+	span.SetAttributes(attribute.String("submission_id", submission.ID))
+	defer span.End()
+
+	body, err := json.Marshal(models.WebhookPayload{Event: WebhookEvent, Submission: submission})
+	if err != nil {
+		tracing.RecordError(span, err)
+		return false
+	}
+
+	deliveryID := uuid.New().String()
+	backoff := n.Backoff
+	for attempt := 1; attempt <= n.MaxAttempts; attempt++ {
+		record := n.attempt(ctx, submission, deliveryID, attempt, body)
+		if n.DB != nil {
+			if err := n.DB.WithContext(ctx).Create(&record).Error; err != nil {
+				slog.WarnContext(ctx, "failed to log webhook delivery", "submission_id", submission.ID, "error", err)
+			}
+		}
+
+		if record.Delivered {
+			span.SetAttributes(attribute.Int("webhook.attempts", attempt))
+			return true
+		}
+		slog.WarnContext(ctx, "webhook delivery failed", "submission_id", submission.ID, "url", submission.CallbackURL,
+			"attempt", attempt, "status", record.StatusCode, "error", record.Error)
+
+		// Client errors other than rate limiting will not succeed on retry
+		if record.StatusCode >= 400 && record.StatusCode < 500 && record.StatusCode != http.StatusTooManyRequests {
+			break
+		}
+		if attempt == n.MaxAttempts {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, webhookMaxBackoff)
+	}
+
+	slog.ErrorContext(ctx, "webhook delivery abandoned", "submission_id", submission.ID, "url", submission.CallbackURL)
+	return false
+}
+
+// attempt sends one signed request and describes its outcome
+func (n *WebhookNotifier) attempt(ctx context.Context, submission models.Submission, deliveryID string, attempt int, body []byte) models.WebhookDelivery {
+	record := models.WebhookDelivery{
+		DeliveryID:   deliveryID,
+		SubmissionID: submission.ID,
+		URL:          submission.CallbackURL,
+		Attempt:      attempt,
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, submission.CallbackURL, bytes.NewReader(body))
+	if err != nil {
+		record.Error = err.Error()
+		return record
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "online-compiler-webhook")
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	req.Header.Set(WebhookDeliveryHeader, deliveryID)
+	req.Header.Set(WebhookSignatureHeader, "sha256="+SignWebhook(n.Secret, timestamp, body))
+
+	start := time.Now()
+	resp, err := n.Client.Do(req)
+	record.DurationMS = time.Since(start).Milliseconds()
+	if err != nil {
+		record.Error = err.Error()
+		return record
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	record.StatusCode = resp.StatusCode
+	record.Delivered = resp.StatusCode >= 200 && resp.StatusCode < 300
+	if !record.Delivered {
+		record.Error = fmt.Sprintf("unexpected status %d", resp.StatusCode)
+	}
+	return record
+}
+
+// SignWebhook returns the hex HMAC-SHA256 of "<timestamp>.<body>"
+func SignWebhook(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Deliveries returns the logged delivery attempts for a submission, oldest
+// first
+func (n *WebhookNotifier) Deliveries(ctx context.Context, submissionID string) ([]models.WebhookDelivery, error) {
+	deliveries := []models.WebhookDelivery{}
+	err := n.DB.WithContext(ctx).Where("submission_id = ?", submissionID).Order("id").Find(&deliveries).Error
+	return deliveries, err
+}
+
+// Wait blocks until in-flight deliveries finish or ctx is done
+func (n *WebhookNotifier) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		n.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close abandons pending retries and waits for deliveries to stop
+func (n *WebhookNotifier) Close() {
+	n.cancel()
+	n.wg.Wait()
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/online-compiler/backend/configs"
+	"github.com/online-compiler/backend/internal/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// newWebhookTestNotifier returns a notifier that may call local servers and
+// logs to an in-memory database
+func newWebhookTestNotifier(t *testing.T) *WebhookNotifier {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.WebhookDelivery{}); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := configs.Defaults()
+	cfg.WebhookSecret = "secret"
+	cfg.WebhookBackoff = 1
+	cfg.WebhookPrivate = true
+	notifier := NewWebhookNotifier(db, cfg)
+	t.Cleanup(notifier.Close)
+	return notifier
+}
+
+func TestWebhookDeliveryRetriesAndSigns(t *testing.T) {
+	notifier := newWebhookTestNotifier(t)
+
+	var calls atomic.Int32
+	var payload models.WebhookPayload
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		want := "sha256=" + SignWebhook([]byte("secret"), r.Header.Get(WebhookTimestampHeader), body)
+		if r.Header.Get(WebhookSignatureHeader) != want {
+			t.Errorf("signature = %q, want %q", r.Header.Get(WebhookSignatureHeader), want)
+		}
+		json.Unmarshal(body, &payload)
+	}))
+	defer receiver.Close()
+
+	submission := models.Submission{ID: "sub-1", Status: SubmissionCompleted, CallbackURL: receiver.URL}
+	if !notifier.Deliver(context.Background(), submission) {
+		t.Fatal("delivery was not accepted")
+	}
+	if payload.Event != WebhookEvent || payload.Submission.ID != "sub-1" {
+		t.Errorf("payload = %+v", payload)
+	}
+
+	deliveries, err := notifier.Deliveries(context.Background(), "sub-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 2 || deliveries[0].StatusCode != http.StatusServiceUnavailable || !deliveries[1].Delivered {
+		t.Errorf("deliveries = %+v, want a failed then a delivered attempt", deliveries)
+	}
+	if deliveries[0].DeliveryID != deliveries[1].DeliveryID {
+		t.Error("retries should share the delivery ID")
+	}
+}
+
+func TestWebhookDeliveryStopsOnClientError(t *testing.T) {
+	notifier := newWebhookTestNotifier(t)
+
+	var calls atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer receiver.Close()
+
+	if notifier.Deliver(context.Background(), models.Submission{ID: "sub-1", CallbackURL: receiver.URL}) {
+		t.Error("delivery should have failed")
+	}
+	if calls.Load() != 1 {
+		t.Errorf("receiver called %d times, want no retries", calls.Load())
+	}
+}
+
+func TestWebhookRejectsPrivateAddresses(t *testing.T) {
+	notifier := NewWebhookNotifier(nil, configs.Defaults())
+	notifier.MaxAttempts = 1
+	defer notifier.Close()
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("private callback was called")
+	}))
+	defer receiver.Close()
+
+	record := notifier.attempt(context.Background(), models.Submission{ID: "sub-1", CallbackURL: receiver.URL}, "d", 1, []byte("{}"))
+	if record.Delivered || !strings.Contains(record.Error, errPrivateAddress.Error()) {
+		t.Errorf("record = %+v, want private address error", record)
+	}
+}
@@ -0,0 +1,107 @@
+// Package testutil provides hermetic fakes for the services the backend
+// talks to: Judge0, Piston, Redis and SQLite.
+package testutil
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/online-compiler/backend/configs"
+	"github.com/online-compiler/backend/internal/app"
+	"github.com/online-compiler/backend/internal/database"
+	"github.com/online-compiler/backend/internal/services"
+	"gorm.io/gorm"
+)
+
+var databaseCounter atomic.Int64
+
+// NewTestDB opens a migrated in-memory SQLite database private to the test
+func NewTestDB(t testing.TB) *gorm.DB {
+	t.Helper()
+
+	dsn := fmt.Sprintf("file:testdb%d?mode=memory&cache=shared", databaseCounter.Add(1))
+	db, err := database.InitDatabase(dsn)
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	t.Cleanup(func() { database.CloseDatabase(db) })
+
+	return db
+}
+
+// NewTestRedis starts an in-memory Redis server and returns it with a
+// connected client
+func NewTestRedis(t testing.TB) (*miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	return server, client
+}
+
+// TestApp is an application wired to fake backends
+type TestApp struct {
+	*app.App
+	Judge0 *FakeJudge0
+	Piston *FakePiston
+	Redis  *miniredis.Miniredis
+	Router *gin.Engine
+}
+
+// NewTestApp builds an application backed by fake Judge0 and Piston servers,
+// an in-memory Redis and an in-memory database. configure may adjust the
+// configuration before the application is built.
+func NewTestApp(t testing.TB, configure ...func(cfg *configs.Config)) *TestApp {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	judge0 := NewFakeJudge0(t)
+	piston := NewFakePiston(t)
+	redisServer, redisClient := NewTestRedis(t)
+
+	cfg := configs.Defaults()
+	cfg.GinMode = gin.TestMode
+	cfg.Judge0URL = judge0.URL()
+	cfg.PistonURL = piston.URL()
+	cfg.Judge0Timeout = 5
+	for _, fn := range configure {
+		fn(cfg)
+	}
+
+	application := app.New(cfg, NewTestDB(t), redisClient)
+	t.Cleanup(func() {
+		// Stop submissions, pools, flushers and webhooks before the
+		// database and Redis they write to are closed
+		application.Submissions.CancelAllSubmissions()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		application.Submissions.WaitForSubmissions(ctx)
+		application.Close()
+	})
+
+	// Poll the fake quickly so tests do not wait on real-world intervals
+	if executor, exists := application.Executors.Get("judge0"); exists {
+		if pooled, ok := executor.(*services.PooledExecutor); ok {
+			executor = pooled.Executor
+		}
+		if service, ok := executor.(*services.Judge0Service); ok {
+			service.PollInterval = 5 * time.Millisecond
+		}
+	}
+
+	return &TestApp{
+		App:    application,
+		Judge0: judge0,
+		Piston: piston,
+		Redis:  redisServer,
+		Router: application.Router(),
+	}
+}
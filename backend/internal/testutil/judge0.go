@@ -0,0 +1,320 @@
+package testutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/online-compiler/backend/internal/models"
+)
+
+// Judge0 status IDs used by the fake
+const (
+	Judge0InQueue           = 1
+	Judge0Processing        = 2
+	Judge0Accepted          = 3
+	Judge0WrongAnswer       = 4
+	Judge0TimeLimitExceeded = 5
+	Judge0CompilationError  = 6
+	Judge0RuntimeError      = 11
+)
+
+// Judge0Outcome is the final result the fake reports for a submission
+type Judge0Outcome struct {
+	StatusID      int
+	Stdout        string
+	Stderr        string
+	CompileOutput string
+	Message       string
+	Time          string
+	MemoryKB      int
+}
+
+// Accepted returns a successful outcome with the given stdout
+func Accepted(stdout string) Judge0Outcome {
+	return Judge0Outcome{StatusID: Judge0Accepted, Stdout: stdout, Time: "0.012", MemoryKB: 3200}
+}
+
+// CompilationError returns a compile failure with the given compiler output
+func CompilationError(output string) Judge0Outcome {
+	return Judge0Outcome{StatusID: Judge0CompilationError, CompileOutput: output}
+}
+
+// TimeLimitExceeded returns a time limit failure
+func TimeLimitExceeded() Judge0Outcome {
+	return Judge0Outcome{StatusID: Judge0TimeLimitExceeded, Message: "Time limit exceeded", Time: "5.000"}
+}
+
+var judge0Descriptions = map[int]string{
+	Judge0InQueue:           "In Queue",
+	Judge0Processing:        "Processing",
+	Judge0Accepted:          "Accepted",
+	Judge0WrongAnswer:       "Wrong Answer",
+	Judge0TimeLimitExceeded: "Time Limit Exceeded",
+	Judge0CompilationError:  "Compilation Error",
+	Judge0RuntimeError:      "Runtime Error (NZEC)",
+}
+
+// FakeJudge0 is an in-process Judge0 API. Each submission reports In Queue
+// and Processing for QueuedPolls and ProcessingPolls polls respectively
+// before returning the outcome chosen by Respond.
+type FakeJudge0 struct {
+	Server *httptest.Server
+
+	mu              sync.Mutex
+	Respond         func(sub models.Judge0Submission) Judge0Outcome
+	QueuedPolls     int
+	ProcessingPolls int
+	SubmitStatus    int
+	submissions     map[string]*fakeJudge0Submission
+	Submitted       []models.Judge0Submission
+	Batches         []int
+	Polls           int
+
+	// RejectBatch chooses batch entries answered with an error instead of
+	// a token, and BatchLimit caps the entries answered per batch
+	RejectBatch func(sub models.Judge0Submission) bool
+	BatchLimit  int
+}
+
+type fakeJudge0Submission struct {
+	outcome Judge0Outcome
+	polls   int
+}
+
+// NewFakeJudge0 starts a fake Judge0 server that is closed with the test.
+// By default every submission is accepted and echoes its stdin.
+func NewFakeJudge0(t testing.TB) *FakeJudge0 {
+	t.Helper()
+
+	f := &FakeJudge0{
+		Respond: func(sub models.Judge0Submission) Judge0Outcome {
+			return Accepted(sub.Stdin)
+		},
+		QueuedPolls:     1,
+		ProcessingPolls: 1,
+		submissions:     make(map[string]*fakeJudge0Submission),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /submissions", f.handleSubmit)
+	mux.HandleFunc("POST /submissions/batch", f.handleSubmitBatch)
+	mux.HandleFunc("GET /submissions/batch", f.handleGetBatch)
+	mux.HandleFunc("GET /submissions/{token}", f.handleGet)
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Server.Close)
+
+	return f
+}
+
+// URL returns the base URL of the fake server
+func (f *FakeJudge0) URL() string {
+	return f.Server.URL
+}
+
+// SetRespond replaces the function choosing each submission's outcome
+func (f *FakeJudge0) SetRespond(respond func(sub models.Judge0Submission) Judge0Outcome) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Respond = respond
+}
+
+// FailSubmissions makes submissions fail with the given HTTP status
+func (f *FakeJudge0) FailSubmissions(status int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.SubmitStatus = status
+}
+
+// SetPolls sets how many polls a submission spends in the queue and
+// processing before its outcome is reported
+func (f *FakeJudge0) SetPolls(queued, processing int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.QueuedPolls, f.ProcessingPolls = queued, processing
+}
+
+// SetRejectBatch makes batch entries for which reject returns true fail
+// validation, as Judge0 reports an unknown language
+func (f *FakeJudge0) SetRejectBatch(reject func(sub models.Judge0Submission) bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.RejectBatch = reject
+}
+
+// SetBatchLimit answers only the first limit entries of each batch, as a
+// misbehaving proxy might; 0 answers every entry
+func (f *FakeJudge0) SetBatchLimit(limit int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.BatchLimit = limit
+}
+
+// BatchSizes returns the number of entries in each batch submission
+func (f *FakeJudge0) BatchSizes() []int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.Batches)
+}
+
+// SubmissionCount returns the number of accepted submissions
+func (f *FakeJudge0) SubmissionCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.Submitted)
+}
+
+// PollCount returns the number of result polls received
+func (f *FakeJudge0) PollCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.Polls
+}
+
+func (f *FakeJudge0) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	var sub models.Judge0Submission
+	if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
+		http.Error(w, `{"error":"invalid submission"}`, http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.SubmitStatus != 0 {
+		http.Error(w, `{"error":"submission rejected"}`, f.SubmitStatus)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, models.Judge0Response{Token: f.submitLocked(sub)})
+}
+
+func (f *FakeJudge0) handleSubmitBatch(w http.ResponseWriter, r *http.Request) {
+	var batch models.Judge0BatchSubmission
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil || len(batch.Submissions) == 0 {
+		http.Error(w, `{"error":"invalid submissions"}`, http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.SubmitStatus != 0 {
+		http.Error(w, `{"error":"submission rejected"}`, f.SubmitStatus)
+		return
+	}
+
+	f.Batches = append(f.Batches, len(batch.Submissions))
+	entries := batch.Submissions
+	if f.BatchLimit > 0 && len(entries) > f.BatchLimit {
+		entries = entries[:f.BatchLimit]
+	}
+	created := make([]interface{}, len(entries))
+	for i, sub := range entries {
+		if f.RejectBatch != nil && f.RejectBatch(sub) {
+			created[i] = map[string][]string{"language_id": {fmt.Sprintf("language with id %d doesn't exist", sub.LanguageID)}}
+			continue
+		}
+		created[i] = models.Judge0Response{Token: f.submitLocked(sub)}
+	}
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// submitLocked stores a submission and returns its token. Like Judge0, an
+// accepted outcome whose stdout differs from the expected output becomes
+// Wrong Answer. Callers must hold f.mu.
+func (f *FakeJudge0) submitLocked(sub models.Judge0Submission) string {
+	token := fmt.Sprintf("token-%d", len(f.Submitted)+1)
+	outcome := f.Respond(sub)
+	if sub.ExpectedOutput != "" && outcome.StatusID == Judge0Accepted && outcome.Stdout != sub.ExpectedOutput {
+		outcome.StatusID = Judge0WrongAnswer
+	}
+	f.Submitted = append(f.Submitted, sub)
+	f.submissions[token] = &fakeJudge0Submission{outcome: outcome}
+	return token
+}
+
+func (f *FakeJudge0) handleGet(w http.ResponseWriter, r *http.Request) {
+	token := r.PathValue("token")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.Polls++
+	result, exists := f.pollLocked(token)
+	if !exists {
+		http.Error(w, `{"error":"Not Found"}`, http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (f *FakeJudge0) handleGetBatch(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.Polls++
+	batch := models.Judge0BatchResult{Submissions: []models.Judge0Result{}}
+	for _, token := range strings.Split(r.URL.Query().Get("tokens"), ",") {
+		if result, exists := f.pollLocked(token); exists {
+			batch.Submissions = append(batch.Submissions, result)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, batch)
+}
+
+// pollLocked advances a submission by one poll and returns its state.
+// Callers must hold f.mu.
+func (f *FakeJudge0) pollLocked(token string) (models.Judge0Result, bool) {
+	sub, exists := f.submissions[token]
+	if !exists {
+		return models.Judge0Result{}, false
+	}
+
+	sub.polls++
+	statusID := sub.outcome.StatusID
+	switch {
+	case sub.polls <= f.QueuedPolls:
+		statusID = Judge0InQueue
+	case sub.polls <= f.QueuedPolls+f.ProcessingPolls:
+		statusID = Judge0Processing
+	}
+
+	result := models.Judge0Result{
+		Token:  token,
+		Status: models.Status{ID: statusID, Description: judge0Descriptions[statusID]},
+	}
+	if statusID > Judge0Processing {
+		result.Stdout = optional(sub.outcome.Stdout)
+		result.Stderr = optional(sub.outcome.Stderr)
+		result.CompileOutput = optional(sub.outcome.CompileOutput)
+		result.Message = optional(sub.outcome.Message)
+		result.Time = optional(sub.outcome.Time)
+		if sub.outcome.MemoryKB > 0 {
+			memory := sub.outcome.MemoryKB
+			result.Memory = &memory
+		}
+	}
+	return result, true
+}
+
+// optional returns nil for empty strings, matching Judge0's null fields
+func optional(value string) *string {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+	return &value
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
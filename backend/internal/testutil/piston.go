@@ -0,0 +1,161 @@
+package testutil
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/online-compiler/backend/internal/services"
+)
+
+// PistonRuntime describes a runtime reported by /api/v2/runtimes
+type PistonRuntime struct {
+	Language string   `json:"language"`
+	Version  string   `json:"version"`
+	Aliases  []string `json:"aliases"`
+}
+
+// PistonOutcome is the result the fake reports for an execution. A non-nil
+// Compile stage with a non-zero code is reported as a compile failure.
+type PistonOutcome struct {
+	Compile *PistonStage
+	Run     PistonStage
+}
+
+// PistonStage is the output of one Piston stage (compile or run)
+type PistonStage struct {
+	Stdout string `json:"stdout"`
+	Stderr string `json:"stderr"`
+	Code   int    `json:"code"`
+	Signal *int   `json:"signal"`
+	Output string `json:"output"`
+}
+
+// RunOutput returns a successful run with the given stdout
+func RunOutput(stdout string) PistonOutcome {
+	return PistonOutcome{Run: PistonStage{Stdout: stdout, Output: stdout}}
+}
+
+// RunFailure returns a run that exits with code and writes stderr
+func RunFailure(code int, stderr string) PistonOutcome {
+	return PistonOutcome{Run: PistonStage{Stderr: stderr, Output: stderr, Code: code}}
+}
+
+// CompileFailure returns a compile stage failure with the given stderr
+func CompileFailure(stderr string) PistonOutcome {
+	return PistonOutcome{Compile: &PistonStage{Stderr: stderr, Output: stderr, Code: 1}}
+}
+
+// FakePiston is an in-process Piston API
+type FakePiston struct {
+	Server *httptest.Server
+
+	mu       sync.Mutex
+	Respond  func(req services.PistonRequest) PistonOutcome
+	Runtimes []PistonRuntime
+	Delay    time.Duration
+	Status   int
+	Requests []services.PistonRequest
+}
+
+// NewFakePiston starts a fake Piston server that is closed with the test.
+// By default every execution succeeds and echoes its stdin.
+func NewFakePiston(t testing.TB) *FakePiston {
+	t.Helper()
+
+	f := &FakePiston{
+		Respond: func(req services.PistonRequest) PistonOutcome {
+			return RunOutput(req.Stdin)
+		},
+		Runtimes: []PistonRuntime{
+			{Language: "python", Version: "3.10.0", Aliases: []string{"py", "python3"}},
+			{Language: "javascript", Version: "18.15.0", Aliases: []string{"node-javascript", "js"}},
+			{Language: "c++", Version: "10.2.0", Aliases: []string{"cpp", "g++"}},
+		},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v2/runtimes", f.handleRuntimes)
+	mux.HandleFunc("POST /api/v2/execute", f.handleExecute)
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Server.Close)
+
+	return f
+}
+
+// URL returns the base URL of the fake server
+func (f *FakePiston) URL() string {
+	return f.Server.URL
+}
+
+// SetRespond replaces the function choosing each execution's outcome
+func (f *FakePiston) SetRespond(respond func(req services.PistonRequest) PistonOutcome) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Respond = respond
+}
+
+// SetDelay makes every execution take at least d, or until the client
+// disconnects
+func (f *FakePiston) SetDelay(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Delay = d
+}
+
+// FailWith makes every execution fail with the given HTTP status
+func (f *FakePiston) FailWith(status int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Status = status
+}
+
+// RequestCount returns the number of execute requests received
+func (f *FakePiston) RequestCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.Requests)
+}
+
+func (f *FakePiston) handleRuntimes(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	writeJSON(w, http.StatusOK, f.Runtimes)
+}
+
+func (f *FakePiston) handleExecute(w http.ResponseWriter, r *http.Request) {
+	var req services.PistonRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"message":"invalid request"}`, http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	f.Requests = append(f.Requests, req)
+	delay, status, respond := f.Delay, f.Status, f.Respond
+	f.mu.Unlock()
+
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if status != 0 {
+		http.Error(w, `{"message":"piston unavailable"}`, status)
+		return
+	}
+
+	outcome := respond(req)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"language": req.Language,
+		"version":  req.Version,
+		"run":      outcome.Run,
+		"compile":  outcome.Compile,
+	})
+}